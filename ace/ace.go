@@ -297,7 +297,7 @@ func (a *aceAdapter) IsBonded(addr address.Address) (bool, error) {
 	// Check if the device is in the list of bonded devices
 	for _, device := range bondedDevices {
 		if NewAddressFromAce(device) == addr {
-			slog.Debug("Found bonded device", "address", addr.ToString())
+			slog.Debug("Found bonded device", "address", addr)
 			return true, nil
 		}
 	}
@@ -316,12 +316,12 @@ func (a *aceAdapter) Pair(addr address.Address) error {
 
 	status := C.aceBT_pair(AddressToAce(addr), C.ACEBT_TRANSPORT_AUTO)
 	if status == C.ACEBT_STATUS_DONE {
-		slog.Info("Already paired", "address", addr.ToString(), "status", StatusFromCode(status))
+		slog.Info("Already paired", "address", addr, "status", StatusFromCode(status))
 		return nil
 	}
 	err := errForStatus(status)
 	if err != nil {
-		return fmt.Errorf("failed to pair device %s: %w", addr.ToString(), err)
+		return fmt.Errorf("failed to pair device %s: %w", addr, err)
 	}
 
 	for {
@@ -329,15 +329,15 @@ func (a *aceAdapter) Pair(addr address.Address) error {
 		case result := <-pairCh:
 			if result.Address == addr {
 				if result.Err != nil {
-					slog.Warn("device pairing finished", "address", addr.ToString(), "success", false, "error", result.Err)
+					slog.Warn("device pairing finished", "address", addr, "success", false, "error", result.Err)
 					return result.Err
 				}
 				return nil
 			}
-			slog.Info("paired with unexpected device", "address", addr.ToString(), "error", result.Err)
+			slog.Info("paired with unexpected device", "address", addr, "error", result.Err)
 		case <-ctx.Done():
-			slog.Error("Timed out waiting for pairing", "address", addr.ToString())
-			return fmt.Errorf("timed out waiting for pairing with device %s", addr.ToString())
+			slog.Error("Timed out waiting for pairing", "address", addr)
+			return fmt.Errorf("timed out waiting for pairing with device %s", addr)
 		}
 	}
 }
@@ -495,7 +495,7 @@ func (a *aceAdapter) Disconnect(conn ConnHandle) error {
 }
 
 func (a *aceAdapter) PairIfNeeded(addr address.Address) error {
-	slog.Info("Checking if already bonded", "address", addr.ToString())
+	slog.Info("Checking if already bonded", "address", addr)
 	bonded, err := adapter.IsBonded(addr)
 	if err != nil {
 		slog.Error("Failed to check if device is bonded", "error", err)
@@ -503,12 +503,12 @@ func (a *aceAdapter) PairIfNeeded(addr address.Address) error {
 	}
 
 	if !bonded {
-		slog.Info("Ensuring paired", "address", addr.ToString())
+		slog.Info("Ensuring paired", "address", addr)
 		err := adapter.Pair(addr)
 		if err != nil {
 			return err
 		}
-		slog.Info("Device paired successfully", "address", addr.ToString())
+		slog.Info("Device paired successfully", "address", addr)
 	}
 	return nil
 }
@@ -519,7 +519,7 @@ func (a *aceAdapter) Connect(addr address.Address) (ConnHandle, error) {
 	defer cancel()
 	var connHandle ConnHandle
 	connectCh = make(chan ConnHandle)
-	slog.Debug("calling aceBt_bleConnect()", "address", addr.ToString())
+	slog.Debug("calling aceBt_bleConnect()", "address", addr)
 	status := C.aceBt_bleConnect(
 		/* aceBT_sessionHandle */ sessionHandle,
 		/* aceBT_bdAddr_t* */ AddressToAce(addr),
@@ -530,17 +530,17 @@ func (a *aceAdapter) Connect(addr address.Address) (ConnHandle, error) {
 	)
 	err := errForStatus(status)
 	if err != nil {
-		slog.Error("Failed to connect to device", "address", addr.ToString(), "error", err)
+		slog.Error("Failed to connect to device", "address", addr, "error", err)
 		return ConnHandle{}, err
 	}
 	slog.Debug("called aceBt_bleConnect", "status", status)
 
 	select {
 	case <-ctx.Done():
-		slog.Error("Connection timed out", "timeout", ctx.Err(), "address", addr.ToString())
+		slog.Error("Connection timed out", "timeout", ctx.Err(), "address", addr)
 		return ConnHandle{}, ctx.Err()
 	case connHandle = <-connectCh:
-		slog.Info("Connected to device", "address", addr.ToString(), "conn_handle", unsafe.Pointer(connHandle.conn))
+		slog.Info("Connected to device", "address", addr, "conn_handle", unsafe.Pointer(connHandle.conn))
 	}
 	return connHandle, nil
 }
@@ -717,11 +717,11 @@ func onBleConnectionStateChanged(state C.aceBT_bleConnState_t, status C.aceBT_ga
 		"state", state,
 		"gatt_status", status,
 		"conn_handle", unsafe.Pointer(connHandle),
-		"address", NewAddressFromAce(*addr).ToString(),
+		"address", NewAddressFromAce(*addr),
 	)
 	if status != C.ACEBT_GATT_STATUS_SUCCESS {
 		slog.Error("Failed to connect",
-			"address", NewAddressFromAce(*addr).ToString(),
+			"address", NewAddressFromAce(*addr),
 			"gatt_status", status,
 			"conn_state", state,
 			"conn_handle", unsafe.Pointer(connHandle))
@@ -777,7 +777,7 @@ func onBondStateChanged(status C.aceBT_status_t, remoteAddr *C.aceBT_bdAddr_t, s
 	var addrStr string
 	if remoteAddr != nil {
 		addr = NewAddressFromAce(*remoteAddr)
-		addrStr = addr.ToString()
+		addrStr = addr.String()
 	} else {
 		addrStr = "<null>"
 	}
@@ -2,63 +2,145 @@
 package address
 
 import (
+	"errors"
 	"fmt"
-	"strings"
 )
 
+// Address is a 48-bit Bluetooth device address, stored most-significant byte first
+// (i.e. in the order it's usually printed).
+//
+// Address implements encoding.TextMarshaler and encoding.TextUnmarshaler, so it can be used
+// directly as a field in YAML (gopkg.in/yaml.v3) and JSON documents.
 type Address struct {
 	Bytes [6]uint8
 }
 
+var errEmptyAddress = errors.New("empty address")
+
 func errInvalidAddress(addr string) error {
 	return fmt.Errorf("invalid address format: %s", addr)
 }
 
+// fromHex converts a single hex digit to its value, or returns false if c isn't a hex digit.
+func fromHex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// NewFromString parses an address in any of the common textual forms, case-insensitively:
+//
+//	aa:bb:cc:dd:ee:ff
+//	aa-bb-cc-dd-ee-ff
+//	aabb.ccdd.eeff
+//	aabbccddeeff
 func NewFromString(addr string) (Address, error) {
-	parts := make([]string, 6)
-	if len(addr) == 17 && strings.Contains(addr, ":") {
-		parts = strings.Split(addr, ":")
-		if len(parts) != 6 {
-			return Address{}, errInvalidAddress(addr)
-		}
-	} else if len(addr) == 17 && strings.Contains(addr, "-") {
-		parts = strings.Split(addr, "-")
-		if len(parts) != 6 {
-			return Address{}, errInvalidAddress(addr)
-		}
-	} else if len(addr) == 12 && !strings.Contains(addr, ":") {
-		for i := range 6 {
-			parts[i] = addr[i*2 : i*2+2]
-		}
-	} else {
+	if addr == "" {
+		return Address{}, errEmptyAddress
+	}
+	// groupLen is the number of hex digits between separators, and sep is the separator.
+	var groupLen int
+	var sep byte
+	switch {
+	case len(addr) == 17 && (addr[2] == ':' || addr[2] == '-'):
+		groupLen, sep = 2, addr[2]
+	case len(addr) == 14 && addr[4] == '.':
+		groupLen, sep = 4, '.'
+	case len(addr) == 12:
+		groupLen = 12
+	default:
 		return Address{}, errInvalidAddress(addr)
 	}
-	var address [6]uint8
-	for i, part := range parts {
-		var b byte
-		n, err := fmt.Sscanf(part, "%02x", &b)
-		if n != 1 || err != nil {
+
+	var a Address
+	nibbles := 0
+	for i := 0; i < len(addr); i++ {
+		// every (groupLen+1)th character must be the separator
+		if sep != 0 && i%(groupLen+1) == groupLen {
+			if addr[i] != sep {
+				return Address{}, errInvalidAddress(addr)
+			}
+			continue
+		}
+		v, ok := fromHex(addr[i])
+		if !ok {
 			return Address{}, errInvalidAddress(addr)
 		}
-		address[i] = b
+		a.Bytes[nibbles/2] |= v << (4 * (1 - nibbles%2))
+		nibbles++
 	}
-	return Address{Bytes: address}, nil
+	if nibbles != 12 {
+		return Address{}, errInvalidAddress(addr)
+	}
+	return a, nil
 }
 
+// NewFromStringReverse parses an address with NewFromString, then reverses its byte order.
+// This is the format used by e.g. the evdev unique ID of Bluetooth input devices on the Kindle.
 func NewFromStringReverse(addr string) (Address, error) {
 	a, err := NewFromString(addr)
 	if err != nil {
 		return Address{}, err
 	}
+	return a.Reverse(), nil
+}
+
+// Reverse returns the address with its byte order reversed.
+func (a Address) Reverse() Address {
 	reversed := [6]byte{}
 	for i := range 6 {
 		reversed[i] = a.Bytes[5-i]
 	}
-	return Address{Bytes: reversed}, nil
+	return Address{Bytes: reversed}
 }
 
+// IsZero reports whether the address is the all-zero address, usually meaning it was never set.
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString appends the lowercase, colon-separated form of the address to b.
+func (a Address) appendString(b []byte) []byte {
+	for i, v := range a.Bytes {
+		if i > 0 {
+			b = append(b, ':')
+		}
+		b = append(b, hexDigits[v>>4], hexDigits[v&0xf])
+	}
+	return b
+}
+
+// String returns the address in lowercase, colon-separated form, e.g. "e4:17:d8:0a:b0:0c".
+func (a Address) String() string {
+	return string(a.appendString(make([]byte, 0, 17)))
+}
+
+// ToString returns the same as String.
+//
+// Deprecated: use String, or pass the Address directly to fmt or slog.
 func (a Address) ToString() string {
-	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
-		a.Bytes[0], a.Bytes[1], a.Bytes[2],
-		a.Bytes[3], a.Bytes[4], a.Bytes[5])
+	return a.String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a Address) MarshalText() ([]byte, error) {
+	return a.appendString(make([]byte, 0, 17)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting any form NewFromString does.
+func (a *Address) UnmarshalText(text []byte) error {
+	parsed, err := NewFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
 }
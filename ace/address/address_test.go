@@ -0,0 +1,147 @@
+package address
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var testAddr = Address{Bytes: [6]uint8{0xe4, 0x17, 0xd8, 0x0a, 0xb0, 0x0c}}
+
+func TestNewFromString(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{
+		"e4:17:d8:0a:b0:0c",
+		"E4:17:D8:0A:B0:0C",
+		"e4-17-d8-0a-b0-0c",
+		"e417.d80a.b00c",
+		"E417.D80A.B00C",
+		"e417d80ab00c",
+	} {
+		got, err := NewFromString(s)
+		if err != nil {
+			t.Errorf("NewFromString(%q) error = %v", s, err)
+			continue
+		}
+		if got != testAddr {
+			t.Errorf("NewFromString(%q) = %v, want %v", s, got, testAddr)
+		}
+	}
+}
+
+func TestNewFromStringRejects(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{
+		"",
+		"7081.940dfbaa",
+		"e4:17:d8-0a:b0:0c",
+		"e4-17-d8-0a-b0:0c",
+		"e417.d80a:b00c",
+		"e4:17:d8:0a:b0",
+		"e4:17:d8:0a:b0:0c:00",
+		"e4:17:d8:0a:b0:0",
+		"e417d80ab00",
+		"e417d80ab00c0",
+		"e4:17:d8:0a:b0:0g",
+		"e417d80ab0 c",
+		"e4:17:d8:0a:b0:+c",
+	} {
+		if got, err := NewFromString(s); err == nil {
+			t.Errorf("NewFromString(%q) = %v, want an error", s, got)
+		}
+	}
+}
+
+func TestNewFromStringReverse(t *testing.T) {
+	t.Parallel()
+	got, err := NewFromStringReverse("0c:b0:0a:d8:17:e4")
+	if err != nil {
+		t.Fatalf("NewFromStringReverse() error = %v", err)
+	}
+	if got != testAddr {
+		t.Errorf("NewFromStringReverse() = %v, want %v", got, testAddr)
+	}
+}
+
+func TestAddressText(t *testing.T) {
+	t.Parallel()
+	if got, want := testAddr.String(), "e4:17:d8:0a:b0:0c"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	text, err := testAddr.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText() error = %v", err)
+	}
+	if string(text) != testAddr.String() {
+		t.Errorf("MarshalText() = %q, want %q", text, testAddr.String())
+	}
+	var got Address
+	if err := got.UnmarshalText(text); err != nil {
+		t.Fatalf("UnmarshalText(%q) error = %v", text, err)
+	}
+	if got != testAddr {
+		t.Errorf("UnmarshalText(%q) = %v, want %v", text, got, testAddr)
+	}
+	if err := got.UnmarshalText([]byte("e4:17")); err == nil {
+		t.Error("UnmarshalText() of a short address succeeded, want an error")
+	}
+	if got != testAddr {
+		t.Errorf("failed UnmarshalText() changed the address to %v", got)
+	}
+
+	// Address is usable as a JSON string and map key through the text methods
+	b, err := json.Marshal(map[Address]Address{testAddr: {}})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if want := `{"e4:17:d8:0a:b0:0c":"00:00:00:00:00:00"}`; string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		addr   string
+		random bool
+		want   Kind
+	}{
+		{"70:81:94:0d:fb:aa", false, KindPublic},
+		{"c0:00:00:00:00:01", false, KindPublic},
+		{"c0:00:00:00:00:01", true, KindRandomStatic},
+		{"70:81:94:0d:fb:aa", true, KindResolvablePrivate},
+		{"30:00:00:00:00:01", true, KindNonResolvablePrivate},
+		{"80:00:00:00:00:01", true, KindReserved},
+	}
+	for _, tt := range tests {
+		a, err := NewFromString(tt.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := KindOf(a, tt.random); got != tt.want {
+			t.Errorf("KindOf(%s, %v) = %v, want %v", tt.addr, tt.random, got, tt.want)
+		}
+		if got := KindOf(a, tt.random).IsRandom(); got != tt.random {
+			t.Errorf("KindOf(%s, %v).IsRandom() = %v", tt.addr, tt.random, got)
+		}
+	}
+}
+
+func TestVendor(t *testing.T) {
+	t.Parallel()
+	if got, ok := testAddr.Vendor(false); !ok || got != "8BitDo" {
+		t.Errorf("Vendor(false) = %q, %v, want 8BitDo", got, ok)
+	}
+	if got, ok := testAddr.Vendor(true); ok {
+		t.Errorf("Vendor(true) = %q for a random address, want none", got)
+	}
+	local := Address{Bytes: [6]uint8{0xe6, 0x17, 0xd8, 0x0a, 0xb0, 0x0c}}
+	if !local.IsLocallyAdministered() {
+		t.Errorf("%v isn't locally administered", local)
+	}
+	if got, ok := local.Vendor(false); ok {
+		t.Errorf("Vendor() = %q for a locally administered address, want none", got)
+	}
+	if got, want := testAddr.OUI().String(), "e4:17:d8"; got != want {
+		t.Errorf("OUI() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package address
+
+// Kind is the kind of a Bluetooth device address, as defined in the Core Specification
+// (Vol 6, Part B, Section 1.3).
+type Kind uint8
+
+const (
+	// KindPublic is an IEEE-assigned address, with an OUI in its upper three bytes.
+	KindPublic Kind = iota
+	// KindRandomStatic is a random address that is fixed for at least a power cycle.
+	KindRandomStatic
+	// KindResolvablePrivate is a random address that rotates, but can be resolved to an identity
+	// address with the device's Identity Resolving Key.
+	KindResolvablePrivate
+	// KindNonResolvablePrivate is a random address that rotates and can't be resolved.
+	KindNonResolvablePrivate
+	// KindReserved is a random address using the reserved sub-type bits, which shouldn't be seen in practice.
+	KindReserved
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindPublic:
+		return "public"
+	case KindRandomStatic:
+		return "random_static"
+	case KindResolvablePrivate:
+		return "resolvable_private"
+	case KindNonResolvablePrivate:
+		return "non_resolvable_private"
+	case KindReserved:
+		return "reserved"
+	}
+	return "unknown"
+}
+
+// IsRandom reports whether the kind is one of the random address kinds.
+func (k Kind) IsRandom() bool {
+	return k != KindPublic
+}
+
+// KindOf returns the kind of the address.
+// Whether an address is public or random isn't encoded in the address itself: it comes from the
+// address type sent alongside it (e.g. in an advertising PDU or the bonding info), so callers have
+// to pass that in. Random addresses are then classified by their two most significant bits.
+func KindOf(a Address, random bool) Kind {
+	if !random {
+		return KindPublic
+	}
+	switch a.Bytes[0] >> 6 {
+	case 0b11:
+		return KindRandomStatic
+	case 0b01:
+		return KindResolvablePrivate
+	case 0b00:
+		return KindNonResolvablePrivate
+	default:
+		return KindReserved
+	}
+}
@@ -0,0 +1,47 @@
+package address
+
+import "fmt"
+
+// OUI is the Organizationally Unique Identifier in the upper three bytes of a public address.
+type OUI [3]uint8
+
+func (o OUI) String() string {
+	return fmt.Sprintf("%02x:%02x:%02x", o[0], o[1], o[2])
+}
+
+// OUI returns the upper three bytes of the address.
+// This is only meaningful for public addresses; see KindOf.
+func (a Address) OUI() OUI {
+	return OUI{a.Bytes[0], a.Bytes[1], a.Bytes[2]}
+}
+
+// IsLocallyAdministered reports whether the U/L bit is set, meaning the OUI isn't IEEE-assigned.
+func (a Address) IsLocallyAdministered() bool {
+	return a.Bytes[0]&0x02 != 0
+}
+
+// knownVendors is a small table of OUIs for devices we've seen used with kindle-keymap and friends.
+// It's not meant to be exhaustive, just enough to make logs friendlier.
+var knownVendors = map[OUI]string{
+	{0xe4, 0x17, 0xd8}: "8BitDo",
+	{0x28, 0xcf, 0x51}: "Nintendo",
+	{0x98, 0xb6, 0xe9}: "Nintendo",
+	{0x00, 0x1f, 0x32}: "Nintendo",
+	{0xb8, 0x27, 0xeb}: "Raspberry Pi Foundation",
+	{0xdc, 0xa6, 0x32}: "Raspberry Pi Trading",
+}
+
+// Vendor looks up the vendor name for the OUI, returning false if it's unknown.
+func (o OUI) Vendor() (string, bool) {
+	name, ok := knownVendors[o]
+	return name, ok
+}
+
+// Vendor looks up the vendor name for a public address.
+// Random addresses and addresses with an unknown OUI return false.
+func (a Address) Vendor(random bool) (string, bool) {
+	if KindOf(a, random) != KindPublic || a.IsLocallyAdministered() {
+		return "", false
+	}
+	return a.OUI().Vendor()
+}
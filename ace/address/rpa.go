@@ -0,0 +1,149 @@
+package address
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// IRK is a 128-bit Identity Resolving Key, exchanged during bonding. It's stored most-significant
+// byte first, matching how the Core Specification writes keys.
+//
+// IRK implements encoding.TextMarshaler and encoding.TextUnmarshaler using 32 hex digits.
+type IRK [16]uint8
+
+// NewIRKFromString parses an IRK from 32 hex digits, optionally with a leading "0x".
+func NewIRKFromString(s string) (IRK, error) {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		s = s[2:]
+	}
+	var k IRK
+	if len(s) != 2*len(k) {
+		return IRK{}, fmt.Errorf("invalid IRK: expected %d hex digits, got %d", 2*len(k), len(s))
+	}
+	_, err := hex.Decode(k[:], []byte(s))
+	if err != nil {
+		return IRK{}, fmt.Errorf("invalid IRK: %w", err)
+	}
+	return k, nil
+}
+
+func (k IRK) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// IsZero reports whether the key is all zeroes, usually meaning it was never set.
+func (k IRK) IsZero() bool {
+	return k == IRK{}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k IRK) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *IRK) UnmarshalText(text []byte) error {
+	parsed, err := NewIRKFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
+
+// ah is the random address hash function from the Core Specification (Vol 3, Part H, Section 2.2.2):
+// the low 24 bits of AES-128(k, r padded to 128 bits).
+func ah(k IRK, r [3]uint8) [3]uint8 {
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		// only possible with a bad key length, which the IRK type rules out
+		panic(err)
+	}
+	var in, out [aes.BlockSize]uint8
+	copy(in[aes.BlockSize-3:], r[:])
+	block.Encrypt(out[:], in[:])
+	return [3]uint8{out[aes.BlockSize-3], out[aes.BlockSize-2], out[aes.BlockSize-1]}
+}
+
+// Resolves reports whether a was generated from this key.
+// a is taken to be a random address, and only resolvable private ones (see KindOf) can match.
+func (k IRK) Resolves(a Address) bool {
+	if KindOf(a, true) != KindResolvablePrivate {
+		return false
+	}
+	prand := [3]uint8{a.Bytes[0], a.Bytes[1], a.Bytes[2]}
+	hash := [3]uint8{a.Bytes[3], a.Bytes[4], a.Bytes[5]}
+	return ah(k, prand) == hash
+}
+
+// Resolver maps resolvable private addresses back to the identity addresses of bonded devices.
+// The zero value is ready to use, and a nil *Resolver resolves every address to itself.
+//
+// IRKs aren't stored from bonding: the ACE Bluetooth API has no way to read the keys a device
+// sent while bonding, so they have to be configured by hand instead, e.g. read from another host
+// the device has bonded with (a device sends the same IRK to every host it bonds with).
+type Resolver struct {
+	mu   sync.RWMutex
+	irks map[Address]IRK
+	// cache remembers recent resolutions, since the same RPA is typically seen many times
+	// before it rotates (every ~15 minutes by default).
+	cache map[Address]Address
+}
+
+// NewResolver returns an empty Resolver.
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+// Add registers the IRK for a device's identity address, replacing any existing key for it.
+func (r *Resolver) Add(identity Address, irk IRK) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.irks == nil {
+		r.irks = make(map[Address]IRK)
+	}
+	r.irks[identity] = irk
+	// a new or replaced key can change the result of any previous resolution
+	r.cache = nil
+}
+
+// Resolve returns the identity address for a, and whether it was resolved with a known IRK.
+// Addresses that can't be resolved are returned unchanged, so the result can always be compared
+// against configured identity addresses.
+//
+// random is the address type that came with a, as for KindOf, so public addresses aren't hashed
+// against every IRK. Callers that weren't told the type should pass true: a public address then
+// only resolves if its lower half happens to be the hash of its upper half under a known IRK.
+func (r *Resolver) Resolve(a Address, random bool) (Address, bool) {
+	if r == nil || KindOf(a, random) != KindResolvablePrivate {
+		return a, false
+	}
+	r.mu.RLock()
+	identity, ok := r.cache[a]
+	r.mu.RUnlock()
+	if ok {
+		return identity, true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for identity, irk := range r.irks {
+		if irk.Resolves(a) {
+			if r.cache == nil {
+				r.cache = make(map[Address]Address)
+			}
+			r.cache[a] = identity
+			return identity, true
+		}
+	}
+	return a, false
+}
+
+// Matches reports whether a is the given identity address, or an RPA that resolves to it.
+// random is the address type that came with a, see Resolve.
+func (r *Resolver) Matches(a Address, random bool, identity Address) bool {
+	resolved, _ := r.Resolve(a, random)
+	return resolved == identity
+}
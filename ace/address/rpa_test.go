@@ -0,0 +1,111 @@
+package address
+
+import "testing"
+
+// The sample data from the Core Specification (Vol 3, Part H, Appendix D.7).
+const (
+	testIRK = "ec0234a357c8ad05341010a60a397d9b"
+	testRPA = "70:81:94:0d:fb:aa"
+)
+
+func mustIRK(t *testing.T, s string) IRK {
+	t.Helper()
+	k, err := NewIRKFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func mustAddr(t *testing.T, s string) Address {
+	t.Helper()
+	a, err := NewFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAh(t *testing.T) {
+	t.Parallel()
+	got := ah(mustIRK(t, testIRK), [3]uint8{0x70, 0x81, 0x94})
+	if want := [3]uint8{0x0d, 0xfb, 0xaa}; got != want {
+		t.Errorf("ah() = %x, want %x", got, want)
+	}
+}
+
+func TestNewIRKFromString(t *testing.T) {
+	t.Parallel()
+	k := mustIRK(t, testIRK)
+	if k.String() != testIRK {
+		t.Errorf("String() = %s, want %s", k, testIRK)
+	}
+	if got := mustIRK(t, "0X"+testIRK); got != k {
+		t.Errorf("NewIRKFromString() with 0X = %s, want %s", got, k)
+	}
+	for _, s := range []string{"", "0x", testIRK[:30], testIRK + "00", "zz" + testIRK[2:]} {
+		if _, err := NewIRKFromString(s); err == nil {
+			t.Errorf("NewIRKFromString(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestIRKResolves(t *testing.T) {
+	t.Parallel()
+	k := mustIRK(t, testIRK)
+	tests := map[string]bool{
+		testRPA: true,
+		// a different hash
+		"70:81:94:0d:fb:ab": false,
+		// the same hash, but the prand is no longer that of an RPA
+		"30:81:94:0d:fb:aa": false,
+		"f0:81:94:0d:fb:aa": false,
+	}
+	for addr, want := range tests {
+		if got := k.Resolves(mustAddr(t, addr)); got != want {
+			t.Errorf("Resolves(%s) = %v, want %v", addr, got, want)
+		}
+	}
+	if (IRK{1}).Resolves(mustAddr(t, testRPA)) {
+		t.Errorf("Resolves(%s) with another key = true", testRPA)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+	identity := mustAddr(t, "e4:17:d8:0a:b0:0c")
+	rpa := mustAddr(t, testRPA)
+
+	var nilResolver *Resolver
+	if got, ok := nilResolver.Resolve(rpa, true); ok || got != rpa {
+		t.Errorf("nil Resolve() = %v, %v, want the address unchanged", got, ok)
+	}
+
+	r := NewResolver()
+	if got, ok := r.Resolve(rpa, true); ok || got != rpa {
+		t.Errorf("Resolve() without keys = %v, %v, want the address unchanged", got, ok)
+	}
+	r.Add(mustAddr(t, "28:cf:51:12:34:56"), IRK{1})
+	r.Add(identity, mustIRK(t, testIRK))
+	// twice, the second time from the cache
+	for range 2 {
+		if got, ok := r.Resolve(rpa, true); !ok || got != identity {
+			t.Errorf("Resolve() = %v, %v, want %v", got, ok, identity)
+		}
+	}
+	if got, ok := r.Resolve(rpa, false); ok || got != rpa {
+		t.Errorf("Resolve() of a public address = %v, %v, want the address unchanged", got, ok)
+	}
+	if !r.Matches(rpa, true, identity) || !r.Matches(identity, false, identity) {
+		t.Error("Matches() = false, want true")
+	}
+	if r.Matches(rpa, false, identity) {
+		t.Error("Matches() of a public address = true, want false")
+	}
+
+	// replacing the key drops cached resolutions
+	r.Add(identity, IRK{2})
+	if got, ok := r.Resolve(rpa, true); ok {
+		t.Errorf("Resolve() after replacing the key = %v, want it unresolved", got)
+	}
+}
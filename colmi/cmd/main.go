@@ -55,7 +55,7 @@ func findColmiDevice(adapter ace.Adapter) (address.Address, error) {
 		// TODO: Implement device selection logic based on characteristics
 		if strings.HasPrefix(device.Name(), "R02") {
 			deviceAddr = device.Address()
-			slog.Info("found Colmi R02 device, stopping scan", "name", device.Name(), "address", device.Address().ToString(), "rssi", device.RSSI(), "tx_power", device.TxPower(), "adapter", adapter)
+			slog.Info("found Colmi R02 device, stopping scan", "name", device.Name(), "address", device.Address(), "rssi", device.RSSI(), "tx_power", device.TxPower(), "adapter", adapter)
 			// Stop the scan after finding the device
 			err := adapter.StopScan()
 			if err != nil {
@@ -63,7 +63,7 @@ func findColmiDevice(adapter ace.Adapter) (address.Address, error) {
 			}
 			close(deviceFoundChan)
 		} else {
-			slog.Info("found device", "name", device.Name(), "address", device.Address().ToString(), "rssi", device.RSSI(), "tx_power", device.TxPower())
+			slog.Info("found device", "name", device.Name(), "address", device.Address(), "rssi", device.RSSI(), "tx_power", device.TxPower())
 		}
 	})
 	if err != nil {
@@ -131,7 +131,7 @@ func doMain() error {
 		}
 	}
 
-	slog.Info("Connecting to device", "address", deviceAddr.ToString()) //#nosec
+	slog.Info("Connecting to device", "address", deviceAddr) //#nosec
 	result, err := connectAndFindCharacteristics(ctx, adapter, deviceAddr)
 	if err != nil {
 		return fmt.Errorf("failed to connect to device: %w", err)
@@ -163,7 +163,7 @@ type ConnectResult struct {
 }
 
 func connectAndFindCharacteristics(_ context.Context, adapter ace.Adapter, addr address.Address) (ConnectResult, error) {
-	slog.Info("Connecting to device", "address", addr.ToString()) //#nosec
+	slog.Info("Connecting to device", "address", addr) //#nosec
 	conn, err := adapter.Connect(addr)
 	if err != nil {
 		return ConnectResult{}, err
@@ -1,4 +1,4 @@
-// Package actions contains the constants for various actions that can be mapped to keys
+// Package actions is the registry of actions that can be mapped to keys, along with the schemas of their parameters.
 package actions
 
 const (
@@ -15,3 +15,37 @@ const (
 	OrientLockLeft  string = "orientation_lock_left"
 	OrientLockRight string = "orientation_lock_right"
 )
+
+// Structured actions, which take parameters in bindings like `{action: brightness, delta: 3}`.
+const (
+	Brightness    string = "brightness"
+	Warmth        string = "warmth"
+	SetBrightness string = "set_brightness"
+	SetWarmth     string = "set_warmth"
+	Rotate        string = "rotate"
+	OrientLock    string = "orientation_lock"
+)
+
+// Rotation directions for the rotate action.
+const (
+	Clockwise        string = "cw"
+	Counterclockwise string = "ccw"
+)
+
+// Layer actions, which switch a device's bindings to one of its layers: `layer_hold:nav` while the key
+// is held, or `layer_toggle:light` until the key is pressed again.
+const (
+	LayerHold   string = "layer_hold"
+	LayerToggle string = "layer_toggle"
+)
+
+// Key presses a key by its X keysym name, like `key:Return`.
+const Key string = "key"
+
+// Macro runs a list of actions in order, and Sleep waits between them:
+//
+//	{action: macro, steps: [next_page, sleep:500ms, set_prop:com.lab126.powerd/flIntensity=24]}
+const (
+	Macro string = "macro"
+	Sleep string = "sleep"
+)
@@ -0,0 +1,88 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultStepTimeout limits how long each step of a macro may take, unless the step sets its own timeout.
+const DefaultStepTimeout = 5 * time.Second
+
+func init() {
+	Register(Func(Schema{
+		Name: Macro,
+		Doc:  "Run a list of actions in order, stopping at the first one that fails.",
+		Params: []Param{
+			{Name: "steps", Type: StepsParam, Doc: "the actions to run; each may also set a timeout, like {action: rotate, timeout: 2s}"},
+			{
+				Name:    "repeat",
+				Type:    IntParam,
+				Default: int32(1),
+				Check: func(v any) error {
+					if v.(int32) < 1 {
+						return fmt.Errorf("a macro has to repeat at least once, got %d", v)
+					}
+					return nil
+				},
+				Doc: "how many times to run the steps",
+			},
+		},
+	}, runMacro))
+	Register(Func(Schema{
+		Name: Sleep,
+		Doc:  "Wait before the next step of a macro, e.g. sleep:500ms.",
+		Params: []Param{{
+			Name: "duration",
+			Type: StringParam,
+			Check: func(v any) error {
+				_, err := time.ParseDuration(v.(string))
+				return err
+			},
+			Doc: "how long to wait, like 500ms or 2s",
+		}},
+		Shorthand: "duration",
+	}, func(ctx context.Context, params Params) error {
+		d, err := time.ParseDuration(params.Str("duration"))
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+			return nil
+		}
+	}))
+}
+
+func runMacro(ctx context.Context, params Params) error {
+	steps := params.Steps("steps")
+	for range params.Int("repeat") {
+		for i, step := range steps {
+			err := runStep(ctx, step)
+			if err != nil {
+				return fmt.Errorf("macro step %d (%s): %w", i+1, step, err)
+			}
+		}
+	}
+	return nil
+}
+
+func runStep(ctx context.Context, step Step) error {
+	// a macro is cancelled along with ctx, e.g. when its device disconnects
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	timeout := step.Timeout
+	if timeout == 0 && step.Action != Sleep && step.Action != Macro {
+		// sleeps and nested macros take as long as they're meant to
+		timeout = DefaultStepTimeout
+	}
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return Run(ctx, step.Action, step.Params)
+}
@@ -0,0 +1,159 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Generic LIPC property actions, which take their target in the binding itself:
+//
+//	set_prop:com.lab126.powerd/flIntensity=0
+//	inc_prop:com.lab126.powerd/flIntensity:+2:max=flMaxIntensity
+//	toggle_prop:com.lab126.powerd/preventScreenSaver
+//	toggle_prop:com.lab126.winmgr/orientationLock=U,L
+//	set_prop:com.lab126.example/name="42"
+const (
+	SetProp    string = "set_prop"
+	IncProp    string = "inc_prop"
+	ToggleProp string = "toggle_prop"
+)
+
+// PropValue is a property value in a binding: an int if it parses as one, a string otherwise.
+// Values in double quotes are always strings, so numeric strings can be set too.
+type PropValue struct {
+	Str   string
+	Int   int32
+	IsInt bool
+}
+
+func parsePropValue(s string) PropValue {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return PropValue{Str: s[1 : len(s)-1]}
+	}
+	i, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return PropValue{Str: s}
+	}
+	return PropValue{Int: int32(i), IsInt: true}
+}
+
+func (v PropValue) String() string {
+	if v.IsInt {
+		return strconv.Itoa(int(v.Int))
+	}
+	return v.Str
+}
+
+// PropBound is an inc_prop limit: a fixed number, or the name of another property on the
+// same service to read it from (like flMaxIntensity).
+type PropBound struct {
+	Value    int32
+	Property string
+}
+
+// PropSpec is a parsed generic property action.
+type PropSpec struct {
+	// Kind is SetProp, IncProp or ToggleProp.
+	Kind     string
+	Service  string
+	Property string
+
+	// Value is what set_prop sets.
+	Value PropValue
+	// Delta is what inc_prop adds, and Min and Max optionally limit the result.
+	Delta    int32
+	Min, Max *PropBound
+	// Values are what toggle_prop cycles through. Without them, it toggles an int between 0 and 1.
+	Values []PropValue
+}
+
+// ParsePropSpec parses the target of a generic property action (SetProp, IncProp or ToggleProp),
+// i.e. everything after "set_prop:" and so on.
+func ParsePropSpec(kind, target string) (PropSpec, error) {
+	spec := PropSpec{Kind: kind}
+	action := kind + ":" + target
+	switch kind {
+	case SetProp, IncProp, ToggleProp:
+	default:
+		return spec, fmt.Errorf("%q is not a property action", kind)
+	}
+
+	// the service and property come first, followed by "=" and values, or ":" and arguments
+	service, rest, _ := strings.Cut(target, "/")
+	end := strings.IndexAny(rest, "=:")
+	if end == -1 {
+		end = len(rest)
+	}
+	property, rest := rest[:end], rest[end:]
+	if service == "" || property == "" {
+		return spec, fmt.Errorf("%q: expected <service>/<property>", action)
+	}
+	spec.Service, spec.Property = service, property
+	value, hasValue := strings.CutPrefix(rest, "=")
+	args, _ := strings.CutPrefix(rest, ":")
+	if hasValue {
+		args = ""
+	}
+
+	switch spec.Kind {
+	case SetProp:
+		if !hasValue {
+			return spec, fmt.Errorf("%q: expected %s:<service>/<property>=<value>", action, SetProp)
+		}
+		spec.Value = parsePropValue(value)
+	case ToggleProp:
+		if args != "" {
+			return spec, fmt.Errorf("%q: expected %s:<service>/<property>[=<value>,<value>...]", action, ToggleProp)
+		}
+		if hasValue {
+			for v := range strings.SplitSeq(value, ",") {
+				spec.Values = append(spec.Values, parsePropValue(v))
+			}
+			if len(spec.Values) < 2 {
+				return spec, fmt.Errorf("%q: toggling needs at least two values", action)
+			}
+		}
+	case IncProp:
+		if hasValue {
+			return spec, fmt.Errorf("%q: expected %s:<service>/<property>:<delta>[:min=<n>][:max=<n>]", action, IncProp)
+		}
+		err := spec.parseIncArgs(args)
+		if err != nil {
+			return spec, fmt.Errorf("%q: %w", action, err)
+		}
+	}
+	return spec, nil
+}
+
+func (spec *PropSpec) parseIncArgs(args string) error {
+	if args == "" {
+		return errors.New("missing delta, e.g. +1 or -2")
+	}
+	parts := strings.Split(args, ":")
+	delta, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil || delta == 0 {
+		return fmt.Errorf("invalid delta %q, expected e.g. +1 or -2", parts[0])
+	}
+	spec.Delta = int32(delta)
+	for _, part := range parts[1:] {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return fmt.Errorf("invalid argument %q, expected min=<n> or max=<n>", part)
+		}
+		bound := &PropBound{Property: value}
+		if n, err := strconv.ParseInt(value, 10, 32); err == nil {
+			bound = &PropBound{Value: int32(n)}
+		}
+		switch name {
+		case "min":
+			spec.Min = bound
+		case "max":
+			spec.Max = bound
+		default:
+			return fmt.Errorf("unknown argument %q, expected min or max", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package actions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePropSpecValues(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		kind, target string
+		want         PropSpec
+	}{
+		{SetProp, "s/p=12", PropSpec{Kind: SetProp, Service: "s", Property: "p", Value: PropValue{Int: 12, IsInt: true}}},
+		{SetProp, "s/p=-3", PropSpec{Kind: SetProp, Service: "s", Property: "p", Value: PropValue{Int: -3, IsInt: true}}},
+		{SetProp, "s/p=U", PropSpec{Kind: SetProp, Service: "s", Property: "p", Value: PropValue{Str: "U"}}},
+		{SetProp, `s/p="12"`, PropSpec{Kind: SetProp, Service: "s", Property: "p", Value: PropValue{Str: "12"}}},
+		{SetProp, `s/p=""`, PropSpec{Kind: SetProp, Service: "s", Property: "p", Value: PropValue{}}},
+		{SetProp, `s/p="`, PropSpec{Kind: SetProp, Service: "s", Property: "p", Value: PropValue{Str: `"`}}},
+		{SetProp, "s/p=", PropSpec{Kind: SetProp, Service: "s", Property: "p", Value: PropValue{}}},
+		{ToggleProp, `s/p="0",1`, PropSpec{Kind: ToggleProp, Service: "s", Property: "p", Values: []PropValue{{Str: "0"}, {Int: 1, IsInt: true}}}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePropSpec(tt.kind, tt.target)
+		if err != nil {
+			t.Errorf("ParsePropSpec(%q, %q) error = %v", tt.kind, tt.target, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePropSpec(%q, %q) = %+v, want %+v", tt.kind, tt.target, got, tt.want)
+		}
+	}
+}
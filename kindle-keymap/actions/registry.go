@@ -0,0 +1,100 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/clintharrison/bueno/xkb"
+)
+
+// Action is something a key can be bound to. Implementations register themselves with Register,
+// usually from an init func, so bindings can refer to them by name.
+type Action interface {
+	// Schema describes the action's name and parameters, for validating bindings.
+	Schema() Schema
+	// Run performs the action. The params have been validated against the schema, with
+	// defaults filled in.
+	Run(ctx context.Context, params Params) error
+}
+
+// Env is what actions need to run, handed to them once at startup by Init.
+type Env struct {
+	X11  *xkb.X11
+	LIPC lipc.Caller
+}
+
+var (
+	registry  = make(map[string]Action)
+	initFuncs []func(env *Env) error
+)
+
+// Register adds an action, so bindings can use it. It panics if the name is taken,
+// since that's a programming error.
+func Register(a Action) {
+	name := a.Schema().Name
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("action %q is already registered", name))
+	}
+	registry[name] = a
+}
+
+// OnInit adds a func for Init to call, for packages whose actions need something from the Env.
+func OnInit(f func(env *Env) error) {
+	initFuncs = append(initFuncs, f)
+}
+
+// Init sets up the registered actions to run in env. It's called again with a new Env
+// whenever the key map restarts.
+func Init(env *Env) error {
+	var errs []error
+	for _, f := range initFuncs {
+		errs = append(errs, f(env))
+	}
+	return errors.Join(errs...)
+}
+
+// Lookup returns the named action.
+func Lookup(name string) (Action, bool) {
+	a, ok := registry[name]
+	return a, ok
+}
+
+// All returns the registered actions, sorted by name.
+func All() []Action {
+	result := make([]Action, 0, len(registry))
+	for _, name := range slices.Sorted(maps.Keys(registry)) {
+		result = append(result, registry[name])
+	}
+	return result
+}
+
+// Run runs the named action.
+func Run(ctx context.Context, name string, params Params) error {
+	a, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown action %q", name)
+	}
+	return a.Run(ctx, params)
+}
+
+// Func makes an Action out of a schema and a func, for actions without state of their own.
+func Func(schema Schema, run func(ctx context.Context, params Params) error) Action {
+	return funcAction{schema: schema, run: run}
+}
+
+type funcAction struct {
+	schema Schema
+	run    func(ctx context.Context, params Params) error
+}
+
+func (a funcAction) Schema() Schema {
+	return a.schema
+}
+
+func (a funcAction) Run(ctx context.Context, params Params) error {
+	return a.run(ctx, params)
+}
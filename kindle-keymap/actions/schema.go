@@ -0,0 +1,164 @@
+package actions
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamType is the type of an action parameter.
+type ParamType string
+
+const (
+	IntParam    ParamType = "int"
+	StringParam ParamType = "string"
+	// StepsParam is a list of actions with their own parameters, like the steps of a macro.
+	StepsParam ParamType = "steps"
+)
+
+// Param describes one parameter an action takes in a structured binding, e.g. the delta in
+// `{action: brightness, delta: 3}`.
+type Param struct {
+	Name string
+	Type ParamType
+	// Default is used when the binding leaves the parameter out. Parameters without one are required.
+	Default any
+	// Choices, if set, are the only values a string parameter may have.
+	Choices []string
+	// Check, if set, validates the parsed value further.
+	Check func(v any) error
+	Doc   string
+}
+
+// Required reports whether bindings must give the parameter.
+func (p Param) Required() bool {
+	return p.Default == nil
+}
+
+// Schema describes an action: its name in bindings and the parameters it takes.
+type Schema struct {
+	Name   string
+	Doc    string
+	Params []Param
+	// Shorthand names the parameter that can be given right after the action name, like
+	// "set_prop:<target>", instead of in a structured binding.
+	Shorthand string
+}
+
+// Params are an action's parameter values, by name. Validated params hold int32 values for
+// int parameters, string values for string parameters and []Step values for steps parameters.
+type Params map[string]any
+
+// Int returns an int parameter, or 0 if it's missing.
+func (p Params) Int(name string) int32 {
+	v, _ := p[name].(int32)
+	return v
+}
+
+// Str returns a string parameter, or "" if it's missing.
+func (p Params) Str(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+
+// Steps returns a steps parameter, or nil if it's missing.
+func (p Params) Steps(name string) []Step {
+	v, _ := p[name].([]Step)
+	return v
+}
+
+// Step is an action to run with its parameters, e.g. one of the steps of a macro.
+type Step struct {
+	Action string
+	Params Params
+	// Timeout, if set, limits how long the step may take.
+	Timeout time.Duration
+}
+
+func (s Step) String() string {
+	if len(s.Params) == 0 && s.Timeout == 0 {
+		return s.Action
+	}
+	parts := make([]string, 0, len(s.Params)+1)
+	for _, name := range slices.Sorted(maps.Keys(s.Params)) {
+		parts = append(parts, fmt.Sprintf("%s: %v", name, s.Params[name]))
+	}
+	if s.Timeout != 0 {
+		parts = append(parts, fmt.Sprintf("timeout: %s", s.Timeout))
+	}
+	return fmt.Sprintf("{action: %s, %s}", s.Action, strings.Join(parts, ", "))
+}
+
+// Param returns the named parameter.
+func (s Schema) Param(name string) (Param, bool) {
+	i := slices.IndexFunc(s.Params, func(p Param) bool { return p.Name == name })
+	if i == -1 {
+		return Param{}, false
+	}
+	return s.Params[i], true
+}
+
+// ParseParam converts the text of a parameter value from a binding to the parameter's type.
+// Steps parameters can't be given as text, so the config parses those itself.
+func (s Schema) ParseParam(name, text string) (any, error) {
+	p, ok := s.Param(name)
+	if !ok {
+		return nil, fmt.Errorf("action %q has no parameter %q%s", s.Name, name, s.paramList())
+	}
+	var v any
+	switch p.Type {
+	case IntParam:
+		i, err := strconv.ParseInt(text, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q of action %q must be an int, got %q", name, s.Name, text)
+		}
+		v = int32(i)
+	case StringParam:
+		if len(p.Choices) > 0 && !slices.Contains(p.Choices, text) {
+			return nil, fmt.Errorf("parameter %q of action %q must be one of %s, got %q", name, s.Name, strings.Join(p.Choices, ", "), text)
+		}
+		v = text
+	case StepsParam:
+		return nil, fmt.Errorf("parameter %q of action %q must be a list of actions", name, s.Name)
+	default:
+		return nil, fmt.Errorf("parameter %q of action %q has unknown type %q", name, s.Name, p.Type)
+	}
+	if p.Check != nil {
+		err := p.Check(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// WithDefaults checks that params has all required parameters, and fills in defaults for the rest.
+func (s Schema) WithDefaults(params Params) (Params, error) {
+	result := make(Params, len(s.Params))
+	for _, p := range s.Params {
+		v, ok := params[p.Name]
+		switch {
+		case ok:
+			result[p.Name] = v
+		case p.Required():
+			return nil, fmt.Errorf("action %q needs parameter %q", s.Name, p.Name)
+		default:
+			result[p.Name] = p.Default
+		}
+	}
+	return result, nil
+}
+
+func (s Schema) paramList() string {
+	if len(s.Params) == 0 {
+		return " (it takes none)"
+	}
+	names := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		names[i] = p.Name
+	}
+	return " (it takes " + strings.Join(names, ", ") + ")"
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/holoplot/go-evdev"
+
+	"github.com/clintharrison/bueno/ace/address"
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/clintharrison/bueno/kindle-keymap/watcher"
+	"github.com/clintharrison/bueno/quietly"
+)
+
+// deviceManager watches every evdev node of the configured devices, both those that exist at
+// startup and those udev reports later. A single remote can have several nodes (e.g. keyboard,
+// consumer control and mouse), so nodes are tracked by path as well as by device address, and
+// a node's watch is stopped when udev reports it removed.
+type deviceManager struct {
+	// ctx lives until the manager is stopped, and the watches with it
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    *config.Config
+	w      *watcher.Watcher
+	svc    *keymapService
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	nodes map[string]*deviceNode
+	// allConnected is closed once every configured device has a watched node
+	allConnected chan struct{}
+	closed       bool
+}
+
+// deviceNode is a watched evdev node.
+type deviceNode struct {
+	path string
+	name string
+	// uniqueID is the same for all the nodes of a device, and the watcher identifies devices by it
+	uniqueID string
+	// addr is the configured address of the node's device, which may differ from the address it
+	// connected with if it uses a resolvable private address
+	addr   address.Address
+	since  time.Time
+	cancel context.CancelFunc
+}
+
+func newDeviceManager(ctx context.Context, cfg *config.Config, w *watcher.Watcher, svc *keymapService) *deviceManager {
+	ctx, cancel := context.WithCancel(ctx)
+	m := &deviceManager{
+		ctx:          ctx,
+		cancel:       cancel,
+		cfg:          cfg,
+		w:            w,
+		svc:          svc,
+		nodes:        make(map[string]*deviceNode),
+		allConnected: make(chan struct{}),
+	}
+	svc.setStatusFunc(m.status)
+	return m
+}
+
+// scan starts watching every existing evdev node that belongs to a configured device.
+func (m *deviceManager) scan() {
+	devicePaths, err := evdev.ListDevicePaths()
+	if err != nil {
+		slog.Warn("evdev.ListDevicePaths()", "error", err)
+		return
+	}
+	for _, d := range devicePaths {
+		dev, err := evdev.Open(d.Path)
+		if err != nil {
+			// if we can't open the device, we can't check its unique ID, so we skip it
+			slog.Warn("evdev.Open() failed, skipping device", "name", d.Name, "path", d.Path, "error", err)
+			continue
+		}
+		m.add(dev)
+	}
+}
+
+// add starts watching an evdev node if it belongs to a configured device, and closes it otherwise.
+func (m *deviceManager) add(dev *evdev.InputDevice) {
+	path := dev.Path()
+	devName, err := dev.Name()
+	if err != nil {
+		slog.Warn("dev.Name() failed, skipping device", "path", path, "error", err)
+		quietly.Close(dev)
+		return
+	}
+	uniqueID, err := dev.UniqueID()
+	if err != nil {
+		slog.Warn("dev.UniqueID() failed, skipping device", "name", devName, "path", path, "error", err)
+		quietly.Close(dev)
+		return
+	}
+	addr, err := address.NewFromStringReverse(uniqueID)
+	if err != nil {
+		// e.g. the Kindle's own touchscreen and buttons, which have no unique ID
+		slog.Debug("address.NewFromStringReverse() failed, skipping device", "name", devName, "path", path, "unique_id", uniqueID, "error", err)
+		quietly.Close(dev)
+		return
+	}
+	// the unique ID doesn't say whether the address is public or random, so it may be an RPA
+	cfg := m.cfg.FirstMatchingDevice(addr, true)
+	if cfg == nil {
+		slog.Debug("no config found matching device, skipping it", "name", devName, "path", path, "addr", addr)
+		quietly.Close(dev)
+		return
+	}
+
+	m.mu.Lock()
+	if _, ok := m.nodes[path]; ok || m.ctx.Err() != nil {
+		// udev can report a node that the startup scan already found
+		m.mu.Unlock()
+		quietly.Close(dev)
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	node := &deviceNode{path: path, name: devName, uniqueID: uniqueID, addr: cfg.Address(), since: time.Now(), cancel: cancel}
+	m.nodes[path] = node
+	m.checkAllConnectedLocked()
+	m.mu.Unlock()
+
+	slog.Info("watching device", "name", devName, "path", path, "addr", addr, "cfg", cfg.Dump())
+	m.svc.deviceConnected(path, node.addr)
+	m.wg.Go(func() {
+		m.w.Watch(ctx, dev, cfg)
+		cancel()
+		m.mu.Lock()
+		// the node may have been removed and replaced by a new one at the same path already
+		if m.nodes[path] == node {
+			delete(m.nodes, path)
+		}
+		m.mu.Unlock()
+		m.svc.deviceDisconnected(path)
+		m.logStatus()
+	})
+	m.logStatus()
+}
+
+// layerChanged is a watcher.Watcher.LayerChanged func, passing the layers on to the service by
+// the device's configured address.
+func (m *deviceManager) layerChanged(deviceID string, layers []string) {
+	m.mu.Lock()
+	var addr address.Address
+	found := false
+	for _, n := range m.nodes {
+		if n.uniqueID == deviceID || n.path == deviceID {
+			addr, found = n.addr, true
+			break
+		}
+	}
+	m.mu.Unlock()
+	if found {
+		m.svc.layerChanged(addr, layers)
+	}
+}
+
+// remove stops watching the node at path, which udev reported removed.
+func (m *deviceManager) remove(path string) {
+	m.mu.Lock()
+	node, ok := m.nodes[path]
+	if ok {
+		delete(m.nodes, path)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	slog.Info("watched device removed, stopping its watch", "name", node.name, "path", path, "addr", node.addr)
+	node.cancel()
+}
+
+// connected returns a channel that's closed once every configured device has a watched node.
+func (m *deviceManager) connected() <-chan struct{} {
+	return m.allConnected
+}
+
+func (m *deviceManager) checkAllConnectedLocked() {
+	if m.closed {
+		return
+	}
+	connected := m.connectedLocked()
+	for _, d := range m.cfg.Devices {
+		if !connected[d.Address()] {
+			return
+		}
+	}
+	m.closed = true
+	close(m.allConnected)
+}
+
+// connectedLocked returns the configured addresses of the devices with watched nodes.
+func (m *deviceManager) connectedLocked() map[address.Address]bool {
+	connected := make(map[address.Address]bool)
+	for _, n := range m.nodes {
+		connected[n.addr] = true
+	}
+	return connected
+}
+
+// stop stops all the watches, and waits for them to finish.
+func (m *deviceManager) stop() {
+	// add checks ctx under the lock, so no watches start after this
+	m.mu.Lock()
+	m.cancel()
+	m.mu.Unlock()
+	m.wg.Wait()
+	m.svc.setStatusFunc(nil)
+}
+
+// status describes each configured device and its watched nodes, one device per line, e.g.
+//
+//	e4:17:d8:33:22:11 connected: /dev/input/event3 (8BitDo Micro gamepad) since 12:03:04
+//	28:cf:51:12:34:56 not connected
+func (m *deviceManager) status() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lines := make([]string, 0, len(m.cfg.Devices))
+	for _, d := range m.cfg.Devices {
+		var nodes []string
+		for _, n := range m.nodes {
+			if n.addr == d.Address() {
+				nodes = append(nodes, fmt.Sprintf("%s (%s) since %s", n.path, n.name, n.since.Format(time.TimeOnly)))
+			}
+		}
+		if len(nodes) == 0 {
+			lines = append(lines, d.Address().String()+" not connected")
+			continue
+		}
+		slices.Sort(nodes)
+		lines = append(lines, d.Address().String()+" connected: "+strings.Join(nodes, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *deviceManager) logStatus() {
+	m.mu.Lock()
+	connected := m.connectedLocked()
+	nodes := len(m.nodes)
+	m.mu.Unlock()
+	var missing []string
+	for _, d := range m.cfg.Devices {
+		if !connected[d.Address()] {
+			missing = append(missing, d.Address().String())
+		}
+	}
+	slog.Info("device status", "watched_nodes", nodes, "connected_devices", len(connected), "configured_devices", len(m.cfg.Devices), "missing", missing)
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/holoplot/go-evdev"
@@ -14,93 +17,57 @@ import (
 
 	"github.com/clintharrison/bueno/ace/address"
 	"github.com/clintharrison/bueno/core/logutil"
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
 	"github.com/clintharrison/bueno/kindle-keymap/config"
 	"github.com/clintharrison/bueno/kindle-keymap/install"
 	"github.com/clintharrison/bueno/kindle-keymap/lipcaction"
 	"github.com/clintharrison/bueno/kindle-keymap/watcher"
+	_ "github.com/clintharrison/bueno/kindle-keymap/xkbaction"
 	"github.com/clintharrison/bueno/quietly"
 	"github.com/clintharrison/bueno/udev"
 	"github.com/clintharrison/bueno/xkb"
 )
 
-func findExistingDevice(devices []config.Device) (*evdev.InputDevice, *config.Device, error) {
-	devicePaths, err := evdev.ListDevicePaths()
-	if err != nil {
-		slog.Warn("evdev.ListDevicePaths()", "error", err)
-		return nil, nil, err
-	}
-
-	for _, d := range devicePaths {
-		dev, err := evdev.Open(d.Path)
-		if err != nil {
-			slog.Warn("evdev.Open() failed, skipping device", "name", d.Name, "path", d.Path, "error", err)
-			continue
-			// if we can't open the device, we can't check its unique ID, so we skip it
-		}
-		uniqueID, err := dev.UniqueID()
-		if err != nil {
-			slog.Warn("dev.UniqueID() failed, skipping device", "name", d.Name, "path", d.Path, "error", err)
-			quietly.Close(dev)
-			continue
-		}
-
-		addr, err := address.NewFromStringReverse(uniqueID)
-		if err != nil {
-			slog.Warn("address.NewFromStringReverse() failed, skipping device", "name", d.Name, "path", d.Path, "unique_id", uniqueID, "error", err)
-			quietly.Close(dev)
-			continue
-		}
+var errReloadConfig = errors.New("config reload requested")
 
-		for _, cd := range devices {
-			if cd.Address() == addr {
-				slog.Info("found existing device matching config", "name", d.Name, "path", d.Path, "unique_id", uniqueID, "addr", addr.ToString(), "cfg", cd.Dump())
-				return dev, &cd, nil
-			}
-		}
-	}
-	return nil, nil, errors.New("no input device found matching given patterns")
-}
-
-func startDeviceWatcher(ctx context.Context, cfg *config.Config) chan *evdev.InputDevice {
-	// here we set up a udev watcher to look for _new_ input devices matching the given pattern
-	// we also check any existing devices, and start watching them too
-	deviceCh := make(chan *evdev.InputDevice)
+// startDeviceWatcher sets up a udev watcher that hands new input devices matching the config to
+// mgr, and tells it about removed ones.
+func startDeviceWatcher(ctx context.Context, cfg *config.Config, mgr *deviceManager) {
 	devices := make([]address.Address, 0, len(cfg.Devices))
 	for _, d := range cfg.Devices {
 		devices = append(devices, d.Address())
 	}
 	idw := &udev.InputDeviceWatcher{
-		Devices: devices,
+		Devices:  devices,
+		Resolver: cfg.Resolver,
 		AddFunc: func(dev *evdev.InputDevice) {
-			devName, err := dev.Name()
-			if err == nil {
-				slog.Info("new input device detected", "devname", devName, "path", dev.Path())
-				deviceCh <- dev
-			}
+			slog.Info("new input device detected", "path", dev.Path())
+			mgr.add(dev)
 		},
-		// TODO: consider proactively removing watches on devices that are removed?
-		// It's likely their goroutines are already in a blocking read though, so there's
-		// not a ton we can do there. Once that errors, the goroutine will exit anyway.
 		RemoveFunc: func(uevent netlink.UEvent) {
 			subsystem := uevent.Env["SUBSYSTEM"]
 			devname := uevent.Env["DEVNAME"]
 			if subsystem != "input" && subsystem != "hid" {
 				return
 			}
-			if devname != "" {
-				slog.Debug("input device removed", "devname", devname, "env", uevent.Env)
-			} else {
+			if devname == "" {
 				slog.Debug("unknown input device removed", "env", uevent.Env)
+				return
 			}
+			slog.Debug("input device removed", "devname", devname, "env", uevent.Env)
+			mgr.remove("/dev/" + devname)
 		},
 	}
 	go idw.Start(ctx)
-	go func() {
-	}()
-	return deviceCh
 }
 
-func runKeymapLoop(ctx context.Context, cfg *config.Config) error {
+// runKeymapLoop watches configured devices until ctx is cancelled or a config reload is requested
+// through svc, in which case it returns errReloadConfig.
+func runKeymapLoop(ctx context.Context, cfg *config.Config, svc *keymapService) error {
+	// stop all the watchers on return, so a reload can start fresh ones
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	err := install.MaybeInstallUdevRule(ctx)
 	if err != nil {
 		slog.Error("maybeInstallUdev()", "error", err)
@@ -120,70 +87,66 @@ func runKeymapLoop(ctx context.Context, cfg *config.Config) error {
 		return err
 	}
 	defer quietly.Close(client)
-	brightness := lipcaction.NewBrightnessAction(client)
-	rotation := lipcaction.NewRotationAction(client)
-
-	w := watcher.New(x11, brightness, rotation)
-
-	// kick off the background device watcher
-	deviceCh := startDeviceWatcher(ctx, cfg)
-	// "catch up" on any existing devices that match the pattern
-	deviceFound := false
-	dev, matchedCfg, err := findExistingDevice(cfg.Devices)
-	if err == nil {
-		devName, err := dev.Name()
-		if err == nil {
-			slog.Info("using existing input device", "devname", devName, "path", dev.Path(), "pattern", matchedCfg.Address().ToString())
-			deviceFound = true
-			go func() { deviceCh <- dev }()
-		}
+	err = actions.Init(&actions.Env{X11: x11, LIPC: client.Caller()})
+	if err != nil {
+		slog.Error("actions.Init()", "error", err)
+		return err
 	}
 
+	w := watcher.New()
+	mgr := newDeviceManager(ctx, cfg, w, svc)
+	w.LayerChanged = mgr.layerChanged
+	// the watches have to stop before the X11 and LIPC connections their actions use are closed
+	defer mgr.stop()
+	// kick off the background device watcher, then "catch up" on any existing devices
+	startDeviceWatcher(ctx, cfg, mgr)
+	mgr.scan()
+
 	pairCancelCtx, pairCancel := context.WithCancel(ctx)
 	defer pairCancel()
-	// if we didn't find any existing devices, kick off the process to run pairing
-	if !deviceFound {
-		slog.Info("no existing devices found, starting Bluetooth pairing process")
+	allConnected := mgr.connected()
+	select {
+	case <-allConnected:
+		slog.Info("all configured devices are connected")
+	default:
+		// if some devices are missing, kick off the process to run pairing
+		slog.Info("not all configured devices are connected, starting Bluetooth pairing process")
 		go func() { _ = runSelfAsPairingProcess(pairCancelCtx) }()
 	}
 
-	// now we wait for devices to show up, and then watch their events in a separate goroutine
+	// the manager watches devices as they show up, so we just wait until we're done
 	for {
-		slog.Debug("waiting for device...")
+		slog.Debug("waiting for devices...")
 		select {
 		case <-ctx.Done():
 			slog.Info("shutting down")
 			return nil
-		case dev := <-deviceCh:
-			devName, err := dev.Name()
-			if err != nil {
-				slog.Error("dev.Name()", "error", err)
-				quietly.Close(dev)
-				continue
-			}
-			uniqueID, err := dev.UniqueID()
-			if err != nil {
-				slog.Error("dev.UniqueID()", "error", err, "devname", devName, "path", dev.Path())
-				quietly.Close(dev)
-				continue
-			}
-			addr, err := address.NewFromStringReverse(uniqueID)
-			if err != nil {
-				slog.Error("address.NewFromStringReverse()", "error", err, "devname", devName, "path", dev.Path(), "unique_id", uniqueID)
-				quietly.Close(dev)
-				continue
+		case <-svc.reloadRequests():
+			return errReloadConfig
+		case <-allConnected:
+			// now that every device is here, stop the process looking for devices to pair
+			pairCancel()
+			allConnected = nil
+		}
+	}
+}
+
+// printActions writes the registered actions and their parameters, for writing bindings.
+func printActions(w io.Writer) {
+	for _, a := range actions.All() {
+		schema := a.Schema()
+		fmt.Fprintf(w, "%s\n\t%s\n", schema.Name, schema.Doc)
+		for _, p := range schema.Params {
+			var details []string
+			if len(p.Choices) > 0 {
+				details = append(details, "one of "+strings.Join(p.Choices, ", "))
 			}
-			slog.Debug("got new device", "name", devName, "path", dev.Path(), "error", err, "addr", addr.ToString())
-			cfg := cfg.FirstMatchingDevice(addr)
-			slog.Debug("using config", "cfg", cfg.Dump())
-			if cfg == nil {
-				slog.Error("no config found matching device name", "devname", devName, "path", dev.Path())
-				quietly.Close(dev)
-				continue
+			if p.Required() {
+				details = append(details, "required")
+			} else {
+				details = append(details, fmt.Sprintf("default %v", p.Default))
 			}
-			// now that we found a device, cancel the process looking for devices to pair
-			pairCancel()
-			go w.Watch(ctx, dev, cfg)
+			fmt.Fprintf(w, "\t%s (%s, %s): %s\n", p.Name, p.Type, strings.Join(details, ", "), p.Doc)
 		}
 	}
 }
@@ -201,8 +164,16 @@ func doMain() error {
 	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	listActions := flag.Bool("list-actions", false, "list the actions keys can be bound to, and exit")
+	flag.Parse()
+
 	logutil.ConfigureInteractiveLogger()
 
+	if *listActions {
+		printActions(os.Stdout)
+		return nil
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
@@ -217,7 +188,27 @@ func doMain() error {
 		return nil
 	}
 
-	err = runKeymapLoop(ctx, cfg)
+	svc, err := newKeymapService()
+	if err != nil {
+		slog.Warn("failed to register LIPC service, continuing without it", "service", keymapServiceName, "error", err)
+	} else {
+		defer quietly.Close(svc)
+	}
+
+	for {
+		err = runKeymapLoop(ctx, cfg, svc)
+		if !errors.Is(err, errReloadConfig) {
+			break
+		}
+		newCfg, err := config.Load()
+		if err != nil {
+			// keep running with the old config rather than exiting
+			slog.Error("failed to reload config, keeping the current one", "error", err)
+			continue
+		}
+		slog.Info("reloaded config")
+		cfg = newCfg
+	}
 	if err != nil {
 		return fmt.Errorf("error in keymap loop: %w", err)
 	}
@@ -80,7 +80,7 @@ func runPairProcessInner(ctx context.Context, cfg *config.Config) error {
 	go func() {
 		for _, device := range cfg.Devices {
 			addr := device.Address()
-			addrStr := addr.ToString()
+			addrStr := addr.String()
 			slog.Info("trying to connect", "device", addrStr)
 			err := adapter.PairIfNeeded(addr)
 			if err != nil {
@@ -104,12 +104,12 @@ func runPairProcessInner(ctx context.Context, cfg *config.Config) error {
 			addr, err := pair.Address, pair.error
 			devicesPaired++
 			if _, ok := toBePaired[addr]; !ok {
-				slog.Info("unexpected device paired successfully", "address", addr.ToString())
+				slog.Info("unexpected device paired successfully", "address", addr)
 			} else {
 				if err != nil {
-					slog.Warn("device failed to pair", "error", err, "address", addr.ToString())
+					slog.Warn("device failed to pair", "error", err, "address", addr)
 				} else {
-					slog.Info("device paired successfully", "address", addr.ToString())
+					slog.Info("device paired successfully", "address", addr)
 				}
 				// whether we succeeded or not, we should stop waiting...
 				delete(toBePaired, addr)
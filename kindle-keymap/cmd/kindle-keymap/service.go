@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/clintharrison/bueno/ace/address"
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/clintharrison/bueno/quietly"
+)
+
+const keymapServiceName = "com.bueno.keymap"
+
+// keymapService publishes kindle-keymap's state over LIPC, so KUAL scripts and other tools can use e.g.
+//
+//	lipc-get-prop com.bueno.keymap connectedDevices
+//	lipc-get-prop com.bueno.keymap activeLayers
+//	lipc-get-prop com.bueno.keymap deviceStatus
+//	lipc-get-prop com.bueno.keymap batteryLevels
+//	lipc-set-prop com.bueno.keymap reloadConfig 1
+//	lipc-wait-event com.bueno.keymap deviceConnected
+//	lipc-wait-event com.bueno.keymap layerChanged
+//
+// A nil *keymapService is valid and does nothing, so the keymap still runs if the service can't be registered.
+type keymapService struct {
+	server *lipc.Server
+	reload chan struct{}
+
+	mu sync.Mutex
+	// devices holds the address of each watched device, keyed by evdev path
+	devices map[string]address.Address
+	// layers holds the current layer of each watched device that has switched layers
+	layers map[address.Address]string
+	// status, if set, describes the configured devices and their watched nodes
+	status func() string
+}
+
+func newKeymapService() (*keymapService, error) {
+	server, err := lipc.NewServer(keymapServiceName, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &keymapService{
+		server:  server,
+		reload:  make(chan struct{}, 1),
+		devices: make(map[string]address.Address),
+		layers:  make(map[address.Address]string),
+	}
+	err = s.registerProperties()
+	if err != nil {
+		// release the service name, so the next attempt can claim it
+		quietly.Close(server)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *keymapService) registerProperties() error {
+	err := lipc.RegisterProperty(s.server, "connectedDevices", s.getConnectedDevices, nil)
+	if err != nil {
+		return err
+	}
+	err = lipc.RegisterProperty(s.server, "activeLayers", s.getActiveLayers, nil)
+	if err != nil {
+		return err
+	}
+	err = lipc.RegisterProperty(s.server, "deviceStatus", s.getDeviceStatus, nil)
+	if err != nil {
+		return err
+	}
+	err = lipc.RegisterProperty(s.server, "batteryLevels", s.getBatteryLevels, nil)
+	if err != nil {
+		return err
+	}
+	return lipc.RegisterProperty(s.server, "reloadConfig", nil, s.setReloadConfig)
+}
+
+func (s *keymapService) Close() error {
+	return s.server.Close()
+}
+
+// getConnectedDevices returns the addresses of all watched devices, comma-separated.
+func (s *keymapService) getConnectedDevices(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := make([]string, 0, len(s.devices))
+	for _, addr := range s.devices {
+		addrs = append(addrs, addr.String())
+	}
+	slices.Sort(addrs)
+	// one remote can have several input devices
+	return strings.Join(slices.Compact(addrs), ","), nil
+}
+
+// getActiveLayers returns the current layer of each watched device as address=layer, comma-separated.
+func (s *keymapService) getActiveLayers(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	layers := make([]string, 0, len(s.devices))
+	for _, addr := range s.devices {
+		layer, ok := s.layers[addr]
+		if !ok {
+			layer = config.BaseLayer
+		}
+		layers = append(layers, addr.String()+"="+layer)
+	}
+	slices.Sort(layers)
+	// one remote can have several input devices, which share their layers
+	layers = slices.Compact(layers)
+	return strings.Join(layers, ","), nil
+}
+
+// getBatteryLevels returns the battery level of each watched device that reports one as
+// address=percent, comma-separated.
+func (s *keymapService) getBatteryLevels(context.Context) (string, error) {
+	s.mu.Lock()
+	devices := maps.Clone(s.devices)
+	s.mu.Unlock()
+	levels := make([]string, 0, len(devices))
+	for path, addr := range devices {
+		level, ok := batteryLevel(path)
+		if ok {
+			levels = append(levels, fmt.Sprintf("%s=%d", addr, level))
+		}
+	}
+	slices.Sort(levels)
+	// one remote can have several input devices, which share its battery
+	return strings.Join(slices.Compact(levels), ","), nil
+}
+
+// batteryLevel returns the battery percentage of the device with the evdev node at path, if its
+// HID driver reports one. The kernel adds it as a power supply of the node's HID device, e.g.
+// /sys/class/input/event3/device/device/power_supply/hid-<uniq>-battery/capacity.
+func batteryLevel(path string) (int, bool) {
+	capacities, err := filepath.Glob(filepath.Join("/sys/class/input", filepath.Base(path), "device/device/power_supply/*/capacity"))
+	if err != nil || len(capacities) == 0 {
+		return 0, false
+	}
+	data, err := os.ReadFile(capacities[0]) //#nosec G304 -- a sysfs path built from the node's name
+	if err != nil {
+		slog.Debug("failed to read battery level", "path", capacities[0], "error", err)
+		return 0, false
+	}
+	level, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		slog.Debug("unexpected battery level", "path", capacities[0], "level", string(data), "error", err)
+		return 0, false
+	}
+	return level, true
+}
+
+// getDeviceStatus returns each configured device's status and watched nodes, one device per line.
+func (s *keymapService) getDeviceStatus(context.Context) (string, error) {
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+	if status == nil {
+		return "", nil
+	}
+	return status(), nil
+}
+
+// setStatusFunc sets the func that describes the devices for deviceStatus.
+func (s *keymapService) setStatusFunc(status func() string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status = status
+	s.mu.Unlock()
+}
+
+func (s *keymapService) setReloadConfig(context.Context, int32) error {
+	slog.Info("config reload requested over LIPC")
+	select {
+	case s.reload <- struct{}{}:
+	default:
+		// a reload is already pending
+	}
+	return nil
+}
+
+// reloadRequests returns a channel that receives when a config reload is requested.
+func (s *keymapService) reloadRequests() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.reload
+}
+
+func (s *keymapService) deviceConnected(path string, addr address.Address) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.devices[path] = addr
+	s.mu.Unlock()
+	err := s.server.SendEvent("deviceConnected", addr.String())
+	if err != nil {
+		slog.Warn("failed to send deviceConnected event", "error", err)
+	}
+}
+
+func (s *keymapService) deviceDisconnected(path string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	addr, ok := s.devices[path]
+	delete(s.devices, path)
+	stillConnected := slices.Contains(slices.Collect(maps.Values(s.devices)), addr)
+	if !stillConnected {
+		delete(s.layers, addr)
+	}
+	s.mu.Unlock()
+	if !ok || stillConnected {
+		return
+	}
+	err := s.server.SendEvent("deviceDisconnected", addr.String())
+	if err != nil {
+		slog.Warn("failed to send deviceDisconnected event", "error", err)
+	}
+}
+
+// layerChanged records a device's new active layers, and sends its current layer as an event.
+func (s *keymapService) layerChanged(addr address.Address, layers []string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.layers[addr] = layers[0]
+	s.mu.Unlock()
+	err := s.server.SendEvent("layerChanged", addr.String(), layers[0])
+	if err != nil {
+		slog.Warn("failed to send layerChanged event", "error", err)
+	}
+}
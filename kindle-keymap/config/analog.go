@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/holoplot/go-evdev"
+)
+
+// AnalogMode is how an axis binding turns an axis's position into actions.
+type AnalogMode string
+
+const (
+	// AnalogValue runs an action with one of its parameters set from the axis's position, e.g. to
+	// set the frontlight brightness with an analog trigger. It's for axes that rest at their
+	// minimum: a stick resting at its center would set the value halfway whenever it's let go.
+	AnalogValue AnalogMode = "value"
+	// AnalogRate runs an action over and over while the axis is pushed, more often the further
+	// it's pushed, e.g. to turn pages faster.
+	AnalogRate AnalogMode = "rate"
+)
+
+// Defaults for axis bindings.
+const (
+	DefaultAnalogInterval = 100 * time.Millisecond
+	DefaultAnalogRate     = 5.0
+)
+
+// AnalogAxis is an axis binding: an absolute axis that drives actions continuously, instead of
+// being turned into key presses like an Axis. Axis bindings belong to the device, not to a layer.
+type AnalogAxis struct {
+	Code evdev.EvCode
+	Mode AnalogMode
+	// Curve shapes the response to the axis's position, which is raised to this power after the
+	// deadzone is taken off: 1 is linear, and more than 1 gives finer control near rest.
+	Curve float64
+	// Deadzone is how far the axis can be pushed and still be treated as at rest.
+	Deadzone float64
+
+	// For AnalogValue, Binding runs with its Param set to the axis's position mapped from the
+	// edge of the deadzone to the axis's maximum onto Min to Max (which may be reversed), so the
+	// axis at rest is Min. It runs at most once per Interval, and only when the value changes.
+	Binding  Binding
+	Param    string
+	Min, Max int32
+	Interval time.Duration
+
+	// For AnalogRate, High runs while the axis is pushed towards its maximum, and Low (if set)
+	// while it's pushed towards its minimum. Axes with a Low binding rest at their center, the
+	// others at their minimum. At full deflection they run Rate times a second.
+	Low, High Binding
+	Rate      float64
+}
+
+// Centered reports whether the axis rests at its center, rather than at its minimum.
+func (a AnalogAxis) Centered() bool {
+	return a.Mode == AnalogRate && !a.Low.IsZero()
+}
+
+// centeredAxes are the axes of sticks and hats, which rest at their center.
+var centeredAxes = []evdev.EvCode{
+	evdev.ABS_X, evdev.ABS_Y, evdev.ABS_RX, evdev.ABS_RY,
+	evdev.ABS_HAT0X, evdev.ABS_HAT0Y, evdev.ABS_HAT1X, evdev.ABS_HAT1Y,
+	evdev.ABS_HAT2X, evdev.ABS_HAT2Y, evdev.ABS_HAT3X, evdev.ABS_HAT3Y,
+}
+
+// yamlAnalog is an axis binding in the config, e.g.
+//
+//	GAS: {analog: {mode: value, action: set_brightness, min: 0, max: 24}}
+//	RX: {analog: {mode: rate, low: prev_page, high: next_page, rate: 4}}
+type yamlAnalog struct {
+	Mode     AnalogMode    `yaml:"mode"`
+	Curve    float64       `yaml:"curve,omitempty"`
+	Action   string        `yaml:"action,omitempty"`
+	Param    string        `yaml:"param,omitempty"`
+	Min      int32         `yaml:"min,omitempty"`
+	Max      int32         `yaml:"max,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+	Low      Binding       `yaml:"low,omitempty"`
+	High     Binding       `yaml:"high,omitempty"`
+	Rate     float64       `yaml:"rate,omitempty"`
+}
+
+func parseAnalog(code evdev.EvCode, deadzone float64, y *yamlAnalog) (AnalogAxis, error) {
+	a := AnalogAxis{Code: code, Mode: y.Mode, Curve: y.Curve, Deadzone: deadzone}
+	if a.Curve == 0 {
+		a.Curve = 1
+	}
+	if a.Curve < 0 {
+		return a, fmt.Errorf("curve must be more than 0, got %v", a.Curve)
+	}
+
+	switch y.Mode {
+	case AnalogValue:
+		if !y.Low.IsZero() || !y.High.IsZero() || y.Rate != 0 {
+			return a, errors.New("low, high and rate are for rate mode, use action, min and max")
+		}
+		if slices.Contains(centeredAxes, code) {
+			return a, errors.New("value mode is for axes that rest at their minimum, like triggers, not sticks or hats; use rate mode")
+		}
+		if y.Min == y.Max {
+			return a, fmt.Errorf("value mode needs different min and max, got %d", y.Min)
+		}
+		a.Min, a.Max = y.Min, y.Max
+		a.Interval = y.Interval
+		if a.Interval == 0 {
+			a.Interval = DefaultAnalogInterval
+		}
+		if a.Interval < 0 {
+			return a, fmt.Errorf("interval can't be negative, got %s", a.Interval)
+		}
+		var err error
+		a.Binding, a.Param, err = valueBinding(y.Action, y.Param)
+		if err != nil {
+			return a, err
+		}
+	case AnalogRate:
+		if y.Action != "" || y.Param != "" || y.Min != 0 || y.Max != 0 || y.Interval != 0 {
+			return a, errors.New("action, param, min, max and interval are for value mode, use low, high and rate")
+		}
+		if y.High.IsZero() {
+			return a, errors.New("rate mode needs a high binding")
+		}
+		a.Low, a.High = y.Low, y.High
+		a.Rate = y.Rate
+		if a.Rate == 0 {
+			a.Rate = DefaultAnalogRate
+		}
+		if a.Rate < 0 {
+			return a, fmt.Errorf("rate must be more than 0, got %v", a.Rate)
+		}
+	default:
+		return a, fmt.Errorf("unknown mode %q, expected %s or %s", y.Mode, AnalogValue, AnalogRate)
+	}
+	return a, nil
+}
+
+// valueBinding checks that an action can be driven by an axis in value mode, through the int
+// parameter param. Without param, it's the action's only int parameter.
+func valueBinding(action, param string) (Binding, string, error) {
+	if action == "" {
+		return Binding{}, "", errors.New("value mode needs an action")
+	}
+	a, ok := actions.Lookup(action)
+	if !ok {
+		return Binding{}, "", fmt.Errorf("unknown action %q", action)
+	}
+	schema := a.Schema()
+	if param == "" {
+		for _, p := range schema.Params {
+			if p.Type != actions.IntParam {
+				continue
+			}
+			if param != "" {
+				return Binding{}, "", fmt.Errorf("action %q has more than one int parameter, choose one with param", action)
+			}
+			param = p.Name
+		}
+	}
+	if p, ok := schema.Param(param); !ok || p.Type != actions.IntParam {
+		return Binding{}, "", fmt.Errorf("action %q needs an int parameter to set from the axis, got %q", action, param)
+	}
+	params, err := schema.WithDefaults(actions.Params{param: int32(0)})
+	if err != nil {
+		return Binding{}, "", err
+	}
+	return Binding{Action: action, Params: params}, param, nil
+}
+
+// WithValue returns the value binding with its parameter set to v.
+func (a AnalogAxis) WithValue(v int32) Binding {
+	params := maps.Clone(a.Binding.Params)
+	params[a.Param] = v
+	return Binding{Action: a.Binding.Action, Params: params}
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseAnalog(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		yaml    string
+		check   func(t *testing.T, a AnalogAxis)
+		wantErr string
+	}{
+		{
+			name: "value",
+			yaml: "GAS: {analog: {mode: value, action: test_step, min: 0, max: 24}}",
+			check: func(t *testing.T, a AnalogAxis) {
+				if a.Param != "delta" || a.Min != 0 || a.Max != 24 || a.Deadzone != 0.1 || a.Curve != 1 || a.Interval != DefaultAnalogInterval {
+					t.Errorf("axis = %+v", a)
+				}
+				if a.Centered() {
+					t.Error("Centered() = true for a trigger")
+				}
+			},
+		},
+		{
+			name: "value with a deadzone",
+			yaml: "Z: {deadzone: 0.2, analog: {mode: value, action: test_step, min: 24, max: 0}}",
+			check: func(t *testing.T, a AnalogAxis) {
+				if a.Deadzone != 0.2 || a.Min != 24 || a.Max != 0 {
+					t.Errorf("axis = %+v", a)
+				}
+			},
+		},
+		{
+			name:    "value on a stick",
+			yaml:    "RY: {analog: {mode: value, action: test_step, min: 0, max: 24}}",
+			wantErr: `axis "RY": value mode is for axes that rest at their minimum, like triggers, not sticks or hats; use rate mode`,
+		},
+		{
+			name:    "value on a hat",
+			yaml:    "ABS_HAT0X: {analog: {mode: value, action: test_step, min: 0, max: 24}}",
+			wantErr: `axis "ABS_HAT0X": value mode is for axes that rest at their minimum, like triggers, not sticks or hats; use rate mode`,
+		},
+		{
+			name: "rate on a stick",
+			yaml: "RY: {analog: {mode: rate, low: test_press, high: test_press}}",
+			check: func(t *testing.T, a AnalogAxis) {
+				if !a.Centered() || a.Rate != DefaultAnalogRate {
+					t.Errorf("axis = %+v, want a centered axis at the default rate", a)
+				}
+			},
+		},
+		{
+			name:    "value without a range",
+			yaml:    "GAS: {analog: {mode: value, action: test_step, min: 3, max: 3}}",
+			wantErr: `axis "GAS": value mode needs different min and max, got 3`,
+		},
+		{
+			name:    "bad deadzone",
+			yaml:    "GAS: {deadzone: 1, analog: {mode: value, action: test_step, min: 0, max: 24}}",
+			wantErr: `axis "GAS": deadzone must be between 0 and 1, got 1`,
+		},
+		{
+			name:    "keys too",
+			yaml:    "GAS: {high: TR2, analog: {mode: value, action: test_step, min: 0, max: 24}}",
+			wantErr: `axis "GAS": an axis with an analog binding can't also press keys`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var cfg map[string]yamlAxis
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			axes, analog, err := parseAxes(cfg)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if len(analog) != 1 {
+				t.Fatalf("analog = %v, want one axis", analog)
+			}
+			for code, a := range analog {
+				if _, ok := axes[code]; ok {
+					t.Errorf("bound axis %v also presses keys", code)
+				}
+				tt.check(t, a)
+			}
+		})
+	}
+}
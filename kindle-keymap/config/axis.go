@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// Axis is how an absolute axis, like a D-pad or an analog trigger, is turned into key presses
+// and releases. Deflections are fractions of the axis's range: from its center for axes with a
+// Low key, and from its minimum for axes without one, like analog triggers that rest there.
+type Axis struct {
+	Code evdev.EvCode
+	// Low and High are the keys held while the axis is pushed towards its minimum or maximum,
+	// or 0 for none.
+	Low, High evdev.EvCode
+	// Threshold is how far the axis has to be pushed to press a key.
+	Threshold float64
+	// Hysteresis is how far the axis has to come back below Threshold to release the key again,
+	// so a value hovering around the threshold doesn't press it over and over.
+	Hysteresis float64
+	// Deadzone is how far the axis can be pushed and still be treated as at rest.
+	Deadzone float64
+}
+
+// Centered reports whether the axis rests at its center, rather than at its minimum.
+func (a Axis) Centered() bool {
+	return a.Low != 0
+}
+
+// DefaultAxes are the axes turned into keys unless the config says otherwise: sticks and
+// D-pads that report an axis (like the 8BitDo's) press the D-pad keys, and analog triggers press
+// TL2 and TR2. Triggers reported as ABS_Z and ABS_RZ have to be set up in the config, since some
+// gamepads report their right stick on those axes, which would hold TL2 and TR2 while it rests
+// at its center.
+var DefaultAxes = map[evdev.EvCode]Axis{
+	evdev.ABS_X:     defaultAxis(evdev.ABS_X, evdev.BTN_DPAD_LEFT, evdev.BTN_DPAD_RIGHT),
+	evdev.ABS_HAT0X: defaultAxis(evdev.ABS_HAT0X, evdev.BTN_DPAD_LEFT, evdev.BTN_DPAD_RIGHT),
+	evdev.ABS_Y:     defaultAxis(evdev.ABS_Y, evdev.BTN_DPAD_UP, evdev.BTN_DPAD_DOWN),
+	evdev.ABS_HAT0Y: defaultAxis(evdev.ABS_HAT0Y, evdev.BTN_DPAD_UP, evdev.BTN_DPAD_DOWN),
+	evdev.ABS_BRAKE: defaultAxis(evdev.ABS_BRAKE, 0, evdev.BTN_TL2),
+	evdev.ABS_GAS:   defaultAxis(evdev.ABS_GAS, 0, evdev.BTN_TR2),
+}
+
+func defaultAxis(code, low, high evdev.EvCode) Axis {
+	return Axis{Code: code, Low: low, High: high, Threshold: 0.5, Hysteresis: 0.1, Deadzone: 0.1}
+}
+
+// yamlAxis is an axis in the config. Fields left out keep their defaults.
+type yamlAxis struct {
+	Low        string  `yaml:"low,omitempty"`
+	High       string  `yaml:"high,omitempty"`
+	Threshold  float64 `yaml:"threshold,omitempty"`
+	Hysteresis float64 `yaml:"hysteresis,omitempty"`
+	Deadzone   float64 `yaml:"deadzone,omitempty"`
+	// Ignore stops the axis being turned into keys, e.g. for a stick that shouldn't press the D-pad keys.
+	Ignore bool `yaml:"ignore,omitempty"`
+	// Analog binds the axis to actions directly instead of to keys, see AnalogAxis.
+	Analog *yamlAnalog `yaml:"analog,omitempty"`
+}
+
+// parseAxes merges the axes in a device's config into the defaults, and returns them along with
+// the axis bindings.
+func parseAxes(cfg map[string]yamlAxis) (map[evdev.EvCode]Axis, map[evdev.EvCode]AnalogAxis, error) {
+	axes := maps.Clone(DefaultAxes)
+	analog := make(map[evdev.EvCode]AnalogAxis)
+	for name, y := range cfg {
+		code, ok := evdev.ABSFromString[absName(name)]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown axis %q", name)
+		}
+		if y.Ignore {
+			delete(axes, code)
+			continue
+		}
+		axis, ok := axes[code]
+		if !ok {
+			axis = Axis{Code: code, Threshold: 0.5, Hysteresis: 0.1, Deadzone: 0.1}
+		}
+		if y.Analog != nil {
+			if y.Low != "" || y.High != "" || y.Threshold != 0 || y.Hysteresis != 0 {
+				return nil, nil, fmt.Errorf("axis %q: an axis with an analog binding can't also press keys", name)
+			}
+			deadzone := axis.Deadzone
+			if y.Deadzone != 0 {
+				deadzone = y.Deadzone
+			}
+			if deadzone < 0 || deadzone >= 1 {
+				return nil, nil, fmt.Errorf("axis %q: deadzone must be between 0 and 1, got %v", name, deadzone)
+			}
+			a, err := parseAnalog(code, deadzone, y.Analog)
+			if err != nil {
+				return nil, nil, fmt.Errorf("axis %q: %w", name, err)
+			}
+			delete(axes, code)
+			analog[code] = a
+			continue
+		}
+		var err error
+		if y.Low != "" {
+			axis.Low, err = keyCode(y.Low)
+			if err != nil {
+				return nil, nil, fmt.Errorf("axis %q: %w", name, err)
+			}
+		}
+		if y.High != "" {
+			axis.High, err = keyCode(y.High)
+			if err != nil {
+				return nil, nil, fmt.Errorf("axis %q: %w", name, err)
+			}
+		}
+		if y.Threshold != 0 {
+			axis.Threshold = y.Threshold
+		}
+		if y.Hysteresis != 0 {
+			axis.Hysteresis = y.Hysteresis
+		}
+		if y.Deadzone != 0 {
+			axis.Deadzone = y.Deadzone
+		}
+		switch {
+		case axis.High == 0:
+			return nil, nil, fmt.Errorf("axis %q needs a high key", name)
+		case axis.Threshold <= 0 || axis.Threshold > 1:
+			return nil, nil, fmt.Errorf("axis %q: threshold must be between 0 and 1, got %v", name, axis.Threshold)
+		case axis.Hysteresis < 0 || axis.Hysteresis >= axis.Threshold:
+			return nil, nil, fmt.Errorf("axis %q: hysteresis must be between 0 and the threshold, got %v", name, axis.Hysteresis)
+		case axis.Deadzone < 0 || axis.Deadzone >= 1:
+			return nil, nil, fmt.Errorf("axis %q: deadzone must be between 0 and 1, got %v", name, axis.Deadzone)
+		}
+		axes[code] = axis
+	}
+	return axes, analog, nil
+}
+
+func absName(name string) string {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "ABS_") {
+		name = "ABS_" + name
+	}
+	return name
+}
+
+// keyCode returns the code of a key by its name, which may leave out its KEY_ or BTN_ prefix.
+func keyCode(name string) (evdev.EvCode, error) {
+	name = strings.ToUpper(name)
+	candidates := []string{name}
+	if !isSpecificName(name) {
+		candidates = []string{"KEY_" + name, "BTN_" + name}
+	}
+	for _, candidate := range candidates {
+		if code, ok := evdev.KEYFromString[candidate]; ok {
+			return code, nil
+		}
+	}
+	return 0, errors.New("unknown key " + name)
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/holoplot/go-evdev"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDefaultAxes(t *testing.T) {
+	t.Parallel()
+	axes, analog, err := parseAxes(nil)
+	if err != nil {
+		t.Fatalf("parseAxes() error = %v", err)
+	}
+	if len(analog) != 0 {
+		t.Errorf("parseAxes() analog = %v, want none", analog)
+	}
+	for code, high := range map[evdev.EvCode]evdev.EvCode{
+		evdev.ABS_X:     evdev.BTN_DPAD_RIGHT,
+		evdev.ABS_HAT0Y: evdev.BTN_DPAD_DOWN,
+		evdev.ABS_BRAKE: evdev.BTN_TL2,
+		evdev.ABS_GAS:   evdev.BTN_TR2,
+	} {
+		if axis, ok := axes[code]; !ok || axis.High != high {
+			t.Errorf("axis %s = %+v, want high key %s", evdev.CodeName(evdev.EV_ABS, code), axis, evdev.CodeName(evdev.EV_KEY, high))
+		}
+	}
+	// Z and RZ are a right stick on some gamepads, which would hold TL2 and TR2 at rest
+	for _, code := range []evdev.EvCode{evdev.ABS_Z, evdev.ABS_RZ} {
+		if axis, ok := axes[code]; ok {
+			t.Errorf("axis %s = %+v by default, want none", evdev.CodeName(evdev.EV_ABS, code), axis)
+		}
+	}
+}
+
+func TestParseAxes(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		yaml    string
+		code    evdev.EvCode
+		want    *Axis
+		wantErr string
+	}{
+		{
+			name: "trigger on Z",
+			yaml: "Z: {high: TL2}",
+			code: evdev.ABS_Z,
+			want: &Axis{Code: evdev.ABS_Z, High: evdev.BTN_TL2, Threshold: 0.5, Hysteresis: 0.1, Deadzone: 0.1},
+		},
+		{
+			name:    "Z without a key",
+			yaml:    "ABS_Z: {threshold: 0.3}",
+			wantErr: `axis "ABS_Z" needs a high key`,
+		},
+		{
+			name: "default changed",
+			yaml: "GAS: {high: TL2, threshold: 0.3, hysteresis: 0.05}",
+			code: evdev.ABS_GAS,
+			want: &Axis{Code: evdev.ABS_GAS, High: evdev.BTN_TL2, Threshold: 0.3, Hysteresis: 0.05, Deadzone: 0.1},
+		},
+		{
+			name: "centered",
+			yaml: "RX: {low: DPAD_LEFT, high: DPAD_RIGHT}",
+			code: evdev.ABS_RX,
+			want: &Axis{Code: evdev.ABS_RX, Low: evdev.BTN_DPAD_LEFT, High: evdev.BTN_DPAD_RIGHT, Threshold: 0.5, Hysteresis: 0.1, Deadzone: 0.1},
+		},
+		{
+			name: "ignored",
+			yaml: "X: {ignore: true}",
+			code: evdev.ABS_X,
+		},
+		{
+			name:    "unknown axis",
+			yaml:    "TWIST: {high: A}",
+			wantErr: `unknown axis "TWIST"`,
+		},
+		{
+			name:    "unknown key",
+			yaml:    "Z: {high: NOPE}",
+			wantErr: `axis "Z": unknown key NOPE`,
+		},
+		{
+			name:    "hysteresis above the threshold",
+			yaml:    "GAS: {threshold: 0.2, hysteresis: 0.3}",
+			wantErr: `axis "GAS": hysteresis must be between 0 and the threshold, got 0.3`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var cfg map[string]yamlAxis
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			axes, _, err := parseAxes(cfg)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			axis, ok := axes[tt.code]
+			switch {
+			case tt.want == nil && ok:
+				t.Errorf("axis = %+v, want none", axis)
+			case tt.want != nil && axis != *tt.want:
+				t.Errorf("axis = %+v, want %+v", axis, *tt.want)
+			}
+		})
+	}
+}
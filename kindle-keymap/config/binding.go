@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"gopkg.in/yaml.v3"
+)
+
+// Binding is the action a key is bound to. In the YAML config it's either just the action name,
+// or an object with the action name and its parameters:
+//
+//	A: next_page
+//	B: {action: brightness, delta: 3}
+//	C: {action: orientation_lock, value: L}
+//	D: set_prop:com.lab126.powerd/flIntensity=0
+//
+// The last form is shorthand for {action: set_prop, target: com.lab126.powerd/flIntensity=0},
+// for actions with a Schema.Shorthand parameter.
+type Binding struct {
+	Action string
+	Params actions.Params
+}
+
+func (b Binding) String() string {
+	return actions.Step{Action: b.Action, Params: b.Params}.String()
+}
+
+// IsZero reports whether the binding is unset, i.e. the key isn't bound.
+func (b Binding) IsZero() bool {
+	return b.Action == ""
+}
+
+// nodeError prefixes err with the position of n in the config file.
+func nodeError(n *yaml.Node, err error) error {
+	return fmt.Errorf("line %d, column %d: %w", n.Line, n.Column, err)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, validating the action and its parameters against
+// the schemas of the registered actions.
+func (b *Binding) UnmarshalYAML(n *yaml.Node) error {
+	var actionNode *yaml.Node
+	params := make(actions.Params)
+	var paramNodes []*yaml.Node
+
+	switch n.Kind {
+	case yaml.ScalarNode:
+		actionNode = n
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == "action" {
+				actionNode = value
+				continue
+			}
+			paramNodes = append(paramNodes, key, value)
+		}
+		if actionNode == nil {
+			return nodeError(n, errors.New("binding needs an action"))
+		}
+	default:
+		return nodeError(n, errors.New("binding must be an action name or an object with an action"))
+	}
+	if actionNode.Kind != yaml.ScalarNode || actionNode.Value == "" {
+		return nodeError(actionNode, errors.New("action must be a name"))
+	}
+	action := actionNode.Value
+
+	a, ok := actions.Lookup(action)
+	if !ok && n.Kind == yaml.ScalarNode {
+		// actions like set_prop can take their main parameter right after the name
+		name, arg, found := strings.Cut(action, ":")
+		if sa, exists := actions.Lookup(name); found && exists && sa.Schema().Shorthand != "" {
+			shorthand := sa.Schema().Shorthand
+			v, err := sa.Schema().ParseParam(shorthand, arg)
+			if err != nil {
+				return nodeError(actionNode, err)
+			}
+			params[shorthand] = v
+			a, action, ok = sa, name, true
+		}
+	}
+	if !ok {
+		return nodeError(actionNode, fmt.Errorf("unknown action %q", action))
+	}
+	schema := a.Schema()
+	for i := 0; i < len(paramNodes); i += 2 {
+		key, value := paramNodes[i], paramNodes[i+1]
+		if p, ok := schema.Param(key.Value); ok && p.Type == actions.StepsParam {
+			steps, err := parseSteps(value)
+			if err != nil {
+				return err
+			}
+			params[key.Value] = steps
+			continue
+		}
+		if value.Kind != yaml.ScalarNode {
+			return nodeError(value, fmt.Errorf("parameter %q must be a single value", key.Value))
+		}
+		v, err := schema.ParseParam(key.Value, value.Value)
+		if err != nil {
+			return nodeError(value, err)
+		}
+		params[key.Value] = v
+	}
+	params, err := schema.WithDefaults(params)
+	if err != nil {
+		return nodeError(n, err)
+	}
+	*b = Binding{Action: action, Params: params}
+	return nil
+}
+
+// parseSteps parses a list of actions, like the steps of a macro. Each is written like a binding,
+// and may also have a timeout:
+//
+//	steps: [next_page, sleep:500ms, {action: orientation_lock, value: U, timeout: 2s}]
+func parseSteps(n *yaml.Node) ([]actions.Step, error) {
+	if n.Kind != yaml.SequenceNode {
+		return nil, nodeError(n, errors.New("expected a list of actions"))
+	}
+	if len(n.Content) == 0 {
+		return nil, nodeError(n, errors.New("the list of actions is empty"))
+	}
+	steps := make([]actions.Step, 0, len(n.Content))
+	for _, stepNode := range n.Content {
+		var step actions.Step
+		if stepNode.Kind == yaml.MappingNode {
+			// the timeout belongs to the step rather than the action, so take it out before decoding the binding
+			node := *stepNode
+			node.Content = nil
+			for i := 0; i+1 < len(stepNode.Content); i += 2 {
+				key, value := stepNode.Content[i], stepNode.Content[i+1]
+				if key.Value != "timeout" {
+					node.Content = append(node.Content, key, value)
+					continue
+				}
+				err := value.Decode(&step.Timeout)
+				if err != nil || step.Timeout <= 0 {
+					return nil, nodeError(value, fmt.Errorf("invalid timeout %q, expected e.g. 2s", value.Value))
+				}
+			}
+			stepNode = &node
+		}
+		var b Binding
+		err := stepNode.Decode(&b)
+		if err != nil {
+			return nil, err
+		}
+		step.Action, step.Params = b.Action, b.Params
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"gopkg.in/yaml.v3"
+)
+
+// the config only sees the actions registered by the packages it's linked with, so the tests
+// register their own
+func init() {
+	nop := func(context.Context, actions.Params) error { return nil }
+	actions.Register(actions.Func(actions.Schema{Name: "test_press"}, nop))
+	actions.Register(actions.Func(actions.Schema{
+		Name: "test_step",
+		Params: []actions.Param{
+			{Name: "delta", Type: actions.IntParam, Default: int32(1)},
+			{Name: "dir", Type: actions.StringParam, Default: "up", Choices: []string{"up", "down"}},
+		},
+	}, nop))
+	actions.Register(actions.Func(actions.Schema{
+		Name:      "test_set",
+		Params:    []actions.Param{{Name: "target", Type: actions.StringParam}},
+		Shorthand: "target",
+	}, nop))
+}
+
+func TestBindingUnmarshal(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		yaml    string
+		want    Binding
+		wantErr string
+	}{
+		{
+			name: "string",
+			yaml: "A: test_press",
+			want: Binding{Action: "test_press", Params: actions.Params{}},
+		},
+		{
+			name: "object with defaults",
+			yaml: "A: {action: test_step}",
+			want: Binding{Action: "test_step", Params: actions.Params{"delta": int32(1), "dir": "up"}},
+		},
+		{
+			name: "object",
+			yaml: "A:\n  action: test_step\n  delta: -3\n  dir: down",
+			want: Binding{Action: "test_step", Params: actions.Params{"delta": int32(-3), "dir": "down"}},
+		},
+		{
+			name: "shorthand",
+			yaml: "A: test_set:com.lab126.powerd/flIntensity=0",
+			want: Binding{Action: "test_set", Params: actions.Params{"target": "com.lab126.powerd/flIntensity=0"}},
+		},
+		{
+			name: "object with the shorthand parameter",
+			yaml: "A: {action: test_set, target: x}",
+			want: Binding{Action: "test_set", Params: actions.Params{"target": "x"}},
+		},
+		{
+			name:    "unknown action",
+			yaml:    "A: nope",
+			wantErr: `line 1, column 4: unknown action "nope"`,
+		},
+		{
+			name:    "unknown shorthand action",
+			yaml:    "A: nope:x",
+			wantErr: `line 1, column 4: unknown action "nope:x"`,
+		},
+		{
+			name:    "shorthand for an action without one",
+			yaml:    "A: test_press:x",
+			wantErr: `line 1, column 4: unknown action "test_press:x"`,
+		},
+		{
+			name:    "unknown action in an object",
+			yaml:    "A:\n  action: nope",
+			wantErr: `line 2, column 11: unknown action "nope"`,
+		},
+		{
+			name:    "not an int",
+			yaml:    "A:\n  action: test_step\n  delta: x",
+			wantErr: `line 3, column 10: parameter "delta" of action "test_step" must be an int, got "x"`,
+		},
+		{
+			name:    "not a choice",
+			yaml:    "A: {action: test_step, dir: left}",
+			wantErr: `line 1, column 29: parameter "dir" of action "test_step" must be one of up, down, got "left"`,
+		},
+		{
+			name:    "unknown parameter",
+			yaml:    "A: {action: test_press, delta: 1}",
+			wantErr: `line 1, column 32: action "test_press" has no parameter "delta" (it takes none)`,
+		},
+		{
+			name:    "parameter isn't a value",
+			yaml:    "A: {action: test_step, delta: [1]}",
+			wantErr: `line 1, column 31: parameter "delta" must be a single value`,
+		},
+		{
+			name:    "missing parameter",
+			yaml:    "\nA: {action: test_set}",
+			wantErr: `line 2, column 4: action "test_set" needs parameter "target"`,
+		},
+		{
+			name:    "missing action",
+			yaml:    "A: {delta: 1}",
+			wantErr: `line 1, column 4: binding needs an action`,
+		},
+		{
+			name:    "action isn't a name",
+			yaml:    "A: {action: [test_press]}",
+			wantErr: `line 1, column 13: action must be a name`,
+		},
+		{
+			name:    "list",
+			yaml:    "A: [test_press]",
+			wantErr: `line 1, column 4: binding must be an action name or an object with an action`,
+		},
+		{
+			name:    "bad shorthand value",
+			yaml:    "A: sleep:soon",
+			wantErr: `line 1, column 4: time: invalid duration "soon"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var bind map[string]Binding
+			err := yaml.Unmarshal([]byte(tt.yaml), &bind)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if got := bind["A"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("binding = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []actions.Step
+		wantErr string
+	}{
+		{
+			name: "steps",
+			yaml: "A: {action: macro, steps: [test_press, sleep:10ms, {action: test_step, delta: 2, timeout: 2s}]}",
+			want: []actions.Step{
+				{Action: "test_press", Params: actions.Params{}},
+				{Action: actions.Sleep, Params: actions.Params{"duration": "10ms"}},
+				{Action: "test_step", Params: actions.Params{"delta": int32(2), "dir": "up"}, Timeout: 2 * time.Second},
+			},
+		},
+		{
+			name: "timeout only",
+			yaml: "A:\n  action: macro\n  steps:\n    - {action: test_press, timeout: 150ms}",
+			want: []actions.Step{{Action: "test_press", Params: actions.Params{}, Timeout: 150 * time.Millisecond}},
+		},
+		{
+			name:    "invalid timeout",
+			yaml:    "A:\n  action: macro\n  steps:\n    - {action: test_press, timeout: soon}",
+			wantErr: `line 4, column 37: invalid timeout "soon", expected e.g. 2s`,
+		},
+		{
+			name:    "negative timeout",
+			yaml:    "A: {action: macro, steps: [{action: test_press, timeout: -1s}]}",
+			wantErr: `line 1, column 58: invalid timeout "-1s", expected e.g. 2s`,
+		},
+		{
+			name:    "zero timeout",
+			yaml:    "A: {action: macro, steps: [{action: test_press, timeout: 0s}]}",
+			wantErr: `line 1, column 58: invalid timeout "0s", expected e.g. 2s`,
+		},
+		{
+			name:    "bad step",
+			yaml:    "A:\n  action: macro\n  steps:\n    - test_press\n    - nope",
+			wantErr: `line 5, column 7: unknown action "nope"`,
+		},
+		{
+			name:    "empty",
+			yaml:    "A: {action: macro, steps: []}",
+			wantErr: `line 1, column 27: the list of actions is empty`,
+		},
+		{
+			name:    "not a list",
+			yaml:    "A: {action: macro, steps: test_press}",
+			wantErr: `line 1, column 27: expected a list of actions`,
+		},
+		{
+			name:    "missing steps",
+			yaml:    "A: {action: macro, repeat: 2}",
+			wantErr: `line 1, column 4: action "macro" needs parameter "steps"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var bind map[string]Binding
+			err := yaml.Unmarshal([]byte(tt.yaml), &bind)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			got := bind["A"]
+			if got.Action != actions.Macro {
+				t.Fatalf("action = %q, want %q", got.Action, actions.Macro)
+			}
+			if steps := got.Params.Steps("steps"); !reflect.DeepEqual(steps, tt.want) {
+				t.Errorf("steps = %v, want %v", steps, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Chord is a key pressed while all of Modifiers are held down. The modifiers may be held on
+// another device, e.g. a foot pedal held while pressing a remote's button.
+type Chord struct {
+	Modifiers []string
+	Key       string
+}
+
+func (c Chord) String() string {
+	return strings.Join(append(slices.Clone(c.Modifiers), c.Key), "+")
+}
+
+// Combo is a binding for a chord like `LEFTCTRL+RIGHT`, a sequence of key presses like `A A`
+// (a double tap), or a sequence of chords. The keys of a sequence have to follow each other
+// within KeyTiming.SequenceTimeout.
+type Combo struct {
+	Steps   []Chord
+	Binding Binding
+}
+
+func (c Combo) String() string {
+	steps := make([]string, len(c.Steps))
+	for i, step := range c.Steps {
+		steps[i] = step.String()
+	}
+	return strings.Join(steps, " ")
+}
+
+// isCombo reports whether a key in the bind section is a chord or sequence rather than a single key.
+func isCombo(key string) bool {
+	return strings.ContainsAny(key, " +")
+}
+
+// parseCombo parses the steps of a chord or sequence binding.
+func parseCombo(key string) ([]Chord, error) {
+	var steps []Chord
+	for step := range strings.FieldsSeq(key) {
+		names := strings.Split(strings.ToUpper(step), "+")
+		if slices.Contains(names, "") {
+			return nil, fmt.Errorf("invalid chord %q, expected keys separated by +", step)
+		}
+		steps = append(steps, Chord{Modifiers: names[:len(names)-1], Key: names[len(names)-1]})
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("empty key combination")
+	}
+	return steps, nil
+}
+
+// KeyMatches reports whether a key name from the config matches the name of a key event,
+// which may be several names separated by /. Config names without a KEY_ or BTN_ prefix
+// match either.
+func KeyMatches(name, keyName string) bool {
+	name = strings.ToUpper(name)
+	for key := range strings.SplitSeq(keyName, "/") {
+		if key == name || !isSpecificName(name) && (key == "KEY_"+name || key == "BTN_"+name) {
+			return true
+		}
+	}
+	return false
+}
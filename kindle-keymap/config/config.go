@@ -2,14 +2,20 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/clintharrison/bueno/ace/address"
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
 	"github.com/clintharrison/bueno/quietly"
+	"github.com/holoplot/go-evdev"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,39 +28,149 @@ type yamlConfig struct {
 }
 
 type yamlDevice struct {
-	Name string            `yaml:"name"`
-	Addr string            `yaml:"mac,omitempty"`
-	Bind map[string]string `yaml:"bind"`
+	Name string          `yaml:"name"`
+	Addr address.Address `yaml:"mac,omitempty"`
+	// IRK is the device's Identity Resolving Key, needed to recognize devices that connect
+	// with a resolvable private address instead of the identity address in mac. It isn't
+	// stored from bonding, so it has to be configured; see address.Resolver.
+	IRK  address.IRK        `yaml:"irk,omitempty"`
+	Bind map[string]Binding `yaml:"bind"`
+	// Layers are extra named sets of bindings, switched to with the layer_hold and layer_toggle
+	// actions. Keys they don't bind fall through to the layers below them, and finally to Bind.
+	Layers map[string]map[string]Binding `yaml:"layers,omitempty"`
+	// Nodes bind keys differently on some of the device's evdev nodes, see Node.
+	Nodes []yamlNode `yaml:"nodes,omitempty"`
+	// Axes tune how absolute axes are turned into key presses, see Axis and DefaultAxes, or bind
+	// them to actions directly, see AnalogAxis.
+	Axes map[string]yamlAxis `yaml:"axes,omitempty"`
+	// These tune long-press, hold-to-repeat and sequence bindings, see KeyTiming.
+	LongPress       time.Duration `yaml:"long_press,omitempty"`
+	RepeatDelay     time.Duration `yaml:"repeat_delay,omitempty"`
+	RepeatInterval  time.Duration `yaml:"repeat_interval,omitempty"`
+	SequenceTimeout time.Duration `yaml:"sequence_timeout,omitempty"`
+	// These tune the key presses for wheels and mice, see RelThresholds.
+	WheelThreshold int32 `yaml:"wheel_threshold,omitempty"`
+	MouseThreshold int32 `yaml:"mouse_threshold,omitempty"`
+	// These keep the device's events from the system, see Grab.
+	Grab        bool     `yaml:"grab,omitempty"`
+	GrabLayers  []string `yaml:"grab_layers,omitempty"`
+	Passthrough bool     `yaml:"passthrough,omitempty"`
+}
+
+// Trigger is how a key has to be used to run a binding. Bindings for triggers other than a
+// press are written with the trigger after the key name, like `A.long: rotate_cw`.
+type Trigger string
+
+const (
+	// TriggerPress runs when the key is pressed. If the key also has a long-press binding,
+	// it runs on release instead, and only if the key wasn't held long enough for that.
+	TriggerPress Trigger = ""
+	// TriggerRelease runs when the key is released.
+	TriggerRelease Trigger = "release"
+	// TriggerLong runs once the key has been held for KeyTiming.LongPress.
+	TriggerLong Trigger = "long"
+	// TriggerHoldRepeat runs after the key has been held for KeyTiming.RepeatDelay, and then
+	// every KeyTiming.RepeatInterval until it's released.
+	TriggerHoldRepeat Trigger = "hold_repeat"
+)
+
+func parseTrigger(s string) (Trigger, error) {
+	switch t := Trigger(strings.ToLower(s)); t {
+	case TriggerRelease, TriggerLong, TriggerHoldRepeat:
+		return t, nil
+	}
+	return TriggerPress, fmt.Errorf("unknown trigger %q, expected %s, %s or %s", s, TriggerRelease, TriggerLong, TriggerHoldRepeat)
+}
+
+// KeyTiming holds a device's thresholds for long-press, hold-to-repeat and sequence bindings.
+type KeyTiming struct {
+	LongPress      time.Duration
+	RepeatDelay    time.Duration
+	RepeatInterval time.Duration
+	// SequenceTimeout is how long to wait for the next key of a sequence. A key that starts a
+	// sequence only runs its own binding once this has passed without the sequence continuing.
+	SequenceTimeout time.Duration
+}
+
+// DefaultKeyTiming is used for any timings a device's config leaves out.
+var DefaultKeyTiming = KeyTiming{
+	LongPress:       500 * time.Millisecond,
+	RepeatDelay:     400 * time.Millisecond,
+	RepeatInterval:  150 * time.Millisecond,
+	SequenceTimeout: 300 * time.Millisecond,
 }
 
 type Device struct {
-	address  address.Address
-	bindings map[string]string
+	address address.Address
+	irk     address.IRK
+	layers  map[string]*Layer
+	nodes   []*Node
+	axes    map[evdev.EvCode]Axis
+	analog  map[evdev.EvCode]AnalogAxis
+	timing  KeyTiming
+	rel     RelThresholds
+	grab    Grab
 }
 
+// isSpecificName reports whether a key name from the config is the full name of a key event,
+// rather than one that could be either a KEY_ or a BTN_.
 func isSpecificName(key string) bool {
-	return strings.HasPrefix(key, "KEY_") || strings.HasPrefix(key, "BTN_")
+	return strings.HasPrefix(key, "KEY_") || strings.HasPrefix(key, "BTN_") || isRelKeyName(key)
 }
 
-func (d *Device) BindingForKey(keyName string) string {
-	// key may be several names separated by /
-	keys := strings.SplitSeq(keyName, "/")
-	for key := range keys {
-		if val, ok := d.bindings[key]; ok {
-			return val
-		}
+// BindingForKey returns the base layer's binding for a key and trigger, or the zero Binding if there's none.
+func (d *Device) BindingForKey(keyName string, trigger Trigger) Binding {
+	return d.layers[BaseLayer].BindingForKey(keyName, trigger)
+}
+
+// bindingKey is the key in Layer.bindings for a key name and trigger.
+func bindingKey(key string, trigger Trigger) string {
+	key = strings.ToUpper(key)
+	if trigger == TriggerPress {
+		return key
 	}
-	return ""
+	return key + "." + string(trigger)
 }
 
-func newDevice(addr string, bindings map[string]string) (*Device, error) {
-	mac, err := address.NewFromString(addr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid device address %q: %w", addr, err)
+func newDevice(addr address.Address, irk address.IRK, layers map[string]*Layer, nodes []*Node, axes map[evdev.EvCode]Axis, analog map[evdev.EvCode]AnalogAxis, timing KeyTiming, rel RelThresholds, grab Grab) (*Device, error) {
+	if addr.IsZero() {
+		return nil, errors.New("device address is missing")
+	}
+	all := slices.Collect(maps.Values(layers))
+	for _, n := range nodes {
+		all = append(all, n.layer)
+	}
+	for _, l := range all {
+		for _, b := range l.allBindings() {
+			if b.Action != actions.LayerHold && b.Action != actions.LayerToggle {
+				continue
+			}
+			if name := b.Params.Str("layer"); name == BaseLayer || layers[name] == nil {
+				return nil, fmt.Errorf("layer %q: %s needs one of the device's layers, got %q", l.Name, b.Action, name)
+			}
+		}
+	}
+	for _, t := range []time.Duration{timing.LongPress, timing.RepeatDelay, timing.RepeatInterval, timing.SequenceTimeout} {
+		if t < 0 {
+			return nil, fmt.Errorf("key timings can't be negative, got %s", t)
+		}
+	}
+	if rel.Wheel <= 0 || rel.Mouse <= 0 {
+		return nil, fmt.Errorf("wheel and mouse thresholds must be more than 0, got %d and %d", rel.Wheel, rel.Mouse)
+	}
+	if err := grab.validate(layers); err != nil {
+		return nil, err
 	}
 	return &Device{
-		address:  mac,
-		bindings: bindings,
+		address: addr,
+		irk:     irk,
+		layers:  layers,
+		nodes:   nodes,
+		axes:    axes,
+		analog:  analog,
+		timing:  timing,
+		rel:     rel,
+		grab:    grab,
 	}, nil
 }
 
@@ -62,12 +178,65 @@ func (d *Device) Address() address.Address {
 	return d.address
 }
 
+// Combos returns the base layer's chord and sequence bindings.
+func (d *Device) Combos() []Combo {
+	return d.layers[BaseLayer].Combos()
+}
+
+// Layer returns the named layer, or nil if the device doesn't have it.
+func (d *Device) Layer(name string) *Layer {
+	return d.layers[name]
+}
+
+// NodeFor returns the first of the device's node configs that matches a node with the given
+// name and events (see Node.Matches), or nil if none do.
+func (d *Device) NodeFor(name string, capable func(evdev.EvType) []evdev.EvCode) *Node {
+	for _, n := range d.nodes {
+		if n.Matches(name, capable) {
+			return n
+		}
+	}
+	return nil
+}
+
+// Axes returns how the device's absolute axes are turned into key presses, by axis code.
+func (d *Device) Axes() map[evdev.EvCode]Axis {
+	return d.axes
+}
+
+// Analog returns the device's axis bindings, by axis code.
+func (d *Device) Analog() map[evdev.EvCode]AnalogAxis {
+	return d.analog
+}
+
+// Timing returns the device's thresholds for long-press, hold-to-repeat and sequence bindings.
+func (d *Device) Timing() KeyTiming {
+	return d.timing
+}
+
+// RelThresholds returns how far the device's wheels and mice have to move to press their keys.
+func (d *Device) RelThresholds() RelThresholds {
+	return d.rel
+}
+
+// Grab returns when the device's nodes are grabbed, and whether their unbound keys are passed on.
+func (d *Device) Grab() Grab {
+	return d.grab
+}
+
 func (d *Device) Dump() string {
-	return fmt.Sprintf("Device{address=%q, bindings=%v}", d.address.ToString(), d.bindings)
+	layers := make([]string, 0, len(d.layers))
+	for _, name := range slices.Sorted(maps.Keys(d.layers)) {
+		layers = append(layers, d.layers[name].String())
+	}
+	return fmt.Sprintf("Device{address=%q, layers=[%s], nodes=%v, timing=%+v, grab=%+v}", d.address, strings.Join(layers, " "), d.nodes, d.timing, d.grab)
 }
 
 type Config struct {
 	Devices []Device
+	// Resolver holds the IRKs of all configured devices, to match resolvable private addresses
+	// against their configured identity addresses.
+	Resolver *address.Resolver
 }
 
 func getConfigPath() string {
@@ -101,51 +270,98 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 	defer quietly.Close(file)
+	return yamlCfg.config()
+}
+
+// Parse reads a config from r, as Load does from the config file.
+func Parse(r io.Reader) (*Config, error) {
+	var yamlCfg yamlConfig
+	if err := yaml.NewDecoder(r).Decode(&yamlCfg); err != nil {
+		return nil, err
+	}
+	return yamlCfg.config()
+}
+
+// config checks the devices in the YAML and builds the Config for them.
+func (yamlCfg *yamlConfig) config() (*Config, error) {
 	devices := make([]Device, 0, len(yamlCfg.Devices))
 	for _, d := range yamlCfg.Devices {
-		bindings := make(map[string]string, len(d.Bind))
-		for k, v := range d.Bind {
-			if isSpecificName(k) {
-				// Keep the original name if it has a KEY_ prefix to disambiguate from BTN_.
-				err := addToBindings(bindings, k, v)
-				if err != nil {
-					return nil, fmt.Errorf("error in device %q binding for key %q: %w", d.Name, k, err)
-				}
-			} else {
-				err := addToBindings(bindings, "BTN_"+k, v)
-				if err != nil {
-					return nil, fmt.Errorf("error in device %q binding for key %q: %w", d.Name, "BTN_"+k, err)
-				}
-				err = addToBindings(bindings, "KEY_"+k, v)
-				if err != nil {
-					return nil, fmt.Errorf("error in device %q binding for key %q: %w", d.Name, "KEY_"+k, err)
-				}
+		base, err := parseLayer(BaseLayer, d.Bind)
+		if err != nil {
+			return nil, fmt.Errorf("error in device %q: %w", d.Name, err)
+		}
+		layers := map[string]*Layer{BaseLayer: base}
+		for name, bind := range d.Layers {
+			if name == BaseLayer {
+				return nil, fmt.Errorf("error in device %q: the %s layer's bindings go in bind, not layers", d.Name, BaseLayer)
 			}
+			layers[name], err = parseLayer(name, bind)
+			if err != nil {
+				return nil, fmt.Errorf("error in device %q: %w", d.Name, err)
+			}
+		}
+		nodes := make([]*Node, 0, len(d.Nodes))
+		for i, yn := range d.Nodes {
+			n, err := parseNode(i, yn)
+			if err != nil {
+				return nil, fmt.Errorf("error in device %q: %w", d.Name, err)
+			}
+			nodes = append(nodes, n)
 		}
-		dev, err := newDevice(d.Addr, bindings)
+		axes, analog, err := parseAxes(d.Axes)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("error in device %q: %w", d.Name, err)
+		}
+		timing := DefaultKeyTiming
+		if d.LongPress != 0 {
+			timing.LongPress = d.LongPress
+		}
+		if d.RepeatDelay != 0 {
+			timing.RepeatDelay = d.RepeatDelay
+		}
+		if d.RepeatInterval != 0 {
+			timing.RepeatInterval = d.RepeatInterval
+		}
+		if d.SequenceTimeout != 0 {
+			timing.SequenceTimeout = d.SequenceTimeout
+		}
+		rel := DefaultRelThresholds
+		if d.WheelThreshold != 0 {
+			rel.Wheel = d.WheelThreshold
+		}
+		if d.MouseThreshold != 0 {
+			rel.Mouse = d.MouseThreshold
+		}
+		grab := Grab{Always: d.Grab, Layers: d.GrabLayers, Passthrough: d.Passthrough}
+		dev, err := newDevice(d.Addr, d.IRK, layers, nodes, axes, analog, timing, rel, grab)
+		if err != nil {
+			return nil, fmt.Errorf("error in device %q: %w", d.Name, err)
 		}
 		devices = append(devices, *dev)
 	}
+	resolver := address.NewResolver()
+	for _, d := range devices {
+		if !d.irk.IsZero() {
+			resolver.Add(d.address, d.irk)
+		}
+	}
 	cfg := Config{
-		Devices: devices,
+		Devices:  devices,
+		Resolver: resolver,
 	}
 	return &cfg, nil
 }
 
-func addToBindings(bindings map[string]string, key string, action string) error {
-	key = strings.ToUpper(key)
-	if _, exists := bindings[key]; exists {
-		return fmt.Errorf("duplicate binding for key %q", key)
+// FirstMatchingDevice returns the config for the device with the given address.
+// The address may be an identity address, or a resolvable private address for a device with a known IRK.
+// random is the address type, if known; see address.Resolver.Resolve.
+func (c *Config) FirstMatchingDevice(addr address.Address, random bool) *Device {
+	identity, resolved := c.Resolver.Resolve(addr, random)
+	if resolved {
+		slog.Debug("resolved private address", "addr", addr, "identity", identity)
 	}
-	bindings[key] = action
-	return nil
-}
-
-func (c *Config) FirstMatchingDevice(addr address.Address) *Device {
 	for _, d := range c.Devices {
-		if addr == d.Address() {
+		if identity == d.Address() {
 			return &d
 		}
 	}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clintharrison/bueno/ace/address"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYAMLDeviceAddr(t *testing.T) {
+	t.Parallel()
+	want, err := address.NewFromString("e4:17:d8:0a:b0:0c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mac := range []string{"e4:17:d8:0a:b0:0c", "E4-17-D8-0A-B0-0C", "e417.d80a.b00c", "e417d80ab00c"} {
+		var cfg yamlConfig
+		err := yaml.Unmarshal([]byte("device:\n  - mac: "+mac+"\n"), &cfg)
+		if err != nil {
+			t.Errorf("mac %q: error = %v", mac, err)
+			continue
+		}
+		if got := cfg.Devices[0].Addr; got != want {
+			t.Errorf("mac %q = %v, want %v", mac, got, want)
+		}
+	}
+
+	var cfg yamlConfig
+	err = yaml.Unmarshal([]byte("device:\n  - mac: 7081.940dfbaa\n"), &cfg)
+	if err == nil || !strings.Contains(err.Error(), "invalid address format") {
+		t.Errorf("invalid mac: error = %v, want an invalid address error", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(configEnvVar, path)
+
+	err := os.WriteFile(path, []byte("device:\n  - name: pad\n    mac: e4:17:d8:0a:b0:0c\n    bind:\n      A: test_press\n      B.long: {action: test_step, delta: 2}\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	addr, _ := address.NewFromString("e4:17:d8:0a:b0:0c")
+	dev := cfg.FirstMatchingDevice(addr, false)
+	if dev == nil {
+		t.Fatalf("FirstMatchingDevice(%v) = nil", addr)
+	}
+	if got := dev.BindingForKey("KEY_A", TriggerPress); got.Action != "test_press" {
+		t.Errorf("A = %v, want test_press", got)
+	}
+	if got := dev.BindingForKey("BTN_B", TriggerLong); got.Params.Int("delta") != 2 {
+		t.Errorf("B.long = %v, want test_step with delta 2", got)
+	}
+
+	err = os.WriteFile(path, []byte("device:\n  - name: pad\n    bind:\n      A: test_press\n      B: nope\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load()
+	if want := `line 5, column 10: unknown action "nope"`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %v, want it to contain %s", err, want)
+	}
+}
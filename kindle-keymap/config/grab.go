@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Grab is whether a device's nodes are grabbed (with EVIOCGRAB), so their events only reach the
+// keymap, and not the X server or the Kindle framework as well. Otherwise a remote's KEY_ENTER or
+// KEY_VOLUMEUP can run its stock behavior as well as its binding.
+type Grab struct {
+	// Always grabs the nodes for as long as they're watched.
+	Always bool
+	// Layers grabs the nodes only while one of these layers is switched to.
+	Layers []string
+	// Passthrough sends the keys that aren't bound on to the system through a virtual uinput
+	// device while the nodes are grabbed, so only the bound keys are kept from it.
+	Passthrough bool
+}
+
+// Enabled reports whether the nodes are ever grabbed.
+func (g Grab) Enabled() bool {
+	return g.Always || len(g.Layers) > 0
+}
+
+// With reports whether the nodes are grabbed while the given layers are active.
+func (g Grab) With(layers []string) bool {
+	if g.Always {
+		return true
+	}
+	for _, l := range layers {
+		if slices.Contains(g.Layers, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g Grab) validate(layers map[string]*Layer) error {
+	for _, name := range g.Layers {
+		if name == BaseLayer {
+			return fmt.Errorf("grab_layers can't include the %s layer, use grab: true to always grab the device", BaseLayer)
+		}
+		if layers[name] == nil {
+			return fmt.Errorf("grab_layers needs the device's layers, got %q", name)
+		}
+	}
+	if g.Passthrough && !g.Enabled() {
+		return errors.New("passthrough needs grab or grab_layers, as the device's keys already reach the system otherwise")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import "testing"
+
+func TestGrabWith(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		grab    Grab
+		enabled bool
+		// with is whether the nodes are grabbed with no layers but the base, with nav, and with fn
+		with [3]bool
+	}{
+		{"off", Grab{}, false, [3]bool{false, false, false}},
+		{"always", Grab{Always: true}, true, [3]bool{true, true, true}},
+		{"layers", Grab{Layers: []string{"nav"}}, true, [3]bool{false, true, false}},
+		{"always and layers", Grab{Always: true, Layers: []string{"nav"}}, true, [3]bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		if got := tt.grab.Enabled(); got != tt.enabled {
+			t.Errorf("%s: Enabled() = %v, want %v", tt.name, got, tt.enabled)
+		}
+		for i, layers := range [][]string{{BaseLayer}, {"nav", BaseLayer}, {"fn", BaseLayer}} {
+			if got := tt.grab.With(layers); got != tt.with[i] {
+				t.Errorf("%s: With(%v) = %v, want %v", tt.name, layers, got, tt.with[i])
+			}
+		}
+	}
+}
+
+func TestGrabValidate(t *testing.T) {
+	t.Parallel()
+	layers := map[string]*Layer{BaseLayer: {Name: BaseLayer}, "nav": {Name: "nav"}}
+	tests := []struct {
+		name    string
+		grab    Grab
+		wantErr string
+	}{
+		{name: "off", grab: Grab{}},
+		{name: "always", grab: Grab{Always: true, Passthrough: true}},
+		{name: "layers", grab: Grab{Layers: []string{"nav"}, Passthrough: true}},
+		{
+			name:    "base layer",
+			grab:    Grab{Layers: []string{"nav", BaseLayer}},
+			wantErr: "grab_layers can't include the base layer, use grab: true to always grab the device",
+		},
+		{
+			name:    "unknown layer",
+			grab:    Grab{Layers: []string{"fn"}},
+			wantErr: `grab_layers needs the device's layers, got "fn"`,
+		},
+		{
+			name:    "passthrough without a grab",
+			grab:    Grab{Passthrough: true},
+			wantErr: "passthrough needs grab or grab_layers, as the device's keys already reach the system otherwise",
+		},
+	}
+	for _, tt := range tests {
+		err := tt.grab.validate(layers)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: validate() error = %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("%s: validate() error = %v, want %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// BaseLayer is the name of the layer with a device's bind section, which is always active.
+const BaseLayer = "base"
+
+// Layer is a named set of bindings for a device.
+type Layer struct {
+	Name     string
+	bindings map[string]Binding
+	combos   []Combo
+}
+
+// BindingForKey returns the layer's binding for a key and trigger, or the zero Binding if there's none.
+func (l *Layer) BindingForKey(keyName string, trigger Trigger) Binding {
+	// key may be several names separated by /
+	keys := strings.SplitSeq(keyName, "/")
+	for key := range keys {
+		if val, ok := l.bindings[bindingKey(key, trigger)]; ok {
+			return val
+		}
+	}
+	return Binding{}
+}
+
+// Binds reports whether the layer binds a key, for any trigger or as part of a chord or sequence.
+func (l *Layer) Binds(keyName string) bool {
+	for _, trigger := range []Trigger{TriggerPress, TriggerRelease, TriggerLong, TriggerHoldRepeat} {
+		if !l.BindingForKey(keyName, trigger).IsZero() {
+			return true
+		}
+	}
+	for _, c := range l.combos {
+		for _, step := range c.Steps {
+			if KeyMatches(step.Key, keyName) || slices.ContainsFunc(step.Modifiers, func(m string) bool { return KeyMatches(m, keyName) }) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Combos returns the layer's chord and sequence bindings.
+func (l *Layer) Combos() []Combo {
+	return l.combos
+}
+
+func (l *Layer) String() string {
+	return fmt.Sprintf("%s{bindings=%v, combos=%v}", l.Name, l.bindings, l.combos)
+}
+
+// allBindings returns all the layer's bindings, including those of its combos.
+func (l *Layer) allBindings() []Binding {
+	result := make([]Binding, 0, len(l.bindings)+len(l.combos))
+	for _, b := range l.bindings {
+		result = append(result, b)
+	}
+	for _, c := range l.combos {
+		result = append(result, c.Binding)
+	}
+	return result
+}
+
+// parseLayer parses the bindings of a layer from the config, keyed by key names with optional
+// triggers (like `A.long`), chords or sequences.
+func parseLayer(name string, bind map[string]Binding) (*Layer, error) {
+	l := &Layer{Name: name, bindings: make(map[string]Binding, len(bind))}
+	for k, v := range bind {
+		trigger := TriggerPress
+		if key, suffix, ok := strings.Cut(k, "."); ok {
+			var err error
+			trigger, err = parseTrigger(suffix)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q binding for key %q: %w", name, k, err)
+			}
+			k = key
+		}
+		if isCombo(k) {
+			if trigger != TriggerPress {
+				return nil, fmt.Errorf("layer %q binding for %q: chords and sequences can only be bound to a press", name, k)
+			}
+			combo, err := addToCombos(l.combos, k, v)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q binding for %q: %w", name, k, err)
+			}
+			l.combos = append(l.combos, combo)
+		} else if isSpecificName(k) {
+			// Keep the original name if it has a KEY_ prefix to disambiguate from BTN_.
+			err := addToBindings(l.bindings, bindingKey(k, trigger), v)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q binding for key %q: %w", name, k, err)
+			}
+		} else {
+			err := addToBindings(l.bindings, bindingKey("BTN_"+k, trigger), v)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q binding for key %q: %w", name, "BTN_"+k, err)
+			}
+			err = addToBindings(l.bindings, bindingKey("KEY_"+k, trigger), v)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q binding for key %q: %w", name, "KEY_"+k, err)
+			}
+		}
+	}
+	// the bindings come from a map, so sort them for stable logs
+	slices.SortFunc(l.combos, func(a, b Combo) int { return strings.Compare(a.String(), b.String()) })
+
+	for key := range l.bindings {
+		k, trigger, _ := strings.Cut(key, ".")
+		if Trigger(trigger) == TriggerLong {
+			if _, ok := l.bindings[bindingKey(k, TriggerHoldRepeat)]; ok {
+				return nil, fmt.Errorf("layer %q: key %q can't have both a long-press and a hold-to-repeat binding", name, k)
+			}
+		}
+	}
+	return l, nil
+}
+
+func addToBindings(bindings map[string]Binding, key string, action Binding) error {
+	if _, exists := bindings[key]; exists {
+		return fmt.Errorf("duplicate binding for key %q", key)
+	}
+	bindings[key] = action
+	return nil
+}
+
+// addToCombos parses a chord or sequence binding, checking that it isn't a duplicate of one in combos.
+func addToCombos(combos []Combo, key string, action Binding) (Combo, error) {
+	steps, err := parseCombo(key)
+	if err != nil {
+		return Combo{}, err
+	}
+	combo := Combo{Steps: steps, Binding: action}
+	for _, c := range combos {
+		if c.String() == combo.String() {
+			return Combo{}, fmt.Errorf("duplicate binding for %q", combo)
+		}
+	}
+	return combo, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// Node holds bindings for only some of a device's evdev nodes. Many remotes have separate
+// nodes for their keyboard keys, their consumer control keys (like KEY_VOLUMEUP) and a mouse,
+// which are all watched as one device; a Node can bind a key differently on one of them.
+// Its bindings come after any layer switched to, but before the base layer's.
+type Node struct {
+	// Name, if set, has to be part of the node's name, ignoring case.
+	Name string
+	// Has are the events the node has to support, if any, like KEY_VOLUMEUP or REL_WHEEL.
+	Has   []string
+	has   []nodeEvent
+	layer *Layer
+}
+
+type nodeEvent struct {
+	typ  evdev.EvType
+	code evdev.EvCode
+}
+
+// yamlNode is a node in the config, e.g.
+//
+//	nodes:
+//	  - name: Consumer Control
+//	    bind: {VOLUMEUP: next_page}
+//	  - has: [REL_WHEEL]
+//	    bind: {BTN_LEFT: next_page}
+type yamlNode struct {
+	Name string             `yaml:"name,omitempty"`
+	Has  []string           `yaml:"has,omitempty"`
+	Bind map[string]Binding `yaml:"bind"`
+}
+
+func parseNode(i int, y yamlNode) (*Node, error) {
+	name := fmt.Sprintf("nodes[%d]", i)
+	if y.Name == "" && len(y.Has) == 0 {
+		return nil, fmt.Errorf("%s needs a name or events to match nodes by", name)
+	}
+	n := &Node{Name: y.Name, Has: y.Has}
+	for _, event := range y.Has {
+		e, err := parseNodeEvent(event)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		n.has = append(n.has, e)
+	}
+	layer, err := parseLayer(name, y.Bind)
+	if err != nil {
+		return nil, err
+	}
+	if len(layer.combos) > 0 {
+		return nil, fmt.Errorf("%s: chords and sequences can't be bound to a single node, bind them for the whole device", name)
+	}
+	n.layer = layer
+	return n, nil
+}
+
+func parseNodeEvent(name string) (nodeEvent, error) {
+	name = strings.ToUpper(name)
+	if code, ok := evdev.KEYFromString[name]; ok {
+		return nodeEvent{evdev.EV_KEY, code}, nil
+	}
+	if code, ok := evdev.RELFromString[name]; ok {
+		return nodeEvent{evdev.EV_REL, code}, nil
+	}
+	if code, ok := evdev.ABSFromString[name]; ok {
+		return nodeEvent{evdev.EV_ABS, code}, nil
+	}
+	return nodeEvent{}, errors.New("unknown event " + name + ", expected e.g. KEY_VOLUMEUP, REL_WHEEL or ABS_X")
+}
+
+// Matches reports whether the node config applies to a node with the given name, and
+// supporting the events capable returns for each event type.
+func (n *Node) Matches(name string, capable func(evdev.EvType) []evdev.EvCode) bool {
+	if n.Name != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(n.Name)) {
+		return false
+	}
+	for _, e := range n.has {
+		if !slices.Contains(capable(e.typ), e.code) {
+			return false
+		}
+	}
+	return true
+}
+
+// BindingForKey returns the node's binding for a key and trigger, or the zero Binding if there's none.
+func (n *Node) BindingForKey(keyName string, trigger Trigger) Binding {
+	return n.layer.BindingForKey(keyName, trigger)
+}
+
+// Binds reports whether the node binds a key, for any trigger.
+func (n *Node) Binds(keyName string) bool {
+	return n.layer.Binds(keyName)
+}
+
+func (n *Node) String() string {
+	return fmt.Sprintf("{name=%q, has=%v, bindings=%v}", n.Name, n.Has, n.layer.bindings)
+}
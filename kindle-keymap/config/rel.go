@@ -0,0 +1,75 @@
+package config
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// Relative axes (EV_REL), like wheels and mice, are bound like keys: each direction has a key
+// name, which is pressed and released every time the axis moves far enough that way.
+//
+//	WHEEL_UP: prev_page
+//	WHEEL_DOWN: next_page
+const (
+	WheelUp     = "WHEEL_UP"
+	WheelDown   = "WHEEL_DOWN"
+	HWheelLeft  = "HWHEEL_LEFT"
+	HWheelRight = "HWHEEL_RIGHT"
+	MouseLeft   = "MOUSE_LEFT"
+	MouseRight  = "MOUSE_RIGHT"
+	MouseUp     = "MOUSE_UP"
+	MouseDown   = "MOUSE_DOWN"
+)
+
+// RelKey is the key name for one direction of a relative axis.
+type RelKey struct {
+	// Code is the axis. The high-resolution wheel axes use the key names of the plain ones.
+	Code evdev.EvCode
+	// Positive is the direction: towards positive values (like REL_WHEEL's up) or negative ones.
+	Positive bool
+	Name     string
+}
+
+// RelKeys are the key names for the relative axes that can be bound.
+var RelKeys = []RelKey{
+	{evdev.REL_WHEEL, true, WheelUp},
+	{evdev.REL_WHEEL, false, WheelDown},
+	{evdev.REL_HWHEEL, true, HWheelRight},
+	{evdev.REL_HWHEEL, false, HWheelLeft},
+	{evdev.REL_X, true, MouseRight},
+	{evdev.REL_X, false, MouseLeft},
+	{evdev.REL_Y, true, MouseDown},
+	{evdev.REL_Y, false, MouseUp},
+}
+
+// RelKeyName returns the key name for a direction of a relative axis, if it can be bound.
+func RelKeyName(code evdev.EvCode, positive bool) (string, bool) {
+	i := slices.IndexFunc(RelKeys, func(k RelKey) bool { return k.Code == code && k.Positive == positive })
+	if i == -1 {
+		return "", false
+	}
+	return RelKeys[i].Name, true
+}
+
+func isRelKeyName(name string) bool {
+	name = strings.ToUpper(name)
+	return slices.ContainsFunc(RelKeys, func(k RelKey) bool { return k.Name == name })
+}
+
+// RelThresholds are how far a device's relative axes have to move to press their keys once.
+type RelThresholds struct {
+	// Wheel is in REL_WHEEL_HI_RES units, of which a notch of the wheel is 120: 60 presses twice
+	// per notch, and on wheels with high-resolution scrolling, also halfway through one.
+	Wheel int32
+	// Mouse is in the mouse's own units.
+	Mouse int32
+}
+
+// DefaultRelThresholds is used for any thresholds a device's config leaves out: a key press
+// per notch of a wheel.
+var DefaultRelThresholds = RelThresholds{
+	Wheel: 120,
+	Mouse: 50,
+}
@@ -28,7 +28,9 @@ run_pairing() {
 case "$1" in
 check-status)
 	if pgrep "kindle-keymap" >/dev/null; then
-		show_eink_log "kindle-keymap is running      "
+		# the full status of each configured device is in `lipc-get-prop com.bueno.keymap deviceStatus`
+		devices=$(lipc-get-prop com.bueno.keymap connectedDevices 2>/dev/null)
+		show_eink_log "kindle-keymap is running, connected: ${devices:-none}"
 	else
 		show_eink_log "kindle-keymap is not running  "
 	fi
@@ -4,90 +4,127 @@ package lipcaction
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 
 	"github.com/clintharrison/bueno/lipc"
-	"github.com/godbus/dbus/v5"
+	"github.com/clintharrison/bueno/lipc/services/powerd"
+	"github.com/clintharrison/bueno/lipc/services/winmgr"
 )
 
+// LipcClient is the LIPC connection shared by the actions. It reconnects if the bus goes away,
+// e.g. when the framework restarts.
 type LipcClient struct {
-	conn *dbus.Conn
+	client *lipc.Client
 }
 
 func NewLipcClient() (*LipcClient, error) {
-	conn, err := dbus.SystemBus()
-	if err != nil {
-		return nil, err
-	}
-	return &LipcClient{conn: conn}, nil
+	return NewLipcClientWithConnector(nil), nil
+}
+
+// NewLipcClientWithConnector makes a client that connects with connect instead of to the system bus,
+// e.g. a lipctest.Bus with fake services on it.
+func NewLipcClientWithConnector(connect lipc.Connector) *LipcClient {
+	client := lipc.NewClient(connect, lipc.ClientOptions{})
+	// the maximum only depends on the model, so it's only fetched again after a reconnect
+	client.Cache(powerd.Service, powerd.PropFlMaxIntensity)
+	return &LipcClient{client: client}
+}
+
+// Caller returns the client for making LIPC calls, e.g. for the actions.Env.
+func (c *LipcClient) Caller() lipc.Caller {
+	return c.client
 }
 
 func (c *LipcClient) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
-	}
-	return nil
+	return c.client.Close()
 }
 
 type BrightnessAction struct {
-	client       *LipcClient
-	maxIntensity int32
+	powerd *powerd.Client
 }
 
 func NewBrightnessAction(client *LipcClient) *BrightnessAction {
-	ba := &BrightnessAction{client: client}
-	return ba
+	return &BrightnessAction{powerd: powerd.NewWithCaller(client.client)}
 }
 
+// InitRanges fetches the brightness range ahead of the first key press.
 func (a *BrightnessAction) InitRanges(ctx context.Context) error {
-	val, err := lipc.GetProperty[int32](ctx, a.client.conn, "com.lab126.powerd", "flMaxIntensity")
-	if err != nil {
-		return err
-	}
-	a.maxIntensity = val
-	return nil
+	_, err := a.powerd.FlMaxIntensity(ctx)
+	return err
 }
 
 func (a *BrightnessAction) DecreaseBrightness(ctx context.Context) error {
-	return a.adjust(ctx, "flIntensity", -1)
+	return a.AdjustBrightness(ctx, -1)
 }
 
 func (a *BrightnessAction) IncreaseBrightness(ctx context.Context) error {
-	return a.adjust(ctx, "flIntensity", 1)
+	return a.AdjustBrightness(ctx, 1)
+}
+
+// AdjustBrightness changes the frontlight brightness by delta, staying within its range.
+func (a *BrightnessAction) AdjustBrightness(ctx context.Context, delta int32) error {
+	return a.adjust(ctx, powerd.PropFlIntensity, a.powerd.FlIntensity, a.powerd.SetFlIntensity, delta)
 }
 
 func (a *BrightnessAction) DecreaseWarmth(ctx context.Context) error {
-	return a.adjust(ctx, "currentAmberLevel", -1)
+	return a.AdjustWarmth(ctx, -1)
 }
 
 func (a *BrightnessAction) IncreaseWarmth(ctx context.Context) error {
-	return a.adjust(ctx, "currentAmberLevel", 1)
+	return a.AdjustWarmth(ctx, 1)
 }
 
-func (a *BrightnessAction) adjust(ctx context.Context, prop string, delta int32) error {
-	if a.maxIntensity == 0 {
-		err := a.InitRanges(ctx)
-		if err != nil {
-			slog.Error("InitRanges()", "error", err)
-			return err
-		}
+// AdjustWarmth changes the warm light level by delta, staying within its range.
+func (a *BrightnessAction) AdjustWarmth(ctx context.Context, delta int32) error {
+	return a.adjust(ctx, powerd.PropCurrentAmberLevel, a.powerd.CurrentAmberLevel, a.powerd.SetCurrentAmberLevel, delta)
+}
+
+// SetBrightness sets the frontlight brightness, limited to its range.
+func (a *BrightnessAction) SetBrightness(ctx context.Context, level int32) error {
+	return a.set(ctx, powerd.PropFlIntensity, a.powerd.SetFlIntensity, level)
+}
+
+// SetWarmth sets the warm light level, limited to its range.
+func (a *BrightnessAction) SetWarmth(ctx context.Context, level int32) error {
+	return a.set(ctx, powerd.PropCurrentAmberLevel, a.powerd.SetCurrentAmberLevel, level)
+}
+
+func (a *BrightnessAction) adjust(ctx context.Context, prop string, get func(context.Context) (int32, error), set func(context.Context, int32) error, delta int32) error {
+	maxIntensity, err := a.powerd.FlMaxIntensity(ctx)
+	if err != nil {
+		slog.Error("FlMaxIntensity()", "error", err)
+		return err
+	}
+	curr, err := get(ctx)
+	if errors.Is(err, lipc.ErrNoSuchProperty) {
+		// e.g. currentAmberLevel on a Kindle without a warm light: retrying won't help
+		return fmt.Errorf("%s is not supported on this device: %w", prop, err)
+	} else if err != nil {
+		return err
 	}
-	curr, err := lipc.GetProperty[int32](ctx, a.client.conn, "com.lab126.powerd", prop)
+	newVal := max(0, min(curr+delta, maxIntensity))
+	slog.Debug("adjust()", "prop", prop, "curr", curr, "delta", delta, "new", newVal, "max", maxIntensity)
+	return set(ctx, newVal)
+}
+
+func (a *BrightnessAction) set(ctx context.Context, prop string, set func(context.Context, int32) error, level int32) error {
+	maxIntensity, err := a.powerd.FlMaxIntensity(ctx)
 	if err != nil {
+		slog.Error("FlMaxIntensity()", "error", err)
 		return err
 	}
-	newVal := curr + delta
-	if newVal < 0 {
-		newVal = 0
-	} else if newVal > a.maxIntensity {
-		newVal = a.maxIntensity
+	newVal := max(0, min(level, maxIntensity))
+	slog.Debug("set()", "prop", prop, "level", level, "new", newVal, "max", maxIntensity)
+	err = set(ctx, newVal)
+	if errors.Is(err, lipc.ErrNoSuchProperty) {
+		return fmt.Errorf("%s is not supported on this device: %w", prop, err)
 	}
-	slog.Debug("adjust()", "prop", prop, "curr", curr, "delta", delta, "new", newVal, "max", a.maxIntensity)
-	return lipc.SetProperty(ctx, a.client.conn, "com.lab126.powerd", prop, newVal)
+	return err
 }
 
 type RotationAction struct {
-	client *LipcClient
+	winmgr *winmgr.Client
 }
 
 type Orientation string
@@ -101,7 +138,7 @@ const (
 )
 
 func NewRotationAction(client *LipcClient) *RotationAction {
-	return &RotationAction{client: client}
+	return &RotationAction{winmgr: winmgr.NewWithCaller(client.client)}
 }
 
 func orientationFromString(s string) (Orientation, error) {
@@ -122,7 +159,7 @@ func orientationFromString(s string) (Orientation, error) {
 }
 
 func (a *RotationAction) GetOrientationLock(ctx context.Context) (Orientation, error) {
-	o, err := lipc.GetProperty[string](ctx, a.client.conn, "com.lab126.winmgr", "orientationLock")
+	o, err := a.winmgr.OrientationLock(ctx)
 	if err != nil {
 		return OrientationUnlocked, err
 	}
@@ -134,8 +171,7 @@ func (a *RotationAction) GetOrientationLock(ctx context.Context) (Orientation, e
 }
 
 func (a *RotationAction) SetOrientationLock(ctx context.Context, o Orientation) error {
-	oo := string(o)
-	return lipc.SetProperty(ctx, a.client.conn, "com.lab126.winmgr", "orientationLock", oo)
+	return a.winmgr.SetOrientationLock(ctx, string(o))
 }
 
 type RotationDirection bool
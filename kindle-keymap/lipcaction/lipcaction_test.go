@@ -0,0 +1,183 @@
+package lipcaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/clintharrison/bueno/lipc/lipctest"
+	"github.com/clintharrison/bueno/lipc/services/powerd"
+	"github.com/clintharrison/bueno/lipc/services/winmgr"
+)
+
+// newBus starts a private bus, skipping the test if dbus-daemon isn't installed.
+func newBus(t *testing.T) *lipctest.Bus {
+	t.Helper()
+	bus, err := lipctest.NewBus()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = bus.Close() })
+	return bus
+}
+
+func newClient(t *testing.T, bus *lipctest.Bus) *LipcClient {
+	t.Helper()
+	client := NewLipcClientWithConnector(bus.Connect)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func newPowerd(t *testing.T, bus *lipctest.Bus) *lipctest.Service {
+	t.Helper()
+	fake, err := lipctest.NewPowerd(bus.Connect)
+	if err != nil {
+		t.Fatalf("NewPowerd() error = %v", err)
+	}
+	t.Cleanup(func() { _ = fake.Close() })
+	return fake
+}
+
+func TestBrightnessActionClamps(t *testing.T) {
+	t.Parallel()
+	bus := newBus(t)
+	fake := newPowerd(t, bus)
+	a := NewBrightnessAction(newClient(t, bus))
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		prop  string
+		start int32
+		do    func(context.Context) error
+		want  int32
+	}{
+		{"brightness up", powerd.PropFlIntensity, 10, a.IncreaseBrightness, 11},
+		{"brightness down", powerd.PropFlIntensity, 10, a.DecreaseBrightness, 9},
+		{"brightness up at max", powerd.PropFlIntensity, 24, a.IncreaseBrightness, 24},
+		{"brightness down at 0", powerd.PropFlIntensity, 0, a.DecreaseBrightness, 0},
+		{"brightness past max", powerd.PropFlIntensity, 20, func(ctx context.Context) error { return a.AdjustBrightness(ctx, 10) }, 24},
+		{"brightness past 0", powerd.PropFlIntensity, 3, func(ctx context.Context) error { return a.AdjustBrightness(ctx, -10) }, 0},
+		{"set brightness above max", powerd.PropFlIntensity, 10, func(ctx context.Context) error { return a.SetBrightness(ctx, 100) }, 24},
+		{"set brightness below 0", powerd.PropFlIntensity, 10, func(ctx context.Context) error { return a.SetBrightness(ctx, -1) }, 0},
+		{"warmth up", powerd.PropCurrentAmberLevel, 0, a.IncreaseWarmth, 1},
+		{"warmth up at max", powerd.PropCurrentAmberLevel, 24, a.IncreaseWarmth, 24},
+		{"warmth down at 0", powerd.PropCurrentAmberLevel, 0, a.DecreaseWarmth, 0},
+		{"set warmth above max", powerd.PropCurrentAmberLevel, 0, func(ctx context.Context) error { return a.SetWarmth(ctx, 25) }, 24},
+		{"set warmth below 0", powerd.PropCurrentAmberLevel, 5, func(ctx context.Context) error { return a.SetWarmth(ctx, -5) }, 0},
+	}
+	// the subtests share the fake, so they run in order
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.SetInt(tt.prop, tt.start)
+			if err := tt.do(ctx); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if got := fake.Int(tt.prop); got != tt.want {
+				t.Errorf("%s = %d, want %d", tt.prop, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrightnessActionWithoutWarmLight(t *testing.T) {
+	t.Parallel()
+	bus := newBus(t)
+	fake := newPowerd(t, bus)
+	fake.Remove(powerd.PropCurrentAmberLevel)
+	a := NewBrightnessAction(newClient(t, bus))
+
+	err := a.IncreaseWarmth(context.Background())
+	if !errors.Is(err, lipc.ErrNoSuchProperty) {
+		t.Errorf("IncreaseWarmth() error = %v, want %v", err, lipc.ErrNoSuchProperty)
+	}
+	err = a.SetWarmth(context.Background(), 3)
+	if !errors.Is(err, lipc.ErrNoSuchProperty) {
+		t.Errorf("SetWarmth() error = %v, want %v", err, lipc.ErrNoSuchProperty)
+	}
+}
+
+func TestActionsWithoutService(t *testing.T) {
+	t.Parallel()
+	bus := newBus(t)
+	client := newClient(t, bus)
+	ctx := context.Background()
+
+	err := NewBrightnessAction(client).IncreaseBrightness(ctx)
+	if !errors.Is(err, lipc.ErrNoSuchSource) {
+		t.Errorf("IncreaseBrightness() error = %v, want %v", err, lipc.ErrNoSuchSource)
+	}
+	err = NewRotationAction(client).Rotate(ctx, RotationClockwise)
+	if !errors.Is(err, lipc.ErrNoSuchSource) {
+		t.Errorf("Rotate() error = %v, want %v", err, lipc.ErrNoSuchSource)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	t.Parallel()
+	bus := newBus(t)
+	fake, err := lipctest.NewWinmgr(bus.Connect)
+	if err != nil {
+		t.Fatalf("NewWinmgr() error = %v", err)
+	}
+	t.Cleanup(func() { _ = fake.Close() })
+	a := NewRotationAction(newClient(t, bus))
+
+	tests := []struct {
+		from      Orientation
+		direction RotationDirection
+		want      Orientation
+	}{
+		{OrientationUnlocked, RotationClockwise, OrientationLandscapeRight},
+		{OrientationPortrait, RotationClockwise, OrientationLandscapeRight},
+		{OrientationLandscapeRight, RotationClockwise, OrientationPortraitInverted},
+		{OrientationPortraitInverted, RotationClockwise, OrientationLandscapeLeft},
+		{OrientationLandscapeLeft, RotationClockwise, OrientationPortrait},
+		{OrientationUnlocked, RotationCounterclockwise, OrientationLandscapeLeft},
+		{OrientationPortrait, RotationCounterclockwise, OrientationLandscapeLeft},
+		{OrientationLandscapeLeft, RotationCounterclockwise, OrientationPortraitInverted},
+		{OrientationPortraitInverted, RotationCounterclockwise, OrientationLandscapeRight},
+		{OrientationLandscapeRight, RotationCounterclockwise, OrientationPortrait},
+	}
+	for _, tt := range tests {
+		fake.SetStr(winmgr.PropOrientationLock, string(tt.from))
+		if err := a.Rotate(context.Background(), tt.direction); err != nil {
+			t.Fatalf("Rotate(%v) from %q error = %v", tt.direction, tt.from, err)
+		}
+		if got := Orientation(fake.Str(winmgr.PropOrientationLock)); got != tt.want {
+			t.Errorf("Rotate(%v) from %q = %q, want %q", tt.direction, tt.from, got, tt.want)
+		}
+	}
+
+	fake.SetStr(winmgr.PropOrientationLock, "X")
+	if err := a.Rotate(context.Background(), RotationClockwise); err == nil {
+		t.Error("Rotate() from an unknown orientation succeeded, want an error")
+	}
+}
+
+func TestPropActionSetsQuotedValuesAsStrings(t *testing.T) {
+	t.Parallel()
+	bus := newBus(t)
+	fake, err := lipctest.NewService("com.bueno.test", bus.Connect)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	t.Cleanup(func() { _ = fake.Close() })
+	if err := fake.AddStr("name", "", true); err != nil {
+		t.Fatalf("AddStr() error = %v", err)
+	}
+	a := NewPropAction(newClient(t, bus))
+
+	spec, err := actions.ParsePropSpec(actions.SetProp, `com.bueno.test/name="42"`)
+	if err != nil {
+		t.Fatalf("ParsePropSpec() error = %v", err)
+	}
+	if err := a.Run(context.Background(), spec); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := fake.Str("name"); got != "42" {
+		t.Errorf("name = %q, want %q", got, "42")
+	}
+}
@@ -0,0 +1,113 @@
+package lipcaction
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/clintharrison/bueno/lipc"
+)
+
+// PropAction runs the generic set_prop, inc_prop and toggle_prop actions, so bindings can use
+// any LIPC property without a dedicated action.
+type PropAction struct {
+	caller lipc.Caller
+}
+
+func NewPropAction(client *LipcClient) *PropAction {
+	return &PropAction{caller: client.client}
+}
+
+func (a *PropAction) Run(ctx context.Context, spec actions.PropSpec) error {
+	switch spec.Kind {
+	case actions.SetProp:
+		return a.set(ctx, spec, spec.Value)
+	case actions.IncProp:
+		return a.inc(ctx, spec)
+	case actions.ToggleProp:
+		return a.toggle(ctx, spec)
+	}
+	return fmt.Errorf("unknown property action %q", spec.Kind)
+}
+
+func (a *PropAction) set(ctx context.Context, spec actions.PropSpec, v actions.PropValue) error {
+	slog.Debug("setting property", "service", spec.Service, "property", spec.Property, "value", v)
+	if v.IsInt {
+		return a.caller.SetInt(ctx, spec.Service, spec.Property, v.Int)
+	}
+	return a.caller.SetStr(ctx, spec.Service, spec.Property, v.Str)
+}
+
+func (a *PropAction) bound(ctx context.Context, spec actions.PropSpec, b *actions.PropBound) (int32, error) {
+	if b.Property == "" {
+		return b.Value, nil
+	}
+	return a.caller.GetInt(ctx, spec.Service, b.Property)
+}
+
+func (a *PropAction) inc(ctx context.Context, spec actions.PropSpec) error {
+	curr, err := a.caller.GetInt(ctx, spec.Service, spec.Property)
+	if err != nil {
+		return err
+	}
+	newVal := curr + spec.Delta
+	if spec.Min != nil {
+		lo, err := a.bound(ctx, spec, spec.Min)
+		if err != nil {
+			return fmt.Errorf("failed to get minimum: %w", err)
+		}
+		newVal = max(newVal, lo)
+	}
+	if spec.Max != nil {
+		hi, err := a.bound(ctx, spec, spec.Max)
+		if err != nil {
+			return fmt.Errorf("failed to get maximum: %w", err)
+		}
+		newVal = min(newVal, hi)
+	}
+	if newVal == curr {
+		return nil
+	}
+	return a.set(ctx, spec, actions.PropValue{Int: newVal, IsInt: true})
+}
+
+func (a *PropAction) toggle(ctx context.Context, spec actions.PropSpec) error {
+	if len(spec.Values) == 0 {
+		curr, err := a.caller.GetInt(ctx, spec.Service, spec.Property)
+		if err != nil {
+			return err
+		}
+		next := int32(1)
+		if curr != 0 {
+			next = 0
+		}
+		return a.set(ctx, spec, actions.PropValue{Int: next, IsInt: true})
+	}
+
+	// if all the values are ints, so is the property; otherwise they're all treated as strings
+	allInts := !slices.ContainsFunc(spec.Values, func(v actions.PropValue) bool { return !v.IsInt })
+	var curr actions.PropValue
+	if allInts {
+		v, err := a.caller.GetInt(ctx, spec.Service, spec.Property)
+		if err != nil {
+			return err
+		}
+		curr = actions.PropValue{Int: v, IsInt: true}
+	} else {
+		v, err := a.caller.GetStr(ctx, spec.Service, spec.Property)
+		if err != nil {
+			return err
+		}
+		curr = actions.PropValue{Str: v}
+	}
+
+	// move on to the value after the current one, or start from the first if it's none of them
+	i := slices.IndexFunc(spec.Values, func(v actions.PropValue) bool { return v.String() == curr.String() })
+	next := spec.Values[(i+1)%len(spec.Values)]
+	if !allInts {
+		next = actions.PropValue{Str: next.String()}
+	}
+	return a.set(ctx, spec, next)
+}
@@ -0,0 +1,193 @@
+package lipcaction
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/clintharrison/bueno/lipc/services/powerd"
+	"github.com/clintharrison/bueno/lipc/services/winmgr"
+)
+
+// The actions are registered once, but run against the LIPC caller from the latest actions.Init,
+// since the key map makes a new one whenever it restarts.
+var (
+	mu         sync.Mutex
+	brightness *BrightnessAction
+	rotation   *RotationAction
+	props      *PropAction
+)
+
+var errNotInitialized = errors.New("LIPC actions are not initialized")
+
+func init() {
+	actions.OnInit(func(env *actions.Env) error {
+		if env.LIPC == nil {
+			return errors.New("LIPC actions need an LIPC caller")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		brightness = &BrightnessAction{powerd: powerd.NewWithCaller(env.LIPC)}
+		rotation = &RotationAction{winmgr: winmgr.NewWithCaller(env.LIPC)}
+		props = &PropAction{caller: env.LIPC}
+		return nil
+	})
+
+	deltaParam := actions.Param{Name: "delta", Type: actions.IntParam, Default: int32(1), Doc: "how much to change it by, e.g. 3 or -2"}
+	registerBrightness(actions.Schema{
+		Name:   actions.Brightness,
+		Doc:    "Change the frontlight brightness.",
+		Params: []actions.Param{deltaParam},
+	}, func(ctx context.Context, a *BrightnessAction, params actions.Params) error {
+		return a.AdjustBrightness(ctx, params.Int("delta"))
+	})
+	registerBrightness(actions.Schema{
+		Name:   actions.Warmth,
+		Doc:    "Change the warm light level.",
+		Params: []actions.Param{deltaParam},
+	}, func(ctx context.Context, a *BrightnessAction, params actions.Params) error {
+		return a.AdjustWarmth(ctx, params.Int("delta"))
+	})
+	levelParam := actions.Param{Name: "level", Type: actions.IntParam, Doc: "the level to set, from 0 up to the frontlight's maximum"}
+	registerBrightness(actions.Schema{
+		Name:      actions.SetBrightness,
+		Doc:       "Set the frontlight brightness, e.g. from an axis binding.",
+		Params:    []actions.Param{levelParam},
+		Shorthand: "level",
+	}, func(ctx context.Context, a *BrightnessAction, params actions.Params) error {
+		return a.SetBrightness(ctx, params.Int("level"))
+	})
+	registerBrightness(actions.Schema{
+		Name:      actions.SetWarmth,
+		Doc:       "Set the warm light level, e.g. from an axis binding.",
+		Params:    []actions.Param{levelParam},
+		Shorthand: "level",
+	}, func(ctx context.Context, a *BrightnessAction, params actions.Params) error {
+		return a.SetWarmth(ctx, params.Int("level"))
+	})
+	registerBrightness(actions.Schema{Name: actions.BrightnessUp, Doc: "Increase the frontlight brightness by one."},
+		func(ctx context.Context, a *BrightnessAction, _ actions.Params) error {
+			return a.IncreaseBrightness(ctx)
+		})
+	registerBrightness(actions.Schema{Name: actions.BrightnessDown, Doc: "Decrease the frontlight brightness by one."},
+		func(ctx context.Context, a *BrightnessAction, _ actions.Params) error {
+			return a.DecreaseBrightness(ctx)
+		})
+	registerBrightness(actions.Schema{Name: actions.WarmthUp, Doc: "Increase the warm light level by one."},
+		func(ctx context.Context, a *BrightnessAction, _ actions.Params) error { return a.IncreaseWarmth(ctx) })
+	registerBrightness(actions.Schema{Name: actions.WarmthDown, Doc: "Decrease the warm light level by one."},
+		func(ctx context.Context, a *BrightnessAction, _ actions.Params) error { return a.DecreaseWarmth(ctx) })
+
+	registerRotation(actions.Schema{
+		Name: actions.Rotate,
+		Doc:  "Rotate the screen a quarter turn.",
+		Params: []actions.Param{{
+			Name:    "direction",
+			Type:    actions.StringParam,
+			Default: actions.Clockwise,
+			Choices: []string{actions.Clockwise, actions.Counterclockwise},
+			Doc:     "cw for clockwise, ccw for counterclockwise",
+		}},
+	}, func(ctx context.Context, a *RotationAction, params actions.Params) error {
+		direction := RotationClockwise
+		if params.Str("direction") == actions.Counterclockwise {
+			direction = RotationCounterclockwise
+		}
+		return a.Rotate(ctx, direction)
+	})
+	registerRotation(actions.Schema{Name: actions.RotateCW, Doc: "Rotate the screen a quarter turn clockwise."},
+		func(ctx context.Context, a *RotationAction, _ actions.Params) error {
+			return a.Rotate(ctx, RotationClockwise)
+		})
+	registerRotation(actions.Schema{Name: actions.RotateCCW, Doc: "Rotate the screen a quarter turn counterclockwise."},
+		func(ctx context.Context, a *RotationAction, _ actions.Params) error {
+			return a.Rotate(ctx, RotationCounterclockwise)
+		})
+	registerRotation(actions.Schema{
+		Name: actions.OrientLock,
+		Doc:  "Lock the screen orientation.",
+		Params: []actions.Param{{
+			Name:    "value",
+			Type:    actions.StringParam,
+			Choices: []string{string(OrientationPortrait), string(OrientationPortraitInverted), string(OrientationLandscapeLeft), string(OrientationLandscapeRight)},
+			Doc:     "U, D, L or R for portrait, inverted portrait, landscape left or landscape right",
+		}},
+	}, func(ctx context.Context, a *RotationAction, params actions.Params) error {
+		return a.SetOrientationLock(ctx, Orientation(params.Str("value")))
+	})
+	for name, o := range map[string]Orientation{
+		actions.OrientLockUp:    OrientationPortrait,
+		actions.OrientLockDown:  OrientationPortraitInverted,
+		actions.OrientLockLeft:  OrientationLandscapeLeft,
+		actions.OrientLockRight: OrientationLandscapeRight,
+	} {
+		registerRotation(actions.Schema{Name: name, Doc: "Lock the screen orientation to " + string(o) + "."},
+			func(ctx context.Context, a *RotationAction, _ actions.Params) error {
+				return a.SetOrientationLock(ctx, o)
+			})
+	}
+
+	for kind, doc := range map[string]string{
+		actions.SetProp:    "Set an LIPC property, e.g. set_prop:com.lab126.powerd/flIntensity=0. Quoted values (=\"42\") are always strings.",
+		actions.IncProp:    "Add to an LIPC int property, e.g. inc_prop:com.lab126.powerd/flIntensity:+2:max=flMaxIntensity.",
+		actions.ToggleProp: "Toggle an LIPC property between 0 and 1, or cycle it through values, e.g. toggle_prop:com.lab126.winmgr/orientationLock=U,L.",
+	} {
+		registerProp(kind, doc)
+	}
+}
+
+func registerBrightness(schema actions.Schema, run func(ctx context.Context, a *BrightnessAction, params actions.Params) error) {
+	actions.Register(actions.Func(schema, func(ctx context.Context, params actions.Params) error {
+		mu.Lock()
+		a := brightness
+		mu.Unlock()
+		if a == nil {
+			return errNotInitialized
+		}
+		return run(ctx, a, params)
+	}))
+}
+
+func registerRotation(schema actions.Schema, run func(ctx context.Context, a *RotationAction, params actions.Params) error) {
+	actions.Register(actions.Func(schema, func(ctx context.Context, params actions.Params) error {
+		mu.Lock()
+		a := rotation
+		mu.Unlock()
+		if a == nil {
+			return errNotInitialized
+		}
+		return run(ctx, a, params)
+	}))
+}
+
+// registerProp registers a generic property action, which takes its target right after its name.
+func registerProp(kind, doc string) {
+	schema := actions.Schema{
+		Name: kind,
+		Doc:  doc,
+		Params: []actions.Param{{
+			Name: "target",
+			Type: actions.StringParam,
+			Check: func(v any) error {
+				_, err := actions.ParsePropSpec(kind, v.(string))
+				return err
+			},
+			Doc: "the service and property, and the values or arguments for them",
+		}},
+		Shorthand: "target",
+	}
+	actions.Register(actions.Func(schema, func(ctx context.Context, params actions.Params) error {
+		mu.Lock()
+		a := props
+		mu.Unlock()
+		if a == nil {
+			return errNotInitialized
+		}
+		spec, err := actions.ParsePropSpec(kind, params.Str("target"))
+		if err != nil {
+			return err
+		}
+		return a.Run(ctx, spec)
+	}))
+}
@@ -0,0 +1,176 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// analogAxes runs a device's axis bindings. Axis events come from the device's read loop, and
+// the actions run from timers, so that slow actions and rate limits don't hold up the device's keys.
+type analogAxes struct {
+	ctx  context.Context
+	axes map[evdev.EvCode]*analogAxis
+}
+
+// analogAxis is the state of one axis binding.
+type analogAxis struct {
+	ctx  context.Context
+	cfg  config.AnalogAxis
+	info evdev.AbsInfo
+
+	mu sync.Mutex
+	// pos is the axis's position after the deadzone and curve: from 0 to 1, or from -1 to 1 for
+	// rate mode on centered axes
+	pos float64
+	// value is the last value run in value mode, valid once ran is set
+	value int32
+	ran   bool
+	// last is when the binding last ran, for rate limiting
+	last time.Time
+	// running is set while an action runs, which reschedules the timer when it's done
+	running  bool
+	timer    *time.Timer
+	timerGen int
+}
+
+func newAnalogAxes(ctx context.Context, cfg *config.Device, absInfos map[evdev.EvCode]evdev.AbsInfo) *analogAxes {
+	a := &analogAxes{ctx: ctx, axes: make(map[evdev.EvCode]*analogAxis)}
+	for code, axis := range cfg.Analog() {
+		info, ok := absInfos[code]
+		if !ok || info.Maximum <= info.Minimum {
+			slog.Warn("device has no range for bound axis, ignoring it", "axis", evdev.CodeName(evdev.EV_ABS, code))
+			continue
+		}
+		a.axes[code] = &analogAxis{ctx: ctx, cfg: axis, info: info}
+	}
+	return a
+}
+
+// handle updates an axis binding's position from an EV_ABS event, and reports whether the axis
+// has a binding.
+func (a *analogAxes) handle(ev *evdev.InputEvent) bool {
+	axis, ok := a.axes[ev.Code]
+	if !ok {
+		return false
+	}
+	axis.update(ev.Value)
+	return true
+}
+
+// stop stops the timers of all the axis bindings. Actions already running are cancelled with the
+// device's context.
+func (a *analogAxes) stop() {
+	for _, axis := range a.axes {
+		axis.mu.Lock()
+		axis.stopTimerLocked()
+		axis.mu.Unlock()
+	}
+}
+
+func (a *analogAxis) update(value int32) {
+	var pos float64
+	d, _ := deflection(a.cfg.Centered(), a.cfg.Deadzone, a.info, value)
+	if d != 0 {
+		// the value or rate starts from nothing at the edge of the deadzone
+		pos = math.Copysign(math.Pow((math.Abs(d)-a.cfg.Deadzone)/(1-a.cfg.Deadzone), a.cfg.Curve), d)
+	}
+	if pos < 0 && a.cfg.Low.IsZero() {
+		pos = 0
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pos = pos
+	a.scheduleLocked()
+}
+
+// targetValue is the value for the current position in value mode.
+func (a *analogAxis) targetValue() int32 {
+	return a.cfg.Min + int32(math.Round(a.pos*float64(a.cfg.Max-a.cfg.Min)))
+}
+
+// dueLocked reports whether the binding should run again, and how long it has to wait first.
+func (a *analogAxis) dueLocked() (time.Duration, bool) {
+	var interval time.Duration
+	switch a.cfg.Mode {
+	case config.AnalogValue:
+		if a.ran && a.targetValue() == a.value {
+			return 0, false
+		}
+		interval = a.cfg.Interval
+	case config.AnalogRate:
+		if a.pos == 0 {
+			return 0, false
+		}
+		// barely pushed past the deadzone, it could be ages until the next run: a minute will do
+		interval = time.Duration(min(1/(a.cfg.Rate*math.Abs(a.pos)), 60) * float64(time.Second))
+	}
+	return max(0, interval-time.Since(a.last)), true
+}
+
+// scheduleLocked starts the timer for the next run, if one is due. The timer is replaced rather
+// than left alone, since a position change may bring the next run closer.
+func (a *analogAxis) scheduleLocked() {
+	if a.running || a.ctx.Err() != nil {
+		return
+	}
+	a.stopTimerLocked()
+	wait, ok := a.dueLocked()
+	if !ok {
+		return
+	}
+	gen := a.timerGen
+	a.timer = time.AfterFunc(wait, func() { a.fire(gen) })
+}
+
+func (a *analogAxis) stopTimerLocked() {
+	// a timer that already fired may still be waiting for the lock, so the generation tells it it's stale
+	a.timerGen++
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+}
+
+func (a *analogAxis) fire(gen int) {
+	a.mu.Lock()
+	if gen != a.timerGen || a.ctx.Err() != nil {
+		a.mu.Unlock()
+		return
+	}
+	a.timer = nil
+	if _, ok := a.dueLocked(); !ok {
+		a.mu.Unlock()
+		return
+	}
+	var binding config.Binding
+	switch a.cfg.Mode {
+	case config.AnalogValue:
+		a.value, a.ran = a.targetValue(), true
+		binding = a.cfg.WithValue(a.value)
+	case config.AnalogRate:
+		binding = a.cfg.High
+		if a.pos < 0 {
+			binding = a.cfg.Low
+		}
+	}
+	a.last = time.Now()
+	a.running = true
+	a.mu.Unlock()
+
+	slog.Debug("running axis binding", "axis", evdev.CodeName(evdev.EV_ABS, a.cfg.Code), "action", binding.Action, "params", binding.Params)
+	ctx, cancel := context.WithTimeout(a.ctx, eventHandlerTimeout)
+	runAction(ctx, binding)
+	cancel()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.running = false
+	a.scheduleLocked()
+}
@@ -0,0 +1,110 @@
+package watcher
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+func init() {
+	actions.Register(actions.Func(actions.Schema{
+		Name:   "test_level",
+		Params: []actions.Param{{Name: "level", Type: actions.IntParam}},
+	}, func(ctx context.Context, params actions.Params) error {
+		ctx.Value(recorderKey{}).(*recorder).record(strconv.Itoa(int(params.Int("level"))))
+		return nil
+	}))
+}
+
+// testAbsInfo is the range of the test's axes, like the 8BitDo's.
+var testAbsInfo = evdev.AbsInfo{Minimum: 0, Maximum: 255}
+
+func TestAnalogPosition(t *testing.T) {
+	t.Parallel()
+	trigger := config.AnalogAxis{Code: evdev.ABS_GAS, Mode: config.AnalogValue, Curve: 1, Deadzone: 0.1, Min: 0, Max: 24}
+	reversed := trigger
+	reversed.Min, reversed.Max = 24, 0
+	curved := trigger
+	curved.Curve = 2
+	stick := config.AnalogAxis{
+		Code: evdev.ABS_RX, Mode: config.AnalogRate, Curve: 1, Deadzone: 0.1,
+		Low: config.Binding{Action: recordAction}, High: config.Binding{Action: recordAction},
+	}
+	oneWay := stick
+	oneWay.Low = config.Binding{}
+
+	tests := []struct {
+		name  string
+		cfg   config.AnalogAxis
+		value int32
+		pos   float64
+		// want is the value set in value mode
+		want int32
+	}{
+		{"trigger at rest", trigger, 0, 0, 0},
+		{"trigger in the deadzone", trigger, 25, 0, 0},
+		{"trigger halfway", trigger, 140, 0.5, 12},
+		{"trigger pulled", trigger, 255, 1, 24},
+		{"reversed at rest", reversed, 0, 0, 24},
+		{"reversed pulled", reversed, 255, 1, 0},
+		{"curved halfway", curved, 140, 0.25, 6},
+		{"stick at rest", stick, 128, 0, 0},
+		{"stick in the deadzone", stick, 140, 0, 0},
+		{"stick up", stick, 255, 1, 0},
+		{"stick down", stick, 0, -1, 0},
+		{"one-way stick down", oneWay, 0, 0, 0},
+		{"one-way stick up", oneWay, 255, 1, 0},
+	}
+	// with the context done, the axes only track their position and never run anything
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tt := range tests {
+		a := &analogAxis{ctx: ctx, cfg: tt.cfg, info: testAbsInfo}
+		a.update(tt.value)
+		if diff := a.pos - tt.pos; diff > 0.01 || diff < -0.01 {
+			t.Errorf("%s: position = %v, want %v", tt.name, a.pos, tt.pos)
+		}
+		if tt.cfg.Mode != config.AnalogValue {
+			continue
+		}
+		if got := a.targetValue(); got != tt.want {
+			t.Errorf("%s: value = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnalogValue(t *testing.T) {
+	t.Parallel()
+	rec := &recorder{}
+	ctx := context.WithValue(t.Context(), recorderKey{}, rec)
+	a := &analogAxis{ctx: ctx, info: testAbsInfo, cfg: config.AnalogAxis{
+		Code: evdev.ABS_GAS, Mode: config.AnalogValue, Curve: 1, Deadzone: 0.1, Min: 0, Max: 24,
+		Binding: config.Binding{Action: "test_level", Params: actions.Params{"level": int32(0)}}, Param: "level",
+		Interval: 10 * time.Millisecond,
+	}}
+	t.Cleanup(func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.stopTimerLocked()
+	})
+
+	a.update(255)
+	rec.wait(t, 1)
+	rec.expect(t, "24")
+	// noise at the same position doesn't run it again
+	a.update(254)
+	time.Sleep(30 * time.Millisecond)
+	rec.expect(t)
+	// let go, back to the minimum
+	a.update(10)
+	rec.wait(t, 1)
+	rec.expect(t, "0")
+	a.update(0)
+	time.Sleep(30 * time.Millisecond)
+	rec.expect(t)
+}
@@ -0,0 +1,95 @@
+package watcher
+
+import (
+	"math"
+
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// keyEvent is a key press or release synthesized from another kind of event.
+type keyEvent struct {
+	code  evdev.EvCode
+	value int32
+}
+
+// axisMapper turns a device's absolute axes into key presses and releases, as configured by
+// config.Axis. It remembers which key each axis is holding, so the key is released when the
+// axis comes back. It's only used from the device's read loop, so it needs no locking.
+type axisMapper struct {
+	axes     map[evdev.EvCode]config.Axis
+	absInfos map[evdev.EvCode]evdev.AbsInfo
+	// held is the key each axis is holding down
+	held map[evdev.EvCode]evdev.EvCode
+}
+
+func newAxisMapper(cfg *config.Device, absInfos map[evdev.EvCode]evdev.AbsInfo) *axisMapper {
+	return &axisMapper{axes: cfg.Axes(), absInfos: absInfos, held: make(map[evdev.EvCode]evdev.EvCode)}
+}
+
+// deflection returns how far an axis is pushed from where it rests, as a fraction of its range:
+// from -1 to 1 for centered axes, and 0 to 1 for the others. Values in the deadzone count as 0.
+// It returns false if the axis has no usable range.
+func deflection(centered bool, deadzone float64, info evdev.AbsInfo, value int32) (float64, bool) {
+	lo, hi := float64(info.Minimum), float64(info.Maximum)
+	if hi <= lo {
+		return 0, false
+	}
+	var d float64
+	if centered {
+		// e.g. the 8BitDo's D-pad shows ABS_X 127 at rest, 0 when left is pressed and 255 when right is
+		center, half := (lo+hi)/2, (hi-lo)/2
+		d = (float64(value) - center) / half
+	} else {
+		d = (float64(value) - lo) / (hi - lo)
+	}
+	d = max(-1, min(1, d))
+	if math.Abs(d) <= deadzone {
+		d = 0
+	}
+	return d, true
+}
+
+// handle returns the key events for an EV_ABS event, if its axis is mapped to keys.
+func (m *axisMapper) handle(ev *evdev.InputEvent) []keyEvent {
+	axis, ok := m.axes[ev.Code]
+	if !ok {
+		return nil
+	}
+	info, ok := m.absInfos[ev.Code]
+	if !ok {
+		return nil
+	}
+	d, ok := deflection(axis.Centered(), axis.Deadzone, info, ev.Value)
+	if !ok {
+		return nil
+	}
+
+	// a held key stays held until the axis comes back past the threshold by the hysteresis
+	held := m.held[ev.Code]
+	release := axis.Threshold - axis.Hysteresis
+	var want evdev.EvCode
+	switch {
+	case d >= axis.Threshold:
+		want = axis.High
+	case d <= -axis.Threshold && axis.Low != 0:
+		want = axis.Low
+	case held == axis.High && d > release:
+		want = axis.High
+	case held == axis.Low && held != 0 && d < -release:
+		want = axis.Low
+	}
+	if want == held {
+		return nil
+	}
+
+	var events []keyEvent
+	if held != 0 {
+		events = append(events, keyEvent{code: held, value: keyReleased})
+	}
+	if want != 0 {
+		events = append(events, keyEvent{code: want, value: keyPressed})
+	}
+	m.held[ev.Code] = want
+	return events
+}
@@ -0,0 +1,285 @@
+package watcher
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// heldKeys tracks the keys held down on all devices, so chords can use modifiers held on
+// another device.
+type heldKeys struct {
+	mu       sync.Mutex
+	byDevice map[string]map[evdev.EvCode]string
+}
+
+func newHeldKeys() *heldKeys {
+	return &heldKeys{byDevice: make(map[string]map[evdev.EvCode]string)}
+}
+
+func (h *heldKeys) press(device string, code evdev.EvCode, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.byDevice[device] == nil {
+		h.byDevice[device] = make(map[evdev.EvCode]string)
+	}
+	h.byDevice[device][code] = name
+}
+
+func (h *heldKeys) release(device string, code evdev.EvCode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.byDevice[device], code)
+}
+
+// releaseDevice forgets the keys held on a device, when it goes away.
+func (h *heldKeys) releaseDevice(device string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.byDevice, device)
+}
+
+// names returns the names of all held keys.
+func (h *heldKeys) names() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var names []string
+	for _, keys := range h.byDevice {
+		for _, name := range keys {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sequencer matches a device's key presses against its chord and sequence bindings, in front of
+// its keyStates. A press that may start a sequence is held back until the sequence completes, or
+// until the sequence timeout passes, when it's handed on to the keyStates as a plain press after all.
+// That way `A: next_page` still works alongside `A A: rotate_cw`, just a little later.
+type sequencer struct {
+	// ctx is the device's context, for the presses handed on when the timeout passes
+	ctx     context.Context
+	keys    keyHandler
+	layers  *layerStack
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending []pendingPress
+	// timerGen tells the current timeout from ones that were stopped too late
+	timer    *time.Timer
+	timerGen int
+	// swallowed holds the keys whose press was held back or used by a combo, so the keyStates
+	// don't see their release either
+	swallowed map[nodeKey]bool
+}
+
+// keyHandler is where the sequencer hands on the presses and releases that aren't part of a
+// combo, with the time the key actually went down or up: a keyStates.
+type keyHandler interface {
+	press(ctx context.Context, key nodeKey, name string, at time.Time)
+	release(ctx context.Context, key nodeKey, name string, at time.Time)
+}
+
+// pendingPress is a press held back by the sequencer, with the keys that were held at the time.
+type pendingPress struct {
+	key  nodeKey
+	name string
+	held []string
+	at   time.Time
+	// releasedAt is when the key was released, or zero if it's still down
+	releasedAt time.Time
+}
+
+// op is something to do once the sequencer's lock is released, since actions may take a while.
+type op func(ctx context.Context)
+
+func newSequencer(ctx context.Context, keys keyHandler, layers *layerStack, cfg *config.Device) *sequencer {
+	return &sequencer{
+		ctx:       ctx,
+		keys:      keys,
+		layers:    layers,
+		timeout:   cfg.Timing().SequenceTimeout,
+		swallowed: make(map[nodeKey]bool),
+	}
+}
+
+// press handles a key press, given the keys that were already held on any device.
+func (s *sequencer) press(ctx context.Context, n *node, code evdev.EvCode, name string, held []string) {
+	s.mu.Lock()
+	ops := s.pressLocked(pendingPress{key: nodeKey{n, code}, name: name, held: held, at: time.Now()})
+	s.mu.Unlock()
+	for _, op := range ops {
+		op(ctx)
+	}
+}
+
+func (s *sequencer) pressLocked(p pendingPress) []op {
+	candidate := append(slices.Clone(s.pending), p)
+	full, more := s.match(candidate)
+	switch {
+	case more:
+		// wait for the next key, or the timeout
+		s.pending = candidate
+		s.swallowed[p.key] = true
+		s.startTimer()
+		return nil
+	case full != nil:
+		s.clearPending()
+		s.swallowed[p.key] = true
+		return []op{runCombo(*full)}
+	case len(s.pending) > 0:
+		// the sequence was broken off, so settle it before looking at this press on its own
+		ops := s.flushLocked()
+		return append(ops, s.pressLocked(p)...)
+	}
+	return []op{func(ctx context.Context) { s.keys.press(ctx, p.key, p.name, p.at) }}
+}
+
+// release handles a key release.
+func (s *sequencer) release(ctx context.Context, n *node, code evdev.EvCode, name string) {
+	key := nodeKey{n, code}
+	now := time.Now()
+	s.mu.Lock()
+	if s.swallowed[key] {
+		delete(s.swallowed, key)
+		for i := range s.pending {
+			if s.pending[i].key == key {
+				s.pending[i].releasedAt = now
+			}
+		}
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.keys.release(ctx, key, name, now)
+}
+
+// flushLocked settles the pending presses: they run the combo they complete if there is one,
+// or else are handed on to the keyStates one by one.
+func (s *sequencer) flushLocked() []op {
+	pending := s.pending
+	s.clearPending()
+	if len(pending) == 0 {
+		return nil
+	}
+	if full, _ := s.match(pending); full != nil {
+		return []op{runCombo(*full)}
+	}
+	var ops []op
+	for _, p := range pending {
+		ops = append(ops, func(ctx context.Context) { s.keys.press(ctx, p.key, p.name, p.at) })
+		if !p.releasedAt.IsZero() {
+			ops = append(ops, func(ctx context.Context) { s.keys.release(ctx, p.key, p.name, p.releasedAt) })
+		} else {
+			// the key is still down, and its release should reach the keyStates now
+			delete(s.swallowed, p.key)
+		}
+	}
+	return ops
+}
+
+func (s *sequencer) clearPending() {
+	s.pending = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.timerGen++
+}
+
+func (s *sequencer) startTimer() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timerGen++
+	gen := s.timerGen
+	s.timer = time.AfterFunc(s.timeout, func() {
+		s.mu.Lock()
+		if gen != s.timerGen {
+			s.mu.Unlock()
+			return
+		}
+		ops := s.flushLocked()
+		s.mu.Unlock()
+
+		for _, op := range ops {
+			op(s.ctx)
+		}
+	})
+}
+
+// stop drops any pending presses, when the device goes away.
+func (s *sequencer) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clearPending()
+}
+
+// stopNode drops the pending presses and swallowed keys of one of the device's nodes, when it
+// goes away while the device's other nodes stay.
+func (s *sequencer) stopNode(n *node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.pending, func(p pendingPress) bool { return p.key.node == n }) {
+		s.clearPending()
+	}
+	for key := range s.swallowed {
+		if key.node == n {
+			delete(s.swallowed, key)
+		}
+	}
+}
+
+// match finds the combo that presses completes, preferring the one with the most modifiers and
+// then the one in the topmost layer, and whether a longer combo starts with presses.
+func (s *sequencer) match(presses []pendingPress) (full *config.Combo, more bool) {
+	best := -1
+	combos := s.layers.combos()
+	for i, combo := range combos {
+		if len(combo.Steps) < len(presses) || !stepsMatch(combo.Steps, presses) {
+			continue
+		}
+		if len(combo.Steps) > len(presses) {
+			more = true
+			continue
+		}
+		if modifiers := countModifiers(combo); modifiers > best {
+			best = modifiers
+			full = &combos[i]
+		}
+	}
+	return full, more
+}
+
+func stepsMatch(steps []config.Chord, presses []pendingPress) bool {
+	for i, p := range presses {
+		step := steps[i]
+		if !config.KeyMatches(step.Key, p.name) {
+			return false
+		}
+		for _, mod := range step.Modifiers {
+			if !slices.ContainsFunc(p.held, func(held string) bool { return config.KeyMatches(mod, held) }) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func countModifiers(combo config.Combo) int {
+	n := 0
+	for _, step := range combo.Steps {
+		n += len(step.Modifiers)
+	}
+	return n
+}
+
+func runCombo(combo config.Combo) op {
+	return func(ctx context.Context) {
+		run(ctx, combo.String(), config.TriggerPress, combo.Binding)
+	}
+}
@@ -0,0 +1,212 @@
+package watcher
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// fakeKeys is a keyHandler that records what the sequencer hands on, as +KEY_A for a press and
+// -KEY_A for a release, alongside the combos the sequencer runs.
+type fakeKeys struct {
+	rec *recorder
+
+	mu sync.Mutex
+	at map[string]time.Time
+}
+
+func (f *fakeKeys) press(_ context.Context, _ nodeKey, name string, at time.Time) {
+	f.mu.Lock()
+	f.at["+"+name] = at
+	f.mu.Unlock()
+	f.rec.record("+" + name)
+}
+
+func (f *fakeKeys) release(_ context.Context, _ nodeKey, name string, at time.Time) {
+	f.mu.Lock()
+	f.at["-"+name] = at
+	f.mu.Unlock()
+	f.rec.record("-" + name)
+}
+
+// seqEvent is a key event for the sequencer: +KEY_A presses A, -KEY_A releases it, and
+// +LEFTCTRL+KEY_A presses A while LEFTCTRL is held. An empty event waits out the sequence timeout.
+type seqEvent string
+
+const waitTimeout seqEvent = ""
+
+func newTestSequencer(t *testing.T, bind string) (context.Context, *sequencer, *fakeKeys, *recorder) {
+	t.Helper()
+	cfg := testDevice(t, "bind:\n"+bind)
+	rec := &recorder{}
+	layers := newLayerStack(cfg, "test", nil)
+	ctx := withLayers(context.WithValue(t.Context(), recorderKey{}, rec), layers)
+	keys := &fakeKeys{rec: rec, at: make(map[string]time.Time)}
+	return ctx, newSequencer(ctx, keys, layers, cfg), keys, rec
+}
+
+func (e seqEvent) send(ctx context.Context, s *sequencer, n *node) {
+	if e == waitTimeout {
+		time.Sleep(2 * testSequenceTimeout)
+		return
+	}
+	names := strings.Split(string(e[1:]), "+")
+	name := names[len(names)-1]
+	code := evdev.KEYFromString[name]
+	if e[0] == '-' {
+		s.release(ctx, n, code, name)
+		return
+	}
+	var held []string
+	for _, mod := range names[:len(names)-1] {
+		held = append(held, "KEY_"+mod)
+	}
+	s.press(ctx, n, code, name, held)
+}
+
+func TestSequencer(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		bind   string
+		events []seqEvent
+		want   []string
+	}{
+		{
+			name:   "unbound key",
+			bind:   "  A A: test_record:aa\n",
+			events: []seqEvent{"+KEY_B", "-KEY_B"},
+			want:   []string{"+KEY_B", "-KEY_B"},
+		},
+		{
+			name:   "sequence",
+			bind:   "  A A: test_record:aa\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A", "+KEY_A", "-KEY_A"},
+			want:   []string{"aa"},
+		},
+		{
+			name:   "prefix held back",
+			bind:   "  A A: test_record:aa\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A"},
+			want:   nil,
+		},
+		{
+			name:   "prefix handed on at the timeout",
+			bind:   "  A A: test_record:aa\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A", waitTimeout},
+			want:   []string{"+KEY_A", "-KEY_A"},
+		},
+		{
+			name:   "prefix still held at the timeout",
+			bind:   "  A A: test_record:aa\n",
+			events: []seqEvent{"+KEY_A", waitTimeout, "-KEY_A"},
+			want:   []string{"+KEY_A", "-KEY_A"},
+		},
+		{
+			name:   "sequence past the timeout",
+			bind:   "  A A: test_record:aa\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A", waitTimeout, "+KEY_A", "-KEY_A", waitTimeout},
+			want:   []string{"+KEY_A", "-KEY_A", "+KEY_A", "-KEY_A"},
+		},
+		{
+			name:   "broken off",
+			bind:   "  A A: test_record:aa\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A", "+KEY_B", "-KEY_B"},
+			want:   []string{"+KEY_A", "-KEY_A", "+KEY_B", "-KEY_B"},
+		},
+		{
+			name:   "broken off by the start of another",
+			bind:   "  A A: test_record:aa\n  B A: test_record:ba\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A", "+KEY_B", "-KEY_B", "+KEY_A", "-KEY_A"},
+			want:   []string{"+KEY_A", "-KEY_A", "ba"},
+		},
+		{
+			name:   "broken off while held",
+			bind:   "  A A: test_record:aa\n",
+			events: []seqEvent{"+KEY_A", "+KEY_B", "-KEY_A", "-KEY_B"},
+			want:   []string{"+KEY_A", "+KEY_B", "-KEY_A", "-KEY_B"},
+		},
+		{
+			name:   "shorter sequence at the timeout",
+			bind:   "  A B: test_record:ab\n  A B C: test_record:abc\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A", "+KEY_B", "-KEY_B", waitTimeout},
+			want:   []string{"ab"},
+		},
+		{
+			name:   "longer sequence",
+			bind:   "  A B: test_record:ab\n  A B C: test_record:abc\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A", "+KEY_B", "-KEY_B", "+KEY_C", "-KEY_C"},
+			want:   []string{"abc"},
+		},
+		{
+			name:   "releases after the sequence are swallowed",
+			bind:   "  A B: test_record:ab\n",
+			events: []seqEvent{"+KEY_A", "+KEY_B", "-KEY_A", "-KEY_B", "+KEY_B", "-KEY_B"},
+			want:   []string{"ab", "+KEY_B", "-KEY_B"},
+		},
+		{
+			name:   "chord",
+			bind:   "  LEFTCTRL+A: test_record:ctrl-a\n",
+			events: []seqEvent{"+KEY_LEFTCTRL", "+LEFTCTRL+KEY_A", "-KEY_A", "-KEY_LEFTCTRL"},
+			want:   []string{"+KEY_LEFTCTRL", "ctrl-a", "-KEY_LEFTCTRL"},
+		},
+		{
+			name:   "chord without its modifier",
+			bind:   "  LEFTCTRL+A: test_record:ctrl-a\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A"},
+			want:   []string{"+KEY_A", "-KEY_A"},
+		},
+		{
+			name:   "most modifiers",
+			bind:   "  LEFTCTRL+A: test_record:ctrl-a\n  LEFTCTRL+LEFTSHIFT+A: test_record:ctrl-shift-a\n",
+			events: []seqEvent{"+LEFTSHIFT+LEFTCTRL+KEY_A", "-KEY_A", "+LEFTCTRL+KEY_A", "-KEY_A"},
+			want:   []string{"ctrl-shift-a", "ctrl-a"},
+		},
+		{
+			name:   "chord in a sequence",
+			bind:   "  A LEFTCTRL+A: test_record:a-ctrl-a\n",
+			events: []seqEvent{"+KEY_A", "-KEY_A", "+LEFTCTRL+KEY_A", "-KEY_A"},
+			want:   []string{"a-ctrl-a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, seq, _, rec := newTestSequencer(t, tt.bind)
+			n := &node{path: "/dev/input/event0"}
+			for _, e := range tt.events {
+				e.send(ctx, seq, n)
+			}
+			if got := rec.take(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ran %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSequencerPressTime(t *testing.T) {
+	t.Parallel()
+	ctx, seq, keys, rec := newTestSequencer(t, "  A A: test_record:aa\n")
+	n := &node{path: "/dev/input/event0"}
+
+	// a press held back reaches the keys with the time it happened, not the time it was handed on
+	before := time.Now()
+	seq.press(ctx, n, evdev.KEY_A, "KEY_A", nil)
+	after := time.Now()
+	rec.wait(t, 1)
+	seq.release(ctx, n, evdev.KEY_A, "KEY_A")
+	rec.expect(t, "+KEY_A", "-KEY_A")
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	if at := keys.at["+KEY_A"]; at.Before(before) || at.After(after) {
+		t.Errorf("press at %v, want between %v and %v", at, before, after)
+	}
+	if held := keys.at["-KEY_A"].Sub(keys.at["+KEY_A"]); held < testSequenceTimeout {
+		t.Errorf("held for %v, want at least the sequence timeout %v", held, testSequenceTimeout)
+	}
+}
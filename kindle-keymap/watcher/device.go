@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+)
+
+// device is the state shared by the evdev nodes of one device, i.e. the nodes with the same
+// unique ID: a remote's keyboard, consumer control and mouse nodes share their layers, and keys
+// on one can be part of sequences with keys on another.
+type device struct {
+	id string
+	// ctx lives as long as any of the device's nodes are watched
+	ctx    context.Context
+	cancel context.CancelFunc
+	layers *layerStack
+	keys   *keyStates
+	seq    *sequencer
+	grabs  *grabs
+	// nodes counts the device's watched nodes, guarded by the Watcher's mu
+	nodes int
+}
+
+// node is one evdev node of a device.
+type node struct {
+	path string
+	// cfg holds the bindings for just this node's keys, or is nil if none of the device's
+	// node configs match it
+	cfg *config.Node
+}
+
+// join returns the device with the given ID, starting it if this is its first node. Each join
+// must be followed by a leave.
+func (w *Watcher) join(ctx context.Context, id string, cfg *config.Device) *device {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d, ok := w.devices[id]
+	if !ok {
+		// the device outlives the node it was started for if others join it, so it gets its own
+		// cancellation, and keeps only the values of ctx
+		devCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		grabs := newGrabs(cfg.Grab())
+		layerChanged := w.LayerChanged
+		layers := newLayerStack(cfg, id, func(deviceID string, layers []string) {
+			grabs.update(layers)
+			if layerChanged != nil {
+				layerChanged(deviceID, layers)
+			}
+		})
+		// actions get the device's layers through the context, for the layer actions
+		devCtx = withLayers(devCtx, layers)
+		keys := newKeyStates(devCtx, cfg, layers)
+		d = &device{id: id, ctx: devCtx, cancel: cancel, layers: layers, keys: keys, seq: newSequencer(devCtx, keys, layers, cfg), grabs: grabs}
+		w.devices[id] = d
+	} else {
+		slog.Info("watching another node of device", "device", id, "nodes", d.nodes+1)
+	}
+	d.nodes++
+	return d
+}
+
+// leave forgets a node's keys, and stops the device when its last node leaves.
+func (w *Watcher) leave(d *device, n *node) {
+	d.grabs.remove(n)
+	w.held.releaseDevice(n.path)
+	d.seq.stopNode(n)
+	d.keys.releaseNode(n)
+
+	w.mu.Lock()
+	d.nodes--
+	last := d.nodes == 0
+	if last {
+		delete(w.devices, d.id)
+	}
+	w.mu.Unlock()
+	if last {
+		d.cancel()
+		d.seq.stop()
+		d.keys.releaseAll()
+	}
+}
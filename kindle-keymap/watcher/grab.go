@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// grabs grabs a device's nodes while its config asks for it, either for as long as they're
+// watched or only while some of its layers are active, so their events don't reach the system.
+type grabs struct {
+	cfg config.Grab
+
+	mu      sync.Mutex
+	grabbed bool
+	nodes   map[*node]*evdev.InputDevice
+}
+
+func newGrabs(cfg config.Grab) *grabs {
+	return &grabs{cfg: cfg, grabbed: cfg.With([]string{config.BaseLayer}), nodes: make(map[*node]*evdev.InputDevice)}
+}
+
+// add grabs a newly watched node if the device's nodes are grabbed.
+func (g *grabs) add(n *node, dev *evdev.InputDevice) {
+	if !g.cfg.Enabled() {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.nodes[n] = dev
+	if g.grabbed {
+		setGrab(n, dev, true)
+	}
+}
+
+// remove forgets a node that's no longer watched. It's closed rather than ungrabbed, which
+// releases the grab too.
+func (g *grabs) remove(n *node) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.nodes, n)
+}
+
+// update grabs or ungrabs the device's nodes for its active layers.
+func (g *grabs) update(layers []string) {
+	grab := g.cfg.With(layers)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if grab == g.grabbed {
+		return
+	}
+	g.grabbed = grab
+	for n, dev := range g.nodes {
+		setGrab(n, dev, grab)
+	}
+}
+
+// isGrabbed reports whether the device's nodes are grabbed.
+func (g *grabs) isGrabbed() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.grabbed
+}
+
+func setGrab(n *node, dev *evdev.InputDevice, grab bool) {
+	var err error
+	if grab {
+		err = dev.Grab()
+	} else {
+		err = dev.Ungrab()
+	}
+	if err != nil {
+		// e.g. the node was closed as its watch stopped
+		slog.Warn("failed to change grab of device", "path", n.path, "grab", grab, "error", err)
+		return
+	}
+	// Grab and Ungrab go through dev.Fd(), which puts the node back in blocking mode, see Watch
+	if err := dev.NonBlock(); err != nil {
+		slog.Warn("dev.NonBlock() failed, the watch will only stop on the device's next event", "path", n.path, "error", err)
+	}
+	slog.Info("changed grab of device", "path", n.path, "grab", grab)
+}
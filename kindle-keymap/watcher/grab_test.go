@@ -0,0 +1,35 @@
+package watcher
+
+import (
+	"testing"
+
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+)
+
+func TestGrabsFollowLayers(t *testing.T) {
+	t.Parallel()
+	// without nodes, nothing is actually grabbed
+	g := newGrabs(config.Grab{Layers: []string{"nav"}, Passthrough: true})
+	steps := []struct {
+		layers []string
+		want   bool
+	}{
+		{[]string{"fn", config.BaseLayer}, false},
+		{[]string{"nav", config.BaseLayer}, true},
+		{[]string{"fn", "nav", config.BaseLayer}, true},
+		{[]string{config.BaseLayer}, false},
+	}
+	if g.isGrabbed() {
+		t.Error("isGrabbed() = true at the start, want false")
+	}
+	for _, s := range steps {
+		g.update(s.layers)
+		if got := g.isGrabbed(); got != s.want {
+			t.Errorf("isGrabbed() with %v = %v, want %v", s.layers, got, s.want)
+		}
+	}
+
+	if !newGrabs(config.Grab{Always: true}).isGrabbed() {
+		t.Error("isGrabbed() = false for grab: true, want true")
+	}
+}
@@ -0,0 +1,212 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// Key event values.
+const (
+	keyReleased  int32 = 0
+	keyPressed   int32 = 1
+	keyRepeating int32 = 2
+)
+
+// keyStates tracks the keys held down on one device, for bindings that depend on how long a key
+// is held. Long-press and hold-to-repeat bindings run from timers, so it's safe for concurrent use.
+type keyStates struct {
+	ctx    context.Context
+	cfg    *config.Device
+	layers *layerStack
+
+	mu   sync.Mutex
+	held map[nodeKey]*heldKey
+}
+
+// nodeKey identifies a key on one of a device's nodes, since its nodes may have keys with the same code.
+type nodeKey struct {
+	node *node
+	code evdev.EvCode
+}
+
+// heldKey is a key that's down, with its pending long-press or repeat timer.
+type heldKey struct {
+	name string
+	// at is when the key went down, which may be a while before the keyStates saw it if the
+	// sequencer held it back
+	at time.Time
+	// press and long are the key's bindings in the layers that were active when it was pressed
+	press, long config.Binding
+	timer       *time.Timer
+	// longFired is set once the long-press binding has run, so the release doesn't run the press binding
+	longFired bool
+}
+
+func newKeyStates(ctx context.Context, cfg *config.Device, layers *layerStack) *keyStates {
+	return &keyStates{ctx: ctx, cfg: cfg, layers: layers, held: make(map[nodeKey]*heldKey)}
+}
+
+// press runs the bindings for a key going down at the given time, and starts its long-press or
+// repeat timer.
+func (s *keyStates) press(ctx context.Context, key nodeKey, name string, at time.Time) {
+	s.mu.Lock()
+	if _, ok := s.held[key]; ok {
+		// a press without a release in between, e.g. two axes mapped to the same key: keep the first one going
+		s.mu.Unlock()
+		return
+	}
+	timing := s.cfg.Timing()
+	press := s.layers.binding(key.node, name, config.TriggerPress)
+	long := s.layers.binding(key.node, name, config.TriggerLong)
+	repeat := s.layers.binding(key.node, name, config.TriggerHoldRepeat)
+	k := &heldKey{name: name, at: at, press: press, long: long}
+	s.held[key] = k
+	// the time the key has already been down counts towards its timers
+	elapsed := time.Since(at)
+	switch {
+	case !long.IsZero():
+		// the press binding has to wait until we know whether this is a long press
+		k.timer = time.AfterFunc(max(timing.LongPress-elapsed, 0), func() {
+			s.mu.Lock()
+			fire := s.isHeld(key, k)
+			k.longFired = fire
+			s.mu.Unlock()
+			if fire {
+				run(s.ctx, name, config.TriggerLong, long)
+			}
+		})
+	case !repeat.IsZero():
+		var tick func()
+		tick = func() {
+			s.mu.Lock()
+			held := s.isHeld(key, k)
+			s.mu.Unlock()
+			if !held {
+				return
+			}
+			run(s.ctx, name, config.TriggerHoldRepeat, repeat)
+			s.mu.Lock()
+			if s.isHeld(key, k) {
+				k.timer = time.AfterFunc(timing.RepeatInterval, tick)
+			}
+			s.mu.Unlock()
+		}
+		k.timer = time.AfterFunc(max(timing.RepeatDelay-elapsed, 0), tick)
+	}
+	s.mu.Unlock()
+
+	if long.IsZero() {
+		run(ctx, name, config.TriggerPress, press)
+	}
+}
+
+// release runs the bindings for a key going up at the given time.
+func (s *keyStates) release(ctx context.Context, key nodeKey, name string, at time.Time) {
+	s.mu.Lock()
+	k, ok := s.held[key]
+	delete(s.held, key)
+	longFired := ok && k.longFired
+	if ok && k.timer != nil {
+		k.timer.Stop()
+	}
+	// a press and release handed on together by the sequencer can be long without its timer having run
+	runLong := ok && !longFired && !k.long.IsZero() && at.Sub(k.at) >= s.cfg.Timing().LongPress
+	s.mu.Unlock()
+
+	if runLong {
+		run(ctx, name, config.TriggerLong, k.long)
+		longFired = true
+	}
+	if ok && !longFired && !k.long.IsZero() {
+		// released before the long-press threshold, so it was a short press after all
+		run(ctx, name, config.TriggerPress, k.press)
+	}
+	if ok {
+		// a layer held with the key goes away with it
+		if layer, held := k.heldLayer(longFired); held {
+			s.layers.release(layer)
+		}
+	}
+	run(ctx, name, config.TriggerRelease, s.layers.binding(key.node, name, config.TriggerRelease))
+}
+
+// isHeld reports whether k is still the held state of the key, i.e. it hasn't been released
+// (and maybe pressed again) since its timer was set. s.mu must be held.
+func (s *keyStates) isHeld(key nodeKey, k *heldKey) bool {
+	return s.held[key] == k && s.ctx.Err() == nil
+}
+
+// heldLayer returns the layer the key is holding, if the binding it ran was a layer_hold.
+// longFired is the key's longFired, read under the keyStates' lock.
+func (k *heldKey) heldLayer(longFired bool) (string, bool) {
+	ran := k.press
+	if longFired {
+		ran = k.long
+	}
+	return ran.Params.Str("layer"), ran.Action == actions.LayerHold
+}
+
+// releaseNode forgets the keys held on one of the device's nodes and stops their timers, when
+// the node goes away while the device's other nodes stay. Layers they were holding are released.
+func (s *keyStates) releaseNode(n *node) {
+	s.mu.Lock()
+	var layers []string
+	for key, k := range s.held {
+		if key.node != n {
+			continue
+		}
+		if k.timer != nil {
+			k.timer.Stop()
+		}
+		delete(s.held, key)
+		if layer, held := k.heldLayer(k.longFired); held {
+			layers = append(layers, layer)
+		}
+	}
+	s.mu.Unlock()
+	for _, layer := range layers {
+		s.layers.release(layer)
+	}
+}
+
+// releaseAll forgets all held keys and stops their timers, when the device goes away.
+func (s *keyStates) releaseAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, k := range s.held {
+		if k.timer != nil {
+			k.timer.Stop()
+		}
+		delete(s.held, key)
+	}
+}
+
+// run runs a binding's action, if the key is bound for the trigger. ctx should be the device's
+// context, so that the action is cancelled if the device goes away.
+func run(ctx context.Context, name string, trigger config.Trigger, binding config.Binding) {
+	if binding.IsZero() {
+		return
+	}
+	slog.Info("running action", "key", name, "trigger", trigger, "action", binding.Action, "params", binding.Params)
+	if binding.Action == actions.Macro {
+		// macros can take a while, so they run in the background to keep the device's keys working
+		go runAction(ctx, binding)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, eventHandlerTimeout)
+	defer cancel()
+	runAction(ctx, binding)
+}
+
+func runAction(ctx context.Context, binding config.Binding) {
+	err := actions.Run(ctx, binding.Action, binding.Params)
+	if err != nil {
+		slog.Error("action failed", "action", binding.Action, "params", binding.Params, "error", err)
+	}
+}
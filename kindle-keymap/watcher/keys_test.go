@@ -0,0 +1,274 @@
+package watcher
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// The timings the tests' devices use, short enough to wait out.
+const (
+	testLongPress       = 40 * time.Millisecond
+	testRepeatDelay     = 40 * time.Millisecond
+	testRepeatInterval  = 20 * time.Millisecond
+	testSequenceTimeout = 40 * time.Millisecond
+)
+
+// recordAction is the action the tests bind, which records its id with the test's recorder.
+const recordAction = "test_record"
+
+func init() {
+	actions.Register(actions.Func(actions.Schema{
+		Name:      recordAction,
+		Params:    []actions.Param{{Name: "id", Type: actions.StringParam}},
+		Shorthand: "id",
+	}, func(ctx context.Context, params actions.Params) error {
+		ctx.Value(recorderKey{}).(*recorder).record(params.Str("id"))
+		return nil
+	}))
+}
+
+type recorderKey struct{}
+
+// recorder collects the ids of the test_record actions run with its context.
+type recorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *recorder) record(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ran = append(r.ran, id)
+}
+
+// take returns the ids recorded since the last take.
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ran := r.ran
+	r.ran = nil
+	return ran
+}
+
+// wait waits for n ids to be recorded, failing the test if they aren't within a second.
+func (r *recorder) wait(t *testing.T, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		r.mu.Lock()
+		got := len(r.ran)
+		r.mu.Unlock()
+		if got >= n {
+			return
+		}
+	}
+	t.Fatalf("waited for %d actions, got %v", n, r.take())
+}
+
+// expect checks the ids recorded since the last take.
+func (r *recorder) expect(t *testing.T, want ...string) {
+	t.Helper()
+	if got := r.take(); !reflect.DeepEqual(got, want) && (len(got) != 0 || len(want) != 0) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+// testDevice parses the config of one device, given as the YAML under its name.
+func testDevice(t *testing.T, yaml string) *config.Device {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("device:\n  - name: test\n    mac: e4:17:d8:0a:b0:0c\n")
+	b.WriteString("    long_press: " + testLongPress.String() + "\n")
+	b.WriteString("    repeat_delay: " + testRepeatDelay.String() + "\n")
+	b.WriteString("    repeat_interval: " + testRepeatInterval.String() + "\n")
+	b.WriteString("    sequence_timeout: " + testSequenceTimeout.String() + "\n")
+	for line := range strings.Lines(yaml) {
+		b.WriteString("    " + line)
+	}
+	cfg, err := config.Parse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return &cfg.Devices[0]
+}
+
+// newTestKeys returns the key states and layers of a device, and the context to handle its events with.
+func newTestKeys(t *testing.T, cfg *config.Device) (context.Context, *keyStates, *layerStack, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	layers := newLayerStack(cfg, "test", nil)
+	ctx := withLayers(context.WithValue(t.Context(), recorderKey{}, rec), layers)
+	return ctx, newKeyStates(ctx, cfg, layers), layers, rec
+}
+
+func checkStack(t *testing.T, layers *layerStack, want ...string) {
+	t.Helper()
+	if got := layers.stack(); !reflect.DeepEqual(got, want) {
+		t.Errorf("layers = %v, want %v", got, want)
+	}
+}
+
+func TestKeyPress(t *testing.T) {
+	t.Parallel()
+	ctx, keys, _, rec := newTestKeys(t, testDevice(t, "bind:\n  A: test_record:press\n  A.release: test_record:release\n"))
+	n := &node{path: "/dev/input/event0"}
+
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	rec.expect(t, "press")
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	rec.expect(t, "release")
+
+	// a key without bindings does nothing
+	keys.press(ctx, nodeKey{n, evdev.KEY_B}, "KEY_B", time.Now())
+	keys.release(ctx, nodeKey{n, evdev.KEY_B}, "KEY_B", time.Now())
+	rec.expect(t)
+}
+
+func TestKeyLongPress(t *testing.T) {
+	t.Parallel()
+	ctx, keys, _, rec := newTestKeys(t, testDevice(t, "bind:\n  A: test_record:press\n  A.long: test_record:long\n"))
+	n := &node{path: "/dev/input/event0"}
+
+	// the press binding waits for the release
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	rec.expect(t)
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	rec.expect(t, "press")
+	time.Sleep(2 * testLongPress)
+	rec.expect(t)
+
+	// once the long binding has run, the release doesn't run the press binding
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	rec.wait(t, 1)
+	rec.expect(t, "long")
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	rec.expect(t)
+}
+
+func TestKeyHoldRepeat(t *testing.T) {
+	t.Parallel()
+	ctx, keys, _, rec := newTestKeys(t, testDevice(t, "bind:\n  A: test_record:press\n  A.hold_repeat: test_record:repeat\n"))
+	n := &node{path: "/dev/input/event0"}
+
+	// a short press only runs the press binding
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	time.Sleep(2 * testRepeatDelay)
+	rec.expect(t, "press")
+
+	// held, it repeats until the release
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	rec.wait(t, 4)
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	got := rec.take()
+	if got[0] != "press" {
+		t.Errorf("ran %v, want press first", got)
+	}
+	for _, id := range got[1:] {
+		if id != "repeat" {
+			t.Errorf("ran %v, want repeats after the press", got)
+			break
+		}
+	}
+	time.Sleep(2 * testRepeatInterval)
+	rec.expect(t)
+}
+
+func TestKeyLayerHold(t *testing.T) {
+	t.Parallel()
+	cfg := testDevice(t, `bind:
+  A: layer_hold:fn
+  B.long: layer_hold:fn
+  B: test_record:b
+  G: test_record:base
+layers:
+  fn:
+    G: test_record:fn
+`)
+	ctx, keys, layers, rec := newTestKeys(t, cfg)
+	n := &node{path: "/dev/input/event0"}
+	tap := func(name string, code evdev.EvCode) {
+		keys.press(ctx, nodeKey{n, code}, name, time.Now())
+		keys.release(ctx, nodeKey{n, code}, name, time.Now())
+	}
+
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	checkStack(t, layers, "fn", config.BaseLayer)
+	tap("KEY_G", evdev.KEY_G)
+	rec.expect(t, "fn")
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	checkStack(t, layers, config.BaseLayer)
+	tap("KEY_G", evdev.KEY_G)
+	rec.expect(t, "base")
+
+	// held by a long press, the layer also goes away with the key, without the press binding
+	keys.press(ctx, nodeKey{n, evdev.KEY_B}, "KEY_B", time.Now())
+	time.Sleep(2 * testLongPress)
+	checkStack(t, layers, "fn", config.BaseLayer)
+	keys.release(ctx, nodeKey{n, evdev.KEY_B}, "KEY_B", time.Now())
+	checkStack(t, layers, config.BaseLayer)
+	rec.expect(t)
+}
+
+func TestKeyReleaseNode(t *testing.T) {
+	t.Parallel()
+	cfg := testDevice(t, "bind:\n  A: layer_hold:fn\n  B.long: test_record:long\nlayers:\n  fn: {}\n")
+	ctx, keys, layers, rec := newTestKeys(t, cfg)
+	n1 := &node{path: "/dev/input/event0"}
+	n2 := &node{path: "/dev/input/event1"}
+
+	// the same key held on both nodes holds the layer twice
+	keys.press(ctx, nodeKey{n1, evdev.KEY_A}, "KEY_A", time.Now())
+	keys.press(ctx, nodeKey{n2, evdev.KEY_A}, "KEY_A", time.Now())
+	keys.press(ctx, nodeKey{n1, evdev.KEY_B}, "KEY_B", time.Now())
+	checkStack(t, layers, "fn", "fn", config.BaseLayer)
+
+	// the node going away releases its hold, and stops its long-press timer
+	keys.releaseNode(n1)
+	checkStack(t, layers, "fn", config.BaseLayer)
+	time.Sleep(2 * testLongPress)
+	rec.expect(t)
+	// its keys' releases no longer do anything
+	keys.release(ctx, nodeKey{n1, evdev.KEY_A}, "KEY_A", time.Now())
+	checkStack(t, layers, "fn", config.BaseLayer)
+
+	keys.releaseNode(n2)
+	checkStack(t, layers, config.BaseLayer)
+}
+
+func TestKeyPressedEarlier(t *testing.T) {
+	t.Parallel()
+	ctx, keys, _, rec := newTestKeys(t, testDevice(t, "bind:\n  A: test_record:press\n  A.long: test_record:long\n"))
+	n := &node{path: "/dev/input/event0"}
+
+	// a press the sequencer held back has already been down for a while, which counts towards the long press
+	start := time.Now()
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", start.Add(-testLongPress))
+	rec.wait(t, 1)
+	if elapsed := time.Since(start); elapsed >= testLongPress {
+		t.Errorf("long press ran after %v, want it straight away", elapsed)
+	}
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", time.Now())
+	rec.expect(t, "long")
+
+	// and so does the time it was down for, if its release was held back too
+	at := time.Now().Add(-time.Second)
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", at)
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", at.Add(testLongPress))
+	rec.wait(t, 1)
+	time.Sleep(testLongPress)
+	rec.expect(t, "long")
+
+	// or not, if it was short
+	at = time.Now()
+	keys.press(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", at)
+	keys.release(ctx, nodeKey{n, evdev.KEY_A}, "KEY_A", at.Add(testLongPress/2))
+	rec.expect(t, "press")
+}
@@ -0,0 +1,167 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+)
+
+func init() {
+	layerParam := actions.Param{Name: "layer", Type: actions.StringParam, Doc: "the name of one of the device's layers"}
+	actions.Register(actions.Func(actions.Schema{
+		Name:      actions.LayerHold,
+		Doc:       "Switch to a layer of bindings while the key is held, e.g. layer_hold:nav.",
+		Params:    []actions.Param{layerParam},
+		Shorthand: "layer",
+	}, func(ctx context.Context, params actions.Params) error {
+		layers, err := layersFromContext(ctx)
+		if err != nil {
+			return err
+		}
+		layers.hold(params.Str("layer"))
+		return nil
+	}))
+	actions.Register(actions.Func(actions.Schema{
+		Name:      actions.LayerToggle,
+		Doc:       "Switch to a layer of bindings until the key is pressed again, e.g. layer_toggle:light.",
+		Params:    []actions.Param{layerParam},
+		Shorthand: "layer",
+	}, func(ctx context.Context, params actions.Params) error {
+		layers, err := layersFromContext(ctx)
+		if err != nil {
+			return err
+		}
+		layers.toggle(params.Str("layer"))
+		return nil
+	}))
+}
+
+type layersKey struct{}
+
+// withLayers returns a context for running a device's actions, so the layer actions can switch its layers.
+func withLayers(ctx context.Context, layers *layerStack) context.Context {
+	return context.WithValue(ctx, layersKey{}, layers)
+}
+
+func layersFromContext(ctx context.Context) (*layerStack, error) {
+	layers, ok := ctx.Value(layersKey{}).(*layerStack)
+	if !ok {
+		return nil, errors.New("layers can only be switched by a device's bindings")
+	}
+	return layers, nil
+}
+
+// layerStack is the layers active on a device. A key's binding is looked up in the most recently
+// activated layer first, then in the ones below it, then in the bindings of the key's node, and
+// finally in the base layer.
+type layerStack struct {
+	cfg *config.Device
+	id  string
+	// onChange, if set, is called with the active layers whenever they change
+	onChange func(deviceID string, layers []string)
+
+	mu     sync.Mutex
+	active []string
+}
+
+func newLayerStack(cfg *config.Device, id string, onChange func(deviceID string, layers []string)) *layerStack {
+	return &layerStack{cfg: cfg, id: id, onChange: onChange}
+}
+
+// stack returns the active layers from the top down, ending with the base layer.
+func (l *layerStack) stack() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stack := slices.Clone(l.active)
+	slices.Reverse(stack)
+	return append(stack, config.BaseLayer)
+}
+
+// binding returns the binding for a key on node n and trigger in the topmost layer that has one.
+func (l *layerStack) binding(n *node, keyName string, trigger config.Trigger) config.Binding {
+	for _, name := range l.stack() {
+		if name == config.BaseLayer && n.cfg != nil {
+			if b := n.cfg.BindingForKey(keyName, trigger); !b.IsZero() {
+				return b
+			}
+		}
+		if b := l.cfg.Layer(name).BindingForKey(keyName, trigger); !b.IsZero() {
+			return b
+		}
+	}
+	return config.Binding{}
+}
+
+// bound reports whether any of the active layers, or node n, bind a key.
+func (l *layerStack) bound(n *node, keyName string) bool {
+	if n.cfg != nil && n.cfg.Binds(keyName) {
+		return true
+	}
+	for _, name := range l.stack() {
+		if l.cfg.Layer(name).Binds(keyName) {
+			return true
+		}
+	}
+	return false
+}
+
+// combos returns the chord and sequence bindings of the active layers, from the top down.
+func (l *layerStack) combos() []config.Combo {
+	var combos []config.Combo
+	for _, name := range l.stack() {
+		combos = append(combos, l.cfg.Layer(name).Combos()...)
+	}
+	return combos
+}
+
+// hold activates a layer, until a matching release.
+func (l *layerStack) hold(name string) {
+	l.mu.Lock()
+	l.active = append(l.active, name)
+	l.mu.Unlock()
+	l.changed()
+}
+
+// release deactivates a layer activated by hold. If it's been held more than once, e.g. on
+// two keys, it stays active until all of them are released.
+func (l *layerStack) release(name string) {
+	l.mu.Lock()
+	released := false
+	for i := len(l.active) - 1; i >= 0; i-- {
+		if l.active[i] == name {
+			l.active = slices.Delete(l.active, i, i+1)
+			released = true
+			break
+		}
+	}
+	l.mu.Unlock()
+	// it may have been toggled off while it was held
+	if released {
+		l.changed()
+	}
+}
+
+// toggle activates a layer, or deactivates it if it's already active.
+func (l *layerStack) toggle(name string) {
+	l.mu.Lock()
+	if slices.Contains(l.active, name) {
+		l.active = slices.DeleteFunc(l.active, func(active string) bool { return active == name })
+	} else {
+		l.active = append(l.active, name)
+	}
+	l.mu.Unlock()
+	l.changed()
+}
+
+func (l *layerStack) changed() {
+	stack := l.stack()
+	slog.Info("active layers changed", "device", l.id, "layer", stack[0], "layers", stack)
+	if l.onChange != nil {
+		l.onChange(l.id, stack)
+	}
+}
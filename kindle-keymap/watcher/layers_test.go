@@ -0,0 +1,184 @@
+package watcher
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+const testLayers = `bind:
+  A: test_record:base-a
+  B: test_record:base-b
+  C: test_record:base-c
+nodes:
+  - name: Consumer Control
+    bind:
+      B: test_record:node-b
+      C: test_record:node-c
+layers:
+  nav:
+    A: test_record:nav-a
+    C: test_record:nav-c
+  fn:
+    A: test_record:fn-a
+`
+
+// testNodes returns a node with bindings of its own, and one without.
+func testNodes(cfg *config.Device) (withBindings, without *node) {
+	capable := func(evdev.EvType) []evdev.EvCode { return nil }
+	return &node{path: "/dev/input/event0", cfg: cfg.NodeFor("Consumer Control", capable)},
+		&node{path: "/dev/input/event1"}
+}
+
+func TestLayerStack(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		// ops are "hold <layer>", "release <layer>" or "toggle <layer>"
+		ops   []string
+		stack []string
+		// changes is how many times the active layers changed
+		changes int
+		// node and plain are the ids of the press bindings of A, B, C and D on the node with
+		// bindings and the one without
+		node, plain []string
+	}{
+		{
+			name:  "base",
+			stack: []string{"base"},
+			node:  []string{"base-a", "node-b", "node-c", ""},
+			plain: []string{"base-a", "base-b", "base-c", ""},
+		},
+		{
+			name:    "held",
+			ops:     []string{"hold nav"},
+			stack:   []string{"nav", "base"},
+			changes: 1,
+			node:    []string{"nav-a", "node-b", "nav-c", ""},
+			plain:   []string{"nav-a", "base-b", "nav-c", ""},
+		},
+		{
+			name:    "top layer first",
+			ops:     []string{"hold nav", "hold fn"},
+			stack:   []string{"fn", "nav", "base"},
+			changes: 2,
+			node:    []string{"fn-a", "node-b", "nav-c", ""},
+			plain:   []string{"fn-a", "base-b", "nav-c", ""},
+		},
+		{
+			name:    "released",
+			ops:     []string{"hold nav", "release nav"},
+			stack:   []string{"base"},
+			changes: 2,
+			node:    []string{"base-a", "node-b", "node-c", ""},
+			plain:   []string{"base-a", "base-b", "base-c", ""},
+		},
+		{
+			name:    "released out of order",
+			ops:     []string{"hold nav", "hold fn", "release nav"},
+			stack:   []string{"fn", "base"},
+			changes: 3,
+			node:    []string{"fn-a", "node-b", "node-c", ""},
+			plain:   []string{"fn-a", "base-b", "base-c", ""},
+		},
+		{
+			name:    "held twice, released once",
+			ops:     []string{"hold nav", "hold nav", "release nav"},
+			stack:   []string{"nav", "base"},
+			changes: 3,
+			node:    []string{"nav-a", "node-b", "nav-c", ""},
+			plain:   []string{"nav-a", "base-b", "nav-c", ""},
+		},
+		{
+			name:    "held twice, released twice",
+			ops:     []string{"hold nav", "hold nav", "release nav", "release nav"},
+			stack:   []string{"base"},
+			changes: 4,
+			node:    []string{"base-a", "node-b", "node-c", ""},
+			plain:   []string{"base-a", "base-b", "base-c", ""},
+		},
+		{
+			name:  "released without a hold",
+			ops:   []string{"release nav"},
+			stack: []string{"base"},
+			node:  []string{"base-a", "node-b", "node-c", ""},
+			plain: []string{"base-a", "base-b", "base-c", ""},
+		},
+		{
+			name:    "toggled",
+			ops:     []string{"toggle fn"},
+			stack:   []string{"fn", "base"},
+			changes: 1,
+			node:    []string{"fn-a", "node-b", "node-c", ""},
+			plain:   []string{"fn-a", "base-b", "base-c", ""},
+		},
+		{
+			name:    "toggled twice",
+			ops:     []string{"toggle fn", "toggle fn"},
+			stack:   []string{"base"},
+			changes: 2,
+			node:    []string{"base-a", "node-b", "node-c", ""},
+			plain:   []string{"base-a", "base-b", "base-c", ""},
+		},
+		{
+			name:    "toggled off while held",
+			ops:     []string{"hold nav", "hold nav", "toggle nav", "release nav"},
+			stack:   []string{"base"},
+			changes: 3,
+			node:    []string{"base-a", "node-b", "node-c", ""},
+			plain:   []string{"base-a", "base-b", "base-c", ""},
+		},
+		{
+			name:    "held while toggled on",
+			ops:     []string{"toggle nav", "hold nav", "hold fn", "release nav"},
+			stack:   []string{"fn", "nav", "base"},
+			changes: 4,
+			node:    []string{"fn-a", "node-b", "nav-c", ""},
+			plain:   []string{"fn-a", "base-b", "nav-c", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := testDevice(t, testLayers)
+			changes := 0
+			layers := newLayerStack(cfg, "test", func(deviceID string, stack []string) {
+				changes++
+				if deviceID != "test" {
+					t.Errorf("changed device %q, want test", deviceID)
+				}
+			})
+			for _, op := range tt.ops {
+				switch verb, layer, _ := strings.Cut(op, " "); verb {
+				case "hold":
+					layers.hold(layer)
+				case "release":
+					layers.release(layer)
+				case "toggle":
+					layers.toggle(layer)
+				}
+			}
+			if got := layers.stack(); !reflect.DeepEqual(got, tt.stack) {
+				t.Errorf("stack() = %v, want %v", got, tt.stack)
+			}
+			if changes != tt.changes {
+				t.Errorf("changed %d times, want %d", changes, tt.changes)
+			}
+			withBindings, without := testNodes(cfg)
+			for i, key := range []string{"KEY_A", "KEY_B", "KEY_C", "KEY_D"} {
+				if got := layers.binding(withBindings, key, config.TriggerPress).Params.Str("id"); got != tt.node[i] {
+					t.Errorf("binding of %s on the node = %q, want %q", key, got, tt.node[i])
+				}
+				if got := layers.binding(without, key, config.TriggerPress).Params.Str("id"); got != tt.plain[i] {
+					t.Errorf("binding of %s = %q, want %q", key, got, tt.plain[i])
+				}
+				if got, want := layers.bound(without, key), tt.plain[i] != ""; got != want {
+					t.Errorf("bound(%s) = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package watcher
+
+import (
+	"log/slog"
+
+	"github.com/clintharrison/bueno/quietly"
+	"github.com/holoplot/go-evdev"
+)
+
+// passthrough sends a grabbed node's unbound keys on to the system through a virtual uinput
+// device with the same capabilities, so grabbing a device only keeps its bound keys from the
+// system. Wheel, mouse and axis movements aren't passed on.
+type passthrough struct {
+	virt *evdev.InputDevice
+	// writeOne writes an event to virt, or somewhere else in tests, which can't make uinput devices
+	writeOne func(*evdev.InputEvent) error
+	// down are the keys pressed on virt, whose repeats and releases follow them even if the
+	// key's been bound or the node ungrabbed since
+	down map[evdev.EvCode]bool
+}
+
+// newPassthrough makes a virtual device like dev. It must be called before dev.NonBlock, see Watch.
+func newPassthrough(dev *evdev.InputDevice, devName string) (*passthrough, error) {
+	// the virtual device has no unique ID, so it's never mistaken for a configured device
+	virt, err := evdev.CloneDevice("kindle-keymap passthrough: "+devName, dev)
+	if err != nil {
+		return nil, err
+	}
+	return &passthrough{virt: virt, writeOne: virt.WriteOne, down: make(map[evdev.EvCode]bool)}, nil
+}
+
+// handle passes on a key event if it's the press of an unbound key while the node is grabbed,
+// or the repeat or release of a key it passed on.
+func (p *passthrough) handle(ev *evdev.InputEvent, grabbed bool, bound func() bool) {
+	if p == nil {
+		return
+	}
+	switch ev.Value {
+	case keyPressed:
+		if !grabbed || bound() {
+			return
+		}
+		p.down[ev.Code] = true
+	case keyRepeating:
+		if !p.down[ev.Code] {
+			return
+		}
+	case keyReleased:
+		if !p.down[ev.Code] {
+			return
+		}
+		delete(p.down, ev.Code)
+	}
+	p.write(ev.Code, ev.Value)
+}
+
+func (p *passthrough) write(code evdev.EvCode, value int32) {
+	for _, ev := range []evdev.InputEvent{
+		{Type: evdev.EV_KEY, Code: code, Value: value},
+		{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT},
+	} {
+		if err := p.writeOne(&ev); err != nil {
+			slog.Warn("failed to pass key on to the system", "code", code, "name", evdev.CodeName(evdev.EV_KEY, code), "error", err)
+			return
+		}
+	}
+}
+
+// close releases the keys still pressed on the virtual device, and removes it.
+func (p *passthrough) close() {
+	if p == nil {
+		return
+	}
+	for code := range p.down {
+		p.write(code, keyReleased)
+	}
+	if err := evdev.DestroyDevice(p.virt); err != nil {
+		slog.Warn("evdev.DestroyDevice() failed", "error", err)
+	}
+	quietly.Close(p.virt)
+}
@@ -0,0 +1,143 @@
+package watcher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/holoplot/go-evdev"
+)
+
+func TestPassthrough(t *testing.T) {
+	t.Parallel()
+	type step struct {
+		code    evdev.EvCode
+		value   int32
+		grabbed bool
+		bound   bool
+		// want is whether the event is passed on
+		want bool
+	}
+	const a, b evdev.EvCode = evdev.KEY_A, evdev.KEY_B
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "unbound while grabbed",
+			steps: []step{
+				{code: a, value: keyPressed, grabbed: true, want: true},
+				{code: a, value: keyRepeating, grabbed: true, want: true},
+				{code: a, value: keyReleased, grabbed: true, want: true},
+			},
+		},
+		{
+			name: "bound while grabbed",
+			steps: []step{
+				{code: a, value: keyPressed, grabbed: true, bound: true},
+				{code: a, value: keyRepeating, grabbed: true, bound: true},
+				{code: a, value: keyReleased, grabbed: true, bound: true},
+			},
+		},
+		{
+			name: "not grabbed",
+			steps: []step{
+				{code: a, value: keyPressed},
+				{code: a, value: keyRepeating},
+				{code: a, value: keyReleased},
+			},
+		},
+		{
+			name: "pressed before the grab",
+			steps: []step{
+				{code: a, value: keyPressed},
+				{code: a, value: keyRepeating, grabbed: true},
+				{code: a, value: keyReleased, grabbed: true},
+			},
+		},
+		{
+			name: "released after the grab",
+			steps: []step{
+				{code: a, value: keyPressed, grabbed: true, want: true},
+				{code: a, value: keyRepeating, want: true},
+				{code: a, value: keyReleased, want: true},
+				{code: a, value: keyPressed},
+			},
+		},
+		{
+			name: "bound while down",
+			steps: []step{
+				{code: a, value: keyPressed, grabbed: true, want: true},
+				{code: a, value: keyRepeating, grabbed: true, bound: true, want: true},
+				{code: a, value: keyReleased, grabbed: true, bound: true, want: true},
+				{code: a, value: keyPressed, grabbed: true, bound: true},
+			},
+		},
+		{
+			name: "keys apart",
+			steps: []step{
+				{code: a, value: keyPressed, grabbed: true, want: true},
+				{code: b, value: keyPressed, grabbed: true, bound: true},
+				{code: b, value: keyReleased, grabbed: true, bound: true},
+				{code: a, value: keyReleased, grabbed: true, want: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var written []evdev.InputEvent
+			p := &passthrough{
+				writeOne: func(ev *evdev.InputEvent) error {
+					written = append(written, *ev)
+					return nil
+				},
+				down: make(map[evdev.EvCode]bool),
+			}
+			for i, s := range tt.steps {
+				written = nil
+				boundCalled := false
+				p.handle(&evdev.InputEvent{Type: evdev.EV_KEY, Code: s.code, Value: s.value}, s.grabbed, func() bool {
+					boundCalled = true
+					return s.bound
+				})
+				var want []evdev.InputEvent
+				if s.want {
+					want = []evdev.InputEvent{
+						{Type: evdev.EV_KEY, Code: s.code, Value: s.value},
+						{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT},
+					}
+				}
+				if !reflect.DeepEqual(written, want) {
+					t.Errorf("step %d: %s %d wrote %v, want %v", i, evdev.CodeName(evdev.EV_KEY, s.code), s.value, written, want)
+				}
+				// whether the key is bound only matters for presses while grabbed
+				if boundCalled && (s.value != keyPressed || !s.grabbed) {
+					t.Errorf("step %d: bound() called for %s %d", i, evdev.CodeName(evdev.EV_KEY, s.code), s.value)
+				}
+			}
+		})
+	}
+}
+
+func TestPassthroughWriteError(t *testing.T) {
+	t.Parallel()
+	writes := 0
+	p := &passthrough{
+		writeOne: func(*evdev.InputEvent) error {
+			writes++
+			return errors.New("no device")
+		},
+		down: make(map[evdev.EvCode]bool),
+	}
+	p.handle(&evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.KEY_A, Value: keyPressed}, true, func() bool { return false })
+	// the SYN_REPORT isn't written after a failed key event
+	if writes != 1 {
+		t.Errorf("wrote %d events, want 1", writes)
+	}
+
+	// nil when the device isn't grabbed with passthrough
+	var none *passthrough
+	none.handle(&evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.KEY_A, Value: keyPressed}, true, func() bool { return false })
+	none.close()
+}
@@ -0,0 +1,108 @@
+package watcher
+
+import (
+	"slices"
+	"time"
+
+	"github.com/clintharrison/bueno/kindle-keymap/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// relKeyCodeBase is where the codes for relative axis keys start, past the codes of real keys
+// (KEY_MAX is 0x2ff), so they can be tracked along with them.
+const relKeyCodeBase evdev.EvCode = 0x1000
+
+// relIdleReset is how long a relative axis has to be still for a partial movement to be forgotten,
+// so that a mouse jittering now and then doesn't add up to a key press.
+const relIdleReset = 500 * time.Millisecond
+
+// maxRelPresses limits the key presses from a single event, for a mouse flung across the table.
+const maxRelPresses = 10
+
+// hiResUnits is how many REL_WHEEL_HI_RES units make one notch of a wheel, i.e. one REL_WHEEL.
+const hiResUnits = 120
+
+// relMapper turns a device's relative axes into key presses, as they move past the device's
+// config.RelThresholds. Like axisMapper, it's only used from the device's read loop.
+type relMapper struct {
+	thresholds config.RelThresholds
+	// hiRes holds the plain wheel axes whose high-resolution axes the device has: their events are
+	// counted from those instead, since the device sends both
+	hiRes map[evdev.EvCode]bool
+	moved map[evdev.EvCode]int32
+	last  map[evdev.EvCode]time.Time
+}
+
+func newRelMapper(cfg *config.Device, capable []evdev.EvCode) *relMapper {
+	m := &relMapper{
+		thresholds: cfg.RelThresholds(),
+		hiRes:      make(map[evdev.EvCode]bool),
+		moved:      make(map[evdev.EvCode]int32),
+		last:       make(map[evdev.EvCode]time.Time),
+	}
+	m.hiRes[evdev.REL_WHEEL] = slices.Contains(capable, evdev.REL_WHEEL_HI_RES)
+	m.hiRes[evdev.REL_HWHEEL] = slices.Contains(capable, evdev.REL_HWHEEL_HI_RES)
+	return m
+}
+
+// relKeyCode returns the code to track a relative axis key with.
+func relKeyCode(code evdev.EvCode, positive bool) evdev.EvCode {
+	c := relKeyCodeBase + 2*code
+	if positive {
+		c++
+	}
+	return c
+}
+
+// relKey is a press of a relative axis key.
+type relKey struct {
+	code evdev.EvCode
+	name string
+}
+
+// handle returns the relative axis keys an EV_REL event presses. Each is pressed and released
+// straight away, since the axis doesn't stay anywhere.
+func (m *relMapper) handle(ev *evdev.InputEvent) []relKey {
+	code, value, threshold := ev.Code, ev.Value, m.thresholds.Mouse
+	switch ev.Code {
+	case evdev.REL_WHEEL, evdev.REL_HWHEEL:
+		if m.hiRes[ev.Code] {
+			return nil
+		}
+		value *= hiResUnits
+		threshold = m.thresholds.Wheel
+	case evdev.REL_WHEEL_HI_RES:
+		code, threshold = evdev.REL_WHEEL, m.thresholds.Wheel
+	case evdev.REL_HWHEEL_HI_RES:
+		code, threshold = evdev.REL_HWHEEL, m.thresholds.Wheel
+	case evdev.REL_X, evdev.REL_Y:
+	default:
+		return nil
+	}
+
+	moved := m.moved[code]
+	now := time.Now()
+	// a change of direction or a pause starts over
+	if now.Sub(m.last[code]) > relIdleReset || moved > 0 != (value > 0) {
+		moved = 0
+	}
+	m.last[code] = now
+	moved += value
+
+	var keys []relKey
+	for moved >= threshold || moved <= -threshold {
+		positive := moved > 0
+		if positive {
+			moved -= threshold
+		} else {
+			moved += threshold
+		}
+		if len(keys) == maxRelPresses {
+			continue
+		}
+		name, _ := config.RelKeyName(code, positive)
+		keys = append(keys, relKey{code: relKeyCode(code, positive), name: name})
+	}
+	m.moved[code] = moved
+	return keys
+}
@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/holoplot/go-evdev"
+)
+
+func TestRelMapper(t *testing.T) {
+	t.Parallel()
+	type step struct {
+		code  evdev.EvCode
+		value int32
+		// idle is set for a step that comes after the axes have been still long enough to start over
+		idle bool
+		want []string
+	}
+	const (
+		up    = "WHEEL_UP"
+		down  = "WHEEL_DOWN"
+		right = "MOUSE_RIGHT"
+	)
+	tests := []struct {
+		name    string
+		config  string
+		capable []evdev.EvCode
+		steps   []step
+	}{
+		{
+			name:    "wheel",
+			capable: []evdev.EvCode{evdev.REL_WHEEL},
+			steps: []step{
+				{code: evdev.REL_WHEEL, value: 1, want: []string{up}},
+				{code: evdev.REL_WHEEL, value: -1, want: []string{down}},
+				{code: evdev.REL_WHEEL, value: -2, want: []string{down, down}},
+			},
+		},
+		{
+			name:    "wheel at half a notch",
+			config:  "wheel_threshold: 60\n",
+			capable: []evdev.EvCode{evdev.REL_WHEEL},
+			steps:   []step{{code: evdev.REL_WHEEL, value: 1, want: []string{up, up}}},
+		},
+		{
+			name:    "high-resolution wheel",
+			capable: []evdev.EvCode{evdev.REL_WHEEL, evdev.REL_WHEEL_HI_RES},
+			steps: []step{
+				{code: evdev.REL_WHEEL_HI_RES, value: 60},
+				{code: evdev.REL_WHEEL_HI_RES, value: 60, want: []string{up}},
+				// the plain event for the same notch doesn't count again
+				{code: evdev.REL_WHEEL, value: 1},
+				{code: evdev.REL_WHEEL_HI_RES, value: -120, want: []string{down}},
+				{code: evdev.REL_WHEEL, value: -1},
+			},
+		},
+		{
+			name:    "high-resolution wheel without its horizontal one",
+			capable: []evdev.EvCode{evdev.REL_WHEEL, evdev.REL_WHEEL_HI_RES, evdev.REL_HWHEEL},
+			steps: []step{
+				{code: evdev.REL_WHEEL, value: 1},
+				{code: evdev.REL_HWHEEL, value: 1, want: []string{"HWHEEL_RIGHT"}},
+				{code: evdev.REL_HWHEEL_HI_RES, value: -120, want: []string{"HWHEEL_LEFT"}},
+			},
+		},
+		{
+			name:    "change of direction",
+			capable: []evdev.EvCode{evdev.REL_WHEEL, evdev.REL_WHEEL_HI_RES},
+			steps: []step{
+				{code: evdev.REL_WHEEL_HI_RES, value: 100},
+				// starts over from nothing, rather than from 100
+				{code: evdev.REL_WHEEL_HI_RES, value: -30},
+				{code: evdev.REL_WHEEL_HI_RES, value: -90, want: []string{down}},
+			},
+		},
+		{
+			name: "mouse",
+			steps: []step{
+				{code: evdev.REL_X, value: 30},
+				{code: evdev.REL_X, value: 30, want: []string{right}},
+				// the 10 left over carries on
+				{code: evdev.REL_X, value: 40, want: []string{right}},
+				{code: evdev.REL_Y, value: -50, want: []string{"MOUSE_UP"}},
+				{code: evdev.REL_Y, value: 120, want: []string{"MOUSE_DOWN", "MOUSE_DOWN"}},
+			},
+		},
+		{
+			name: "idle",
+			steps: []step{
+				{code: evdev.REL_X, value: 30},
+				{code: evdev.REL_X, value: 30, idle: true},
+				{code: evdev.REL_X, value: 30, want: []string{right}},
+			},
+		},
+		{
+			name:   "presses per event",
+			config: "mouse_threshold: 10\n",
+			steps: []step{
+				{code: evdev.REL_X, value: 1005, want: []string{right, right, right, right, right, right, right, right, right, right}},
+				// the presses past the limit are dropped rather than saved up, and only the 5 left over carries on
+				{code: evdev.REL_X, value: 4},
+				{code: evdev.REL_X, value: 1, want: []string{right}},
+			},
+		},
+		{
+			name:  "other axes",
+			steps: []step{{code: evdev.REL_DIAL, value: 1000}, {code: evdev.REL_WHEEL_HI_RES, value: 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := newRelMapper(testDevice(t, tt.config), tt.capable)
+			for i, s := range tt.steps {
+				if s.idle {
+					for code := range m.last {
+						m.last[code] = m.last[code].Add(-relIdleReset - time.Millisecond)
+					}
+				}
+				var got []string
+				for _, key := range m.handle(&evdev.InputEvent{Type: evdev.EV_REL, Code: s.code, Value: s.value}) {
+					got = append(got, key.name)
+				}
+				if !reflect.DeepEqual(got, s.want) {
+					t.Errorf("step %d: %s %d pressed %v, want %v", i, evdev.CodeName(evdev.EV_REL, s.code), s.value, got, s.want)
+				}
+			}
+		})
+	}
+}
@@ -6,33 +6,52 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/clintharrison/bueno/kindle-keymap/actions"
 	"github.com/clintharrison/bueno/kindle-keymap/config"
-	"github.com/clintharrison/bueno/kindle-keymap/lipcaction"
 	"github.com/clintharrison/bueno/quietly"
-	"github.com/clintharrison/bueno/xkb"
 	"github.com/holoplot/go-evdev"
 )
 
 type Watcher struct {
-	x11        *xkb.X11
-	brightness *lipcaction.BrightnessAction
-	rotation   *lipcaction.RotationAction
+	// held is shared by all watched devices, for chords across devices
+	held *heldKeys
+
+	mu sync.Mutex
+	// devices holds the state shared by each device's nodes, by device ID
+	devices map[string]*device
+
+	// LayerChanged, if set, is called whenever a device's active layers change, with the layers
+	// from the top down (so the current layer comes first, and config.BaseLayer last). The device
+	// is identified by its evdev unique ID, like its nodes' InputDevice.UniqueID, or by its node's
+	// path if it has none.
+	LayerChanged func(deviceID string, layers []string)
 }
 
+// eventHandlerTimeout limits how long an action bound to a key may take. Macros are limited by
+// the timeouts of their steps instead.
 const eventHandlerTimeout = 5 * time.Second
 
-func New(x11 *xkb.X11, brightness *lipcaction.BrightnessAction, rotation *lipcaction.RotationAction) *Watcher {
-	return &Watcher{
-		x11:        x11,
-		brightness: brightness,
-		rotation:   rotation,
-	}
+// New makes a watcher. Bindings run registered actions, so actions.Init must have been called.
+func New() *Watcher {
+	return &Watcher{held: newHeldKeys(), devices: make(map[string]*device)}
+}
+
+// deviceState is the state of one watched node: its own axes, and its device's shared state.
+type deviceState struct {
+	node   *node
+	dev    *device
+	axes   *axisMapper
+	analog *analogAxes
+	rel    *relMapper
+	// passthrough is nil unless the device's unbound keys are passed on while it's grabbed
+	passthrough *passthrough
 }
 
+// Watch runs the bindings for an evdev node's events until ctx is done or the node goes away.
+// Nodes with the same unique ID are watched as one device, sharing its layers and key state.
 func (w *Watcher) Watch(ctx context.Context, dev *evdev.InputDevice, cfg *config.Device) {
 	defer quietly.Close(dev)
 	devName, _ := dev.Name()
@@ -42,6 +61,44 @@ func (w *Watcher) Watch(ctx context.Context, dev *evdev.InputDevice, cfg *config
 	if err != nil {
 		slog.Warn("dev.AbsInfos() failed", "devname", devName, "path", dev.Path(), "error", err)
 	}
+	id, err := dev.UniqueID()
+	if err != nil || id == "" {
+		id = dev.Path()
+	}
+	n := &node{path: dev.Path(), cfg: cfg.NodeFor(devName, dev.CapableEvents)}
+	if n.cfg != nil {
+		slog.Info("using node bindings", "devname", devName, "path", dev.Path(), "node", n.cfg)
+	}
+	d := w.join(ctx, id, cfg)
+	defer w.leave(d, n)
+	ctx = withLayers(ctx, d.layers)
+	state := &deviceState{
+		node:   n,
+		dev:    d,
+		axes:   newAxisMapper(cfg, absInfos),
+		analog: newAnalogAxes(ctx, cfg, absInfos),
+		rel:    newRelMapper(cfg, dev.CapableEvents(evdev.EV_REL)),
+	}
+	defer state.analog.stop()
+	if cfg.Grab().Passthrough {
+		state.passthrough, err = newPassthrough(dev, devName)
+		if err != nil {
+			slog.Warn("failed to make a virtual device to pass unbound keys on, they're dropped while the device is grabbed", "devname", devName, "path", dev.Path(), "error", err)
+		}
+		defer state.passthrough.close()
+	}
+	d.grabs.add(n, dev)
+
+	// in non-blocking mode, closing the device interrupts ReadOne, so the watch stops as soon as
+	// ctx is done rather than on the device's next event. Calling dev.Fd() would undo it, and the
+	// evdev methods that do (like Name and AbsInfos) must only be called before this, except for
+	// grabbing, which sets it again.
+	err = dev.NonBlock()
+	if err != nil {
+		slog.Warn("dev.NonBlock() failed, the watch will only stop on the device's next event", "devname", devName, "path", dev.Path(), "error", err)
+	}
+	stopClosing := context.AfterFunc(ctx, func() { quietly.Close(dev) })
+	defer stopClosing()
 
 	for {
 		select {
@@ -50,6 +107,11 @@ func (w *Watcher) Watch(ctx context.Context, dev *evdev.InputDevice, cfg *config
 			return
 		default:
 			ev, err := dev.ReadOne()
+			if err != nil && ctx.Err() != nil {
+				// the device was closed to stop the watch
+				slog.Info("stopping watch on device", "devname", devName, "path", dev.Path())
+				return
+			}
 			if err != nil {
 				// it's normal for bluetooth devices to disconnect - don't log it as an error
 				if errors.Is(err, syscall.ENODEV) {
@@ -59,151 +121,65 @@ func (w *Watcher) Watch(ctx context.Context, dev *evdev.InputDevice, cfg *config
 				slog.Error("unexpected read error on device, stopping watch", "devname", devName, "path", dev.Path(), "error", err)
 				return
 			}
-			eventCtx, cancel := context.WithTimeout(ctx, eventHandlerTimeout)
-			defer cancel()
-			w.handleEvent(eventCtx, ev, cfg, absInfos)
-		}
-	}
-}
-
-var errUnknownEvent = errors.New("unknown event")
-
-// syntheticKeyEventForAbsEvent checks if the given event is an ABS event that we want to remap to a KEY event, and if so returns the remapped event.
-// Not all events will be remapped, which has a sentinel errUnknownEvent to indicate.
-func syntheticKeyEventForAbsEvent(ev *evdev.InputEvent, absInfos map[evdev.EvCode]evdev.AbsInfo) (*evdev.InputEvent, error) {
-	if ev == nil || ev.Type != evdev.EV_ABS || absInfos == nil {
-		return nil, errUnknownEvent
-	}
-	absInfo, ok := absInfos[ev.Code]
-	if !ok {
-		return nil, errUnknownEvent
-	}
-	if absInfo.Minimum == 0 && absInfo.Maximum == 0 {
-		// this axis doesn't have a range, so we can't interpret it
-		return nil, errUnknownEvent
-	}
-
-	newEvent := &evdev.InputEvent{
-		Time: ev.Time,
-		Type: evdev.EV_KEY,
-		// treat it as a key press, since we don't get key release events for ABS
-		Value: 1,
-		Code:  0,
-	}
-	// D-pad on 8bitdo shows ABS_X 127 at rest, 0 when left pressed, 255 when right pressed
-	// and ABS_Y 127 at rest, 0 when up pressed, 255 when down pressed
-	// Since we only get events when the value changes, we
-	switch ev.Code {
-	case evdev.ABS_X, evdev.ABS_HAT0X:
-		switch ev.Value {
-		case absInfo.Minimum:
-			newEvent.Code = evdev.BTN_DPAD_LEFT
-		case absInfo.Maximum:
-			newEvent.Code = evdev.BTN_DPAD_RIGHT
-		}
-	case evdev.ABS_Y, evdev.ABS_HAT0Y:
-		switch ev.Value {
-		case absInfo.Minimum:
-			newEvent.Code = evdev.BTN_DPAD_UP
-		case absInfo.Maximum:
-			newEvent.Code = evdev.BTN_DPAD_DOWN
+			// the watch may have been stopped while we were waiting
+			if ctx.Err() != nil {
+				slog.Info("stopping watch on device", "devname", devName, "path", dev.Path())
+				return
+			}
+			// each action gets its own timeout, see run
+			w.handleEvent(ctx, ev, state)
 		}
 	}
-
-	if newEvent.Code != 0 {
-		return newEvent, nil
-	}
-
-	// if we didn't remap the event, that's not an error
-	return nil, errUnknownEvent
 }
 
-func (w *Watcher) handleEvent(ctx context.Context, ev *evdev.InputEvent, cfg *config.Device, absInfos map[evdev.EvCode]evdev.AbsInfo) {
-	// TODO: handle watcher vs device vs event state better
+func (w *Watcher) handleEvent(ctx context.Context, ev *evdev.InputEvent, state *deviceState) {
 	if ev == nil {
 		return
 	}
 
-	// 8bitdo gamepad sends EV_ABS events for the D-pad, so we'll remap those to equivalent KEY_* events
-	// we don't keep state, so keys are never released
-	syntheticEv, err := syntheticKeyEventForAbsEvent(ev, absInfos)
-	if err != nil && !errors.Is(err, errUnknownEvent) {
-		slog.Error("syntheticKeyEventForAbsEvent()", "error", err)
-		return
-	} else if syntheticEv != nil {
-		ev = syntheticEv
-	}
-
-	if ev.Type == evdev.EV_KEY && ev.Value == 1 { // Key press event
-		keyName := ev.CodeName()
-		mappedAction := cfg.BindingForKey(keyName)
-		if mappedAction == "" {
-			mappedAction = "<unmapped>"
+	switch ev.Type {
+	case evdev.EV_ABS:
+		if state.analog.handle(ev) {
+			return
 		}
-		slog.Info("key pressed", "code", ev.Code, "name", keyName, "mapped_action", mappedAction)
-		switch mappedAction {
-		case actions.NextPage:
-			err := w.x11.KeyPress(xkb.XKPageDown)
-			if err != nil {
-				slog.Error("KeyPress(XKPageDown)", "error", err)
-			}
-		case actions.PrevPage:
-			err := w.x11.KeyPress(xkb.XKPageUp)
-			if err != nil {
-				slog.Error("KeyPress(XKPageUp)", "error", err)
-			}
-		case actions.BrightnessUp:
-			err := w.brightness.IncreaseBrightness(ctx)
-			if err != nil {
-				slog.Error("IncreaseBrightness()", "error", err)
-			}
-		case actions.BrightnessDown:
-			err := w.brightness.DecreaseBrightness(ctx)
-			if err != nil {
-				slog.Error("DecreaseBrightness()", "error", err)
-			}
-		case actions.WarmthUp:
-			err := w.brightness.IncreaseWarmth(ctx)
-			if err != nil {
-				slog.Error("IncreaseWarmth()", "error", err)
-			}
-		case actions.WarmthDown:
-			err := w.brightness.DecreaseWarmth(ctx)
-			if err != nil {
-				slog.Error("DecreaseWarmth()", "error", err)
-			}
-		case actions.RotateCW:
-			err := w.rotation.Rotate(ctx, lipcaction.RotationClockwise)
-			if err != nil {
-				slog.Error("Rotate()", "error", err)
-			}
-		case actions.RotateCCW:
-			err := w.rotation.Rotate(ctx, lipcaction.RotationCounterclockwise)
-			if err != nil {
-				slog.Error("Rotate()", "error", err)
-			}
-		case actions.OrientLockUp:
-			err := w.rotation.SetOrientationLock(ctx, lipcaction.OrientationPortrait)
-			if err != nil {
-				slog.Error("SetOrientationLock()", "error", err)
-			}
-		case actions.OrientLockDown:
-			err := w.rotation.SetOrientationLock(ctx, lipcaction.OrientationPortraitInverted)
-			if err != nil {
-				slog.Error("SetOrientationLock()", "error", err)
-			}
-		case actions.OrientLockLeft:
-			err := w.rotation.SetOrientationLock(ctx, lipcaction.OrientationLandscapeLeft)
-			if err != nil {
-				slog.Error("SetOrientationLock()", "error", err)
-			}
-		case actions.OrientLockRight:
-			err := w.rotation.SetOrientationLock(ctx, lipcaction.OrientationLandscapeRight)
-			if err != nil {
-				slog.Error("SetOrientationLock()", "error", err)
+		// e.g. the 8BitDo gamepad sends EV_ABS events for the D-pad, and gamepads' analog triggers are
+		// axes too, so they're turned into presses and releases of equivalent keys
+		for _, kev := range state.axes.handle(ev) {
+			keyName := evdev.CodeName(evdev.EV_KEY, kev.code)
+			if kev.value == keyPressed {
+				slog.Info("key pressed", "code", kev.code, "name", keyName, "axis", ev.CodeName(), "value", ev.Value)
 			}
-		default:
-			// ignore unmapped keys
+			w.handleKey(ctx, state, kev.code, keyName, kev.value)
+		}
+	case evdev.EV_REL:
+		// wheels and mice move rather than press, so each step far enough is a press and release
+		for _, key := range state.rel.handle(ev) {
+			slog.Info("key pressed", "code", key.code, "name", key.name, "axis", ev.CodeName(), "value", ev.Value)
+			w.handleKey(ctx, state, key.code, key.name, keyPressed)
+			w.handleKey(ctx, state, key.code, key.name, keyReleased)
+		}
+	case evdev.EV_KEY:
+		keyName := ev.CodeName()
+		if ev.Value == keyPressed {
+			slog.Info("key pressed", "code", ev.Code, "name", keyName)
 		}
+		state.passthrough.handle(ev, state.dev.grabs.isGrabbed(), func() bool { return state.dev.layers.bound(state.node, keyName) })
+		w.handleKey(ctx, state, ev.Code, keyName, ev.Value)
+	}
+}
+
+// handleKey passes a key event through the device's sequencer, which hands it on to its
+// keyStates unless it's part of a chord or sequence.
+func (w *Watcher) handleKey(ctx context.Context, state *deviceState, code evdev.EvCode, name string, value int32) {
+	switch value {
+	case keyPressed:
+		held := w.held.names()
+		w.held.press(state.node.path, code, name)
+		state.dev.seq.press(ctx, state.node, code, name, held)
+	case keyReleased:
+		w.held.release(state.node.path, code)
+		state.dev.seq.release(ctx, state.node, code, name)
+	case keyRepeating:
+		// the kernel's autorepeat rate isn't ours to choose, so hold_repeat bindings use their own timer
 	}
 }
@@ -0,0 +1,75 @@
+// Package xkbaction holds key map actions that send key presses to X11 applications.
+package xkbaction
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/clintharrison/bueno/kindle-keymap/actions"
+	"github.com/clintharrison/bueno/xkb"
+)
+
+var (
+	mu  sync.Mutex
+	x11 *xkb.X11
+)
+
+func init() {
+	actions.OnInit(func(env *actions.Env) error {
+		if env.X11 == nil {
+			return errors.New("X11 actions need an X11 connection")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		x11 = env.X11
+		return nil
+	})
+	registerKeyPress(actions.NextPage, "Turn to the next page.", xkb.XKPageDown)
+	registerKeyPress(actions.PrevPage, "Turn to the previous page.", xkb.XKPageUp)
+	actions.Register(actions.Func(actions.Schema{
+		Name: actions.Key,
+		Doc:  "Press a key in the active window, e.g. key:Return.",
+		Params: []actions.Param{{
+			Name: "key",
+			Type: actions.StringParam,
+			Check: func(v any) error {
+				_, err := xkb.KeysymFromName(v.(string))
+				return err
+			},
+			Doc: "the X keysym name of the key, like Page_Down or Return",
+		}},
+		Shorthand: "key",
+	}, func(_ context.Context, params actions.Params) error {
+		keysym, err := xkb.KeysymFromName(params.Str("key"))
+		if err != nil {
+			return err
+		}
+		x, err := display()
+		if err != nil {
+			return err
+		}
+		return x.KeyPress(keysym)
+	}))
+}
+
+// display returns the X11 connection from the latest actions.Init.
+func display() (*xkb.X11, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if x11 == nil {
+		return nil, errors.New("X11 actions are not initialized")
+	}
+	return x11, nil
+}
+
+// registerKeyPress registers an action that presses a key in the active window.
+func registerKeyPress(name, doc string, keysym xkb.XKeysym) {
+	actions.Register(actions.Func(actions.Schema{Name: name, Doc: doc}, func(context.Context, actions.Params) error {
+		x, err := display()
+		if err != nil {
+			return err
+		}
+		return x.KeyPress(keysym)
+	}))
+}
@@ -0,0 +1,56 @@
+package lipc
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Caller makes LIPC property calls and subscriptions. Both a plain bus connection (see Direct)
+// and a Client are Callers, so the typed clients in lipc/services work with either.
+type Caller interface {
+	GetInt(ctx context.Context, service, property string) (int32, error)
+	GetStr(ctx context.Context, service, property string) (string, error)
+	GetHasharray(ctx context.Context, service, property string) (Hasharray, error)
+	SetInt(ctx context.Context, service, property string, v int32) error
+	SetStr(ctx context.Context, service, property string, v string) error
+	SetHasharray(ctx context.Context, service, property string, v Hasharray) error
+	Subscribe(ctx context.Context, service, eventName string) (<-chan Event, error)
+}
+
+// Direct makes a Caller that calls straight through conn, with none of Client's extras.
+func Direct(conn *dbus.Conn) Caller {
+	return directCaller{conn}
+}
+
+type directCaller struct {
+	conn *dbus.Conn
+}
+
+func (d directCaller) GetInt(ctx context.Context, service, property string) (int32, error) {
+	return GetProperty[int32](ctx, d.conn, service, property)
+}
+
+func (d directCaller) GetStr(ctx context.Context, service, property string) (string, error) {
+	return GetProperty[string](ctx, d.conn, service, property)
+}
+
+func (d directCaller) GetHasharray(ctx context.Context, service, property string) (Hasharray, error) {
+	return GetHasharrayProperty(ctx, d.conn, nil, service, property)
+}
+
+func (d directCaller) SetInt(ctx context.Context, service, property string, v int32) error {
+	return SetProperty(ctx, d.conn, service, property, v)
+}
+
+func (d directCaller) SetStr(ctx context.Context, service, property string, v string) error {
+	return SetProperty(ctx, d.conn, service, property, v)
+}
+
+func (d directCaller) SetHasharray(ctx context.Context, service, property string, v Hasharray) error {
+	return SetHasharrayProperty(ctx, d.conn, nil, service, property, v)
+}
+
+func (d directCaller) Subscribe(ctx context.Context, service, eventName string) (<-chan Event, error) {
+	return Subscribe(ctx, d.conn, service, eventName)
+}
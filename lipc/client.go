@@ -0,0 +1,395 @@
+package lipc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ClientOptions tunes a Client. The zero value picks sensible defaults for each field.
+type ClientOptions struct {
+	// Retries is how many times a failed get is retried (default 3). Sets are never retried,
+	// since the service may have acted on a set whose reply got lost.
+	Retries int
+	// Backoff is the delay before the first retry, doubling for each one after (default 100ms).
+	Backoff time.Duration
+	// MaxConcurrent is how many calls to a single service may be in flight at once (default 4),
+	// so a burst of key presses can't pile up calls on a busy service.
+	MaxConcurrent int
+	// SharedMemory is where hasharrays are handed over (default DevShm), e.g. a MemSharedMemory
+	// shared with a fake service in tests.
+	SharedMemory SharedMemory
+}
+
+func (o ClientOptions) withDefaults() ClientOptions {
+	if o.Retries == 0 {
+		o.Retries = 3
+	}
+	if o.Backoff == 0 {
+		o.Backoff = 100 * time.Millisecond
+	}
+	if o.MaxConcurrent == 0 {
+		o.MaxConcurrent = 4
+	}
+	return o
+}
+
+// Client is a long-lived LIPC client for daemons. Unlike calling through a *dbus.Conn directly,
+// it reconnects when the bus connection drops (e.g. across a framework restart), retries gets that
+// failed for transient reasons, caches read-mostly properties, and limits concurrent calls per service.
+type Client struct {
+	connect Connector
+	opts    ClientOptions
+	// ctx lives as long as the client, for its own subscriptions
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	conn   *dbus.Conn
+	limits map[string]chan struct{}
+	cached map[propertyKey]*cacheEntry
+}
+
+type propertyKey struct {
+	service, property string
+}
+
+// cacheEntry is a property set up with Cache. Its fields other than invalidateOn are guarded
+// by Client.mu.
+type cacheEntry struct {
+	invalidateOn []string
+	// subscribing is held while subscribing to invalidateOn, so concurrent gets only do it once
+	subscribing sync.Mutex
+	// subscribed is set once every invalidateOn event is subscribed to; until then nothing is cached
+	subscribed bool
+	value      any
+	hasValue   bool
+	// gen changes whenever the value is dropped, so a get that was in flight at the time
+	// doesn't store what it read before
+	gen uint64
+}
+
+func (e *cacheEntry) drop() {
+	e.value = nil
+	e.hasValue = false
+	e.gen++
+}
+
+// NewClient makes a client that connects with connect (dbus.ConnectSystemBus if nil).
+// It connects lazily, on the first call.
+func NewClient(connect Connector, opts ClientOptions) *Client {
+	if connect == nil {
+		connect = dbus.ConnectSystemBus
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		connect: connect,
+		opts:    opts.withDefaults(),
+		ctx:     ctx,
+		cancel:  cancel,
+		limits:  make(map[string]chan struct{}),
+		cached:  make(map[propertyKey]*cacheEntry),
+	}
+}
+
+var _ Caller = (*Client)(nil)
+
+// Close stops the client's subscriptions and closes its connection.
+func (c *Client) Close() error {
+	c.cancel()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Conn returns the current connection, connecting if there isn't a working one.
+func (c *Client) Conn() (*dbus.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx.Err() != nil {
+		return nil, errors.New("client is closed")
+	}
+	if c.conn != nil && c.conn.Connected() {
+		return c.conn, nil
+	}
+	if c.conn != nil {
+		slog.Info("LIPC bus connection was lost, reconnecting")
+		_ = c.conn.Close()
+		c.conn = nil
+		// the services may have restarted along with the bus, so cached values can't be trusted
+		for _, e := range c.cached {
+			e.drop()
+		}
+	}
+	conn, err := c.connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bus: %w", err)
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// Cache caches property values from service until one of the named events arrives from it.
+// Use it for read-mostly properties like powerd's flMaxIntensity. Sets through the client
+// and reconnects also drop the cached value. The events are subscribed to on the first get,
+// and values are only cached once that has worked.
+func (c *Client) Cache(service, property string, invalidateOn ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cached[propertyKey{service, property}] = &cacheEntry{invalidateOn: invalidateOn}
+}
+
+// subscribeCache subscribes to the events that invalidate key's cached value, if it hasn't yet.
+func (c *Client) subscribeCache(key propertyKey) {
+	c.mu.Lock()
+	e, ok := c.cached[key]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	e.subscribing.Lock()
+	defer e.subscribing.Unlock()
+	c.mu.Lock()
+	subscribed := e.subscribed
+	c.mu.Unlock()
+	if subscribed {
+		return
+	}
+
+	// the subscriptions last as long as the client, unless one of them fails
+	ctx, cancel := context.WithCancel(c.ctx)
+	for _, eventName := range e.invalidateOn {
+		events, err := c.Subscribe(ctx, key.service, eventName)
+		if err != nil {
+			// without the event, a cached value could go stale unnoticed, so the value isn't
+			// cached until a later get manages to subscribe
+			slog.Debug("failed to subscribe to LIPC event, not caching", "service", key.service, "property", key.property, "event", eventName, "error", err)
+			cancel()
+			return
+		}
+		go func() {
+			for ev := range events {
+				slog.Debug("invalidating cached LIPC property", "service", key.service, "property", key.property, "event", ev.Name)
+				c.invalidate(key)
+			}
+		}()
+	}
+	context.AfterFunc(c.ctx, cancel)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.subscribed = true
+}
+
+func (c *Client) invalidate(key propertyKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.cached[key]; ok {
+		e.drop()
+	}
+}
+
+// cachedValue returns key's cached value if there is one, and otherwise the generation to pass
+// to storeValue.
+func (c *Client) cachedValue(key propertyKey) (v any, gen uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, cached := c.cached[key]
+	if !cached {
+		return nil, 0, false
+	}
+	return e.value, e.gen, e.subscribed && e.hasValue
+}
+
+// storeValue caches v for key, unless the value was dropped since the get that read v started.
+func (c *Client) storeValue(key propertyKey, gen uint64, v any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.cached[key]; ok && e.subscribed && e.gen == gen {
+		e.value = v
+		e.hasValue = true
+	}
+}
+
+// acquire waits for a free call slot for service, and returns the func that releases it.
+func (c *Client) acquire(ctx context.Context, service string) (func(), error) {
+	c.mu.Lock()
+	limit, ok := c.limits[service]
+	if !ok {
+		limit = make(chan struct{}, c.opts.MaxConcurrent)
+		c.limits[service] = limit
+	}
+	c.mu.Unlock()
+
+	select {
+	case limit <- struct{}{}:
+		return func() { <-limit }, nil
+	case <-ctx.Done():
+		return nil, newError(ErrTimedOut.Code, service, "", "", "", ctx.Err())
+	}
+}
+
+// isRetryable reports whether a failed get might work if tried again: the service may be
+// restarting, or the connection may have dropped and will be replaced.
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrTimedOut) || errors.Is(err, ErrNoSuchSource) || errors.Is(err, dbus.ErrClosed)
+}
+
+// get calls do (with a working connection) for a get, retrying with backoff and caching the result.
+func get[T any](ctx context.Context, c *Client, service, property string, do func(ctx context.Context, conn *dbus.Conn) (T, error)) (T, error) {
+	key := propertyKey{service, property}
+	c.subscribeCache(key)
+	cv, gen, ok := c.cachedValue(key)
+	if ok {
+		if tv, ok := cv.(T); ok {
+			return tv, nil
+		}
+	}
+
+	release, err := c.acquire(ctx, service)
+	if err != nil {
+		return *new(T), err
+	}
+	defer release()
+
+	backoff := c.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		var v T
+		conn, err := c.Conn()
+		if err == nil {
+			v, err = do(ctx, conn)
+		}
+		if err == nil {
+			c.storeValue(key, gen, v)
+			return v, nil
+		}
+		if attempt >= c.opts.Retries || !isRetryable(err) {
+			return *new(T), err
+		}
+		slog.Debug("retrying LIPC get", "service", service, "property", property, "attempt", attempt+1, "error", err)
+		select {
+		case <-ctx.Done():
+			return *new(T), err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// set calls do (with a working connection) for a set. Sets aren't retried, see ClientOptions.Retries.
+func (c *Client) set(ctx context.Context, service, property string, do func(ctx context.Context, conn *dbus.Conn) error) error {
+	release, err := c.acquire(ctx, service)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// gets in flight before the set finishes may have read the old value, so the cached value
+	// is dropped on both sides of it
+	key := propertyKey{service, property}
+	c.invalidate(key)
+	defer c.invalidate(key)
+	conn, err := c.Conn()
+	if err != nil {
+		return err
+	}
+	return do(ctx, conn)
+}
+
+func (c *Client) GetInt(ctx context.Context, service, property string) (int32, error) {
+	return get(ctx, c, service, property, func(ctx context.Context, conn *dbus.Conn) (int32, error) {
+		return GetProperty[int32](ctx, conn, service, property)
+	})
+}
+
+func (c *Client) GetStr(ctx context.Context, service, property string) (string, error) {
+	return get(ctx, c, service, property, func(ctx context.Context, conn *dbus.Conn) (string, error) {
+		return GetProperty[string](ctx, conn, service, property)
+	})
+}
+
+func (c *Client) GetHasharray(ctx context.Context, service, property string) (Hasharray, error) {
+	return get(ctx, c, service, property, func(ctx context.Context, conn *dbus.Conn) (Hasharray, error) {
+		return GetHasharrayProperty(ctx, conn, c.opts.SharedMemory, service, property)
+	})
+}
+
+func (c *Client) SetInt(ctx context.Context, service, property string, v int32) error {
+	return c.set(ctx, service, property, func(ctx context.Context, conn *dbus.Conn) error {
+		return SetProperty(ctx, conn, service, property, v)
+	})
+}
+
+func (c *Client) SetStr(ctx context.Context, service, property string, v string) error {
+	return c.set(ctx, service, property, func(ctx context.Context, conn *dbus.Conn) error {
+		return SetProperty(ctx, conn, service, property, v)
+	})
+}
+
+func (c *Client) SetHasharray(ctx context.Context, service, property string, v Hasharray) error {
+	return c.set(ctx, service, property, func(ctx context.Context, conn *dbus.Conn) error {
+		return SetHasharrayProperty(ctx, conn, c.opts.SharedMemory, service, property, v)
+	})
+}
+
+// Subscribe is like the package-level Subscribe, but survives reconnects: when the connection
+// drops, it subscribes again on the new one. Events sent while reconnecting are lost.
+func (c *Client) Subscribe(ctx context.Context, service, eventName string) (<-chan Event, error) {
+	conn, err := c.Conn()
+	if err != nil {
+		return nil, err
+	}
+	events, err := Subscribe(ctx, conn, service, eventName)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Event, cap(events))
+	go func() {
+		defer close(out)
+		backoff := c.opts.Backoff
+		for {
+			for ev := range events {
+				select {
+				case out <- ev:
+				default:
+					slog.Warn("dropping LIPC event, subscriber is not keeping up", "event", ev)
+				}
+			}
+			// the subscription ended, either because we're done or because the connection dropped
+			for {
+				if ctx.Err() != nil || c.ctx.Err() != nil {
+					return
+				}
+				conn, err := c.Conn()
+				if err == nil {
+					events, err = Subscribe(ctx, conn, service, eventName)
+				}
+				if err == nil {
+					slog.Debug("resubscribed to LIPC event", "service", service, "event", eventName)
+					backoff = c.opts.Backoff
+					break
+				}
+				slog.Debug("failed to resubscribe to LIPC event", "service", service, "event", eventName, "error", err)
+				select {
+				case <-ctx.Done():
+				case <-c.ctx.Done():
+				case <-time.After(backoff):
+				}
+				// keep trying while the bus is down, but not too eagerly
+				backoff = min(backoff*2, 10*time.Second)
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package lipc_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/clintharrison/bueno/lipc/lipctest"
+	"github.com/godbus/dbus/v5"
+)
+
+const testService = "com.bueno.test"
+
+func TestClientCache(t *testing.T) {
+	t.Parallel()
+	bus, err := lipctest.NewBus()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = bus.Close() })
+	fake, err := lipctest.NewService(testService, bus.Connect)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	t.Cleanup(func() { _ = fake.Close() })
+	if err := fake.AddInt("level", 1, true); err != nil {
+		t.Fatalf("AddInt() error = %v", err)
+	}
+
+	var dials atomic.Int32
+	client := lipc.NewClient(func(opts ...dbus.ConnOption) (*dbus.Conn, error) {
+		dials.Add(1)
+		return bus.Connect(opts...)
+	}, lipc.ClientOptions{})
+	t.Cleanup(func() { _ = client.Close() })
+	client.Cache(testService, "level", "levelChanged")
+	if n := dials.Load(); n != 0 {
+		t.Errorf("Cache() connected %d times, want it to wait for the first get", n)
+	}
+
+	ctx := context.Background()
+	getLevel := func() int32 {
+		t.Helper()
+		v, err := client.GetInt(ctx, testService, "level")
+		if err != nil {
+			t.Fatalf("GetInt() error = %v", err)
+		}
+		return v
+	}
+	if got := getLevel(); got != 1 {
+		t.Errorf("GetInt() = %d, want 1", got)
+	}
+	fake.SetInt("level", 2)
+	if got := getLevel(); got != 1 {
+		t.Errorf("GetInt() = %d, want the cached 1", got)
+	}
+
+	if err := fake.SendEvent("levelChanged"); err != nil {
+		t.Fatalf("SendEvent() error = %v", err)
+	}
+	// the event is handled asynchronously
+	deadline := time.Now().Add(5 * time.Second)
+	for getLevel() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("GetInt() still returns the cached value after the invalidating event")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := client.SetInt(ctx, testService, "level", 5); err != nil {
+		t.Fatalf("SetInt() error = %v", err)
+	}
+	if got := getLevel(); got != 5 {
+		t.Errorf("GetInt() after SetInt() = %d, want 5", got)
+	}
+}
+
+func TestClientUncached(t *testing.T) {
+	t.Parallel()
+	bus, err := lipctest.NewBus()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = bus.Close() })
+	fake, err := lipctest.NewService(testService, bus.Connect)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	t.Cleanup(func() { _ = fake.Close() })
+	if err := fake.AddStr("name", "a", false); err != nil {
+		t.Fatalf("AddStr() error = %v", err)
+	}
+
+	client := lipc.NewClient(bus.Connect, lipc.ClientOptions{})
+	t.Cleanup(func() { _ = client.Close() })
+	for _, want := range []string{"a", "b"} {
+		fake.SetStr("name", want)
+		got, err := client.GetStr(context.Background(), testService, "name")
+		if err != nil {
+			t.Fatalf("GetStr() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("GetStr() = %q, want %q", got, want)
+		}
+	}
+}
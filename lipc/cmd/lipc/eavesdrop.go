@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/clintharrison/bueno/lipc/record"
+	"github.com/clintharrison/bueno/quietly"
+	"github.com/godbus/dbus/v5"
+)
+
+// runEavesdrop prints or records every message on the bus until interrupted.
+// Monitoring takes over a connection entirely, so the recorder gets its own rather than using conn.
+func runEavesdrop(ctx context.Context, _ *dbus.Conn, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("eavesdrop", &common)
+	out := fs.String("o", "", "write the recording to this file (as JSON lines)")
+	_, err := parseArgs(fs, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer quietly.Close(f)
+		w = f
+	}
+
+	recorder, err := record.NewRecorder(connectBus, w)
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(recorder)
+	if !common.json && *out == "" {
+		recorder.Filter = func(r record.Record) bool {
+			fmt.Println(formatRecord(r))
+			return false
+		}
+	}
+
+	slog.Info("listening for messages")
+	return recorder.Run(ctx)
+}
+
+// formatRecord prints the interesting parts of a message on one or two lines, like dbus-monitor.
+func formatRecord(r record.Record) string {
+	var sb strings.Builder
+	sb.WriteString(r.Type)
+	for _, name := range []string{"Sender", "Destination", "Path", "Interface", "Member", "ErrorName", "ReplySerial"} {
+		if v, ok := r.Headers[name]; ok {
+			fmt.Fprintf(&sb, " %s=%s", name, v)
+		}
+	}
+	switch {
+	case r.Event != "":
+		fmt.Fprintf(&sb, " (event %s.%s)", r.Service, r.Event)
+	case r.Property != "":
+		fmt.Fprintf(&sb, " (%s %s.%s", r.Op, r.Service, r.Property)
+		if r.Status != "" {
+			fmt.Fprintf(&sb, ": %s", r.Status)
+		}
+		sb.WriteString(")")
+	}
+	if len(r.Body) > 0 {
+		fmt.Fprintf(&sb, "\n  %v", r.Body)
+	}
+	return sb.String()
+}
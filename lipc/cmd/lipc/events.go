@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+type eventResult struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	Event   string    `json:"event"`
+	Params  []any     `json:"params"`
+}
+
+// formatEvent prints an event the way lipc-wait-event does: the name, then each param, with strings quoted.
+func formatEvent(ev lipc.Event) string {
+	parts := []string{ev.Name}
+	for _, p := range ev.Params {
+		if s, ok := p.(string); ok {
+			parts = append(parts, strconv.Quote(s))
+		} else {
+			parts = append(parts, fmt.Sprint(p))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func runWaitEvent(ctx context.Context, conn *dbus.Conn, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("wait-event", &common)
+	multiple := fs.Bool("m", false, "keep waiting for events instead of exiting after the first")
+	seconds := fs.Int("s", 0, "give up after this many seconds (0 waits forever)")
+	rest, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	service := rest[0]
+	eventNames := strings.Split(rest[1], ",")
+
+	if *seconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(*seconds)*time.Second)
+		defer cancel()
+	}
+
+	subscribeTo := eventNames[0]
+	if len(eventNames) > 1 {
+		subscribeTo = lipc.AllEvents
+	}
+	events, err := lipc.Subscribe(ctx, conn, service, subscribeTo)
+	if err != nil {
+		return err
+	}
+	for ev := range events {
+		if !slices.Contains(eventNames, lipc.AllEvents) && !slices.Contains(eventNames, ev.Name) {
+			continue
+		}
+		if common.json {
+			err := printJSON(eventResult{Time: time.Now(), Service: ev.Service, Event: ev.Name, Params: ev.Params})
+			if err != nil {
+				return err
+			}
+		} else {
+			fmt.Println(formatEvent(ev))
+		}
+		if !*multiple {
+			return nil
+		}
+	}
+	// the subscription only ends when ctx is done
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return lipc.ErrTimedOut
+	}
+	return nil
+}
+
+// runSendEvent parses its own args, since -i and -s can be repeated and their order is the param order.
+func runSendEvent(_ context.Context, conn *dbus.Conn, args []string) error {
+	var params []any
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-i", "-s":
+			if i+1 >= len(args) {
+				return &exitError{code: 2, err: fmt.Errorf("%s needs a value", args[i])}
+			}
+			value := args[i+1]
+			if args[i] == "-i" {
+				n, err := strconv.ParseInt(value, 0, 32)
+				if err != nil {
+					return &exitError{code: 2, err: fmt.Errorf("invalid int param %q: %w", value, err)}
+				}
+				params = append(params, int32(n))
+			} else {
+				params = append(params, value)
+			}
+			i++
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	if len(positional) != 2 {
+		return &exitError{code: 2, err: errors.New("usage: send-event [-i int] [-s str]... <service> <event>")}
+	}
+	service, event := positional[0], positional[1]
+	return conn.Emit("/default", service+"."+event, params...)
+}
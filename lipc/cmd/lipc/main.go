@@ -0,0 +1,165 @@
+// lipc is a single binary replacement for the stock lipc-get-prop, lipc-set-prop, lipc-probe,
+// lipc-wait-event and lipc-send-event tools, with optional JSON output for scripts.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/clintharrison/bueno/core/logutil"
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/clintharrison/bueno/quietly"
+	"github.com/godbus/dbus/v5"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// command is a single subcommand, e.g. "lipc get".
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, conn *dbus.Conn, args []string) error
+}
+
+// commands is a function rather than a var, since the commands' flag sets refer back to it for usage.
+func commands() []command {
+	return []command{
+		{"get", "get [-i|-s|-h] [-json] [-timeout d] <service> <property>", runGet},
+		{"set", "set [-i|-s] [-json] [-timeout d] <service> <property> <value>", runSet},
+		{"probe", "probe [-json] [-timeout d] [-v] [-a | <service>...]", runProbe},
+		{"wait-event", "wait-event [-m] [-s seconds] [-json] <service> <event>[,<event>...]", runWaitEvent},
+		{"send-event", "send-event [-i int] [-s str]... <service> <event>", runSendEvent},
+		{"eavesdrop", "eavesdrop [-json] [-o file]", runEavesdrop},
+		{"replay", "replay [-events] [-realtime] <file>", runReplay},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [args]\n\ncommands:\n", os.Args[0])
+	for _, c := range commands() {
+		fmt.Fprintf(os.Stderr, "  %s\n", c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nSet LIPC_BUS=session to use the session bus instead of the system bus.\n")
+}
+
+// connectBus connects to the bus selected by $LIPC_BUS, which is useful for trying things out
+// against a replayed recording off the device.
+func connectBus(opts ...dbus.ConnOption) (*dbus.Conn, error) {
+	if os.Getenv("LIPC_BUS") == "session" {
+		return dbus.ConnectSessionBus(opts...)
+	}
+	return dbus.ConnectSystemBus(opts...)
+}
+
+// exitError carries a specific exit status, e.g. the lipcErr code of a failed call like the stock tools use.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func main() {
+	err := doMain()
+	if err != nil {
+		slog.Error("Application error", "error", err)
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		var lipcErr *lipc.Error
+		if errors.As(err, &lipcErr) && lipcErr.Code != 0 {
+			os.Exit(int(lipcErr.Code))
+		}
+		os.Exit(1)
+	}
+}
+
+func doMain() error {
+	ctx := context.Background()
+	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	logutil.ConfigureInteractiveLogger()
+
+	if len(os.Args) < 2 {
+		usage()
+		return &exitError{code: 2, err: errors.New("no command given")}
+	}
+	name := os.Args[1]
+	for _, c := range commands() {
+		if c.name != name {
+			continue
+		}
+		conn, err := connectBus()
+		if err != nil {
+			return fmt.Errorf("failed to connect to bus: %w", err)
+		}
+		defer quietly.Close(conn)
+		return c.run(ctx, conn, os.Args[2:])
+	}
+	usage()
+	return &exitError{code: 2, err: fmt.Errorf("unknown command %q", name)}
+}
+
+// commonFlags are the flags shared by most commands.
+type commonFlags struct {
+	json    bool
+	timeout time.Duration
+}
+
+// newCallFlagSet makes the flag set of a command that calls a service, with -json and -timeout.
+func newCallFlagSet(name string, common *commonFlags) *flag.FlagSet {
+	fs := newFlagSet(name, common)
+	fs.DurationVar(&common.timeout, "timeout", defaultTimeout, "how long to wait for the service to reply")
+	return fs
+}
+
+// newFlagSet makes the flag set of a command with -json. Commands that wait on events rather
+// than replies have their own way to stop waiting, like wait-event's -s, so they have no -timeout.
+func newFlagSet(name string, common *commonFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.BoolVar(&common.json, "json", false, "print JSON instead of plain text")
+	fs.Usage = func() {
+		for _, c := range commands() {
+			if c.name == name {
+				fmt.Fprintf(os.Stderr, "usage: %s %s\n", os.Args[0], c.usage)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags and checks the number of positional args.
+func parseArgs(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, &exitError{code: 2, err: err}
+	}
+	rest := fs.Args()
+	if len(rest) < minArgs || (maxArgs >= 0 && len(rest) > maxArgs) {
+		fs.Usage()
+		return nil, &exitError{code: 2, err: fmt.Errorf("%s: wrong number of arguments", fs.Name())}
+	}
+	return rest, nil
+}
+
+// printJSON writes v to stdout as a single line of JSON.
+func printJSON(v any) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
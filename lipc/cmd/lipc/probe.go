@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+type probeResult struct {
+	Service    string              `json:"service"`
+	Running    bool                `json:"running"`
+	Properties []lipc.PropertyInfo `json:"properties,omitempty"`
+}
+
+func runProbe(ctx context.Context, conn *dbus.Conn, args []string) error {
+	var common commonFlags
+	fs := newCallFlagSet("probe", &common)
+	all := fs.Bool("a", false, "list every service on the bus, not just com.lab126.*")
+	verbose := fs.Bool("v", false, "also list each service's properties")
+	services, err := parseArgs(fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, common.timeout)
+	defer cancel()
+
+	// explicitly named services are looked up among all names, not just com.lab126.*
+	prefix := lipc.Lab126Prefix
+	if *all || len(services) > 0 {
+		prefix = ""
+	}
+	names, err := lipc.ListServices(ctx, conn, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list services: %w", err)
+	}
+
+	var results []probeResult
+	if len(services) == 0 {
+		for _, n := range names {
+			results = append(results, probeResult{Service: n, Running: true})
+		}
+	} else {
+		for _, s := range services {
+			_, running := slices.BinarySearch(names, s)
+			results = append(results, probeResult{Service: s, Running: running})
+		}
+	}
+
+	if *verbose {
+		for i, r := range results {
+			if !r.Running {
+				continue
+			}
+			props, err := lipc.IntrospectService(ctx, conn, r.Service)
+			if err != nil {
+				// some services don't support introspection, which shouldn't hide the rest
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Service, err)
+				continue
+			}
+			results[i].Properties = props
+		}
+	}
+
+	if common.json {
+		return printJSON(results)
+	}
+	for _, r := range results {
+		if !r.Running {
+			fmt.Printf("%s (not running)\n", r.Service)
+			continue
+		}
+		fmt.Println(r.Service)
+		for _, p := range r.Properties {
+			fmt.Printf("\t%-3s %-3s %s\n", p.Type, p.Access(), p.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/clintharrison/bueno/lipc/record"
+	"github.com/clintharrison/bueno/quietly"
+	"github.com/godbus/dbus/v5"
+)
+
+// runReplay serves a recording made with "eavesdrop -o" until interrupted.
+func runReplay(ctx context.Context, _ *dbus.Conn, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("replay", &common)
+	events := fs.Bool("events", false, "also send the recorded events, once the services are up")
+	realtime := fs.Bool("realtime", false, "send events with the recorded timing, instead of all at once")
+	rest, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(f)
+	records, err := record.Load(f)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", rest[0], err)
+	}
+	replayer, err := record.NewReplayer(records)
+	if err != nil {
+		return err
+	}
+
+	err = replayer.Start(connectBus)
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(replayer)
+	slog.Info("replaying", "services", replayer.Services())
+
+	if *events {
+		err = replayer.ReplaySignals(ctx, *realtime)
+		if err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,125 @@
+package lipc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Error is a failed LIPC property operation, carrying the lipcErr status code from the reply.
+//
+// Use errors.Is with the sentinels below to check for a specific code, regardless of service or property:
+//
+//	if errors.Is(err, lipc.ErrNoSuchProperty) { ... }
+type Error struct {
+	// Code is the lipcErr status code, see NameForLipcError.
+	Code uint32
+	// Name is the name of the code, e.g. "lipcErrNoSuchProperty".
+	Name     string
+	Service  string
+	Property string
+	Op       Op
+	Type     PropType
+	// Err is the underlying D-Bus error, for failures that didn't come with an LIPC status.
+	Err error
+}
+
+// Op is the operation an Error came from. Property gets and sets use the same names as their
+// PropMessageType, so an Error reads like the method that failed, e.g. "getflIntensityInt".
+type Op string
+
+const (
+	OpGet        Op = "get"
+	OpSet        Op = "set"
+	OpIntrospect Op = "introspect"
+)
+
+func newError(code uint32, service, property string, op Op, propType PropType, err error) *Error {
+	return &Error{
+		Code:     code,
+		Name:     NameForLipcError(code),
+		Service:  service,
+		Property: property,
+		Op:       op,
+		Type:     propType,
+		Err:      err,
+	}
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s (%d)", e.Name, e.Code)
+	if e.Service != "" {
+		msg = fmt.Sprintf("%s%s%s on %s: %s", e.Op, e.Property, e.Type, e.Service, msg)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code. Only the code is compared,
+// so the sentinels match errors from any service or property.
+func (e *Error) Is(target error) bool {
+	var t *Error
+	if !errors.As(target, &t) {
+		return false
+	}
+	return t.Code == e.Code
+}
+
+func sentinel(code uint32) *Error {
+	return &Error{Code: code, Name: NameForLipcError(code)}
+}
+
+var (
+	ErrUnknown               = sentinel(1)
+	ErrInternal              = sentinel(2)
+	ErrNoSuchSource          = sentinel(3)
+	ErrOperationNotSupported = sentinel(4)
+	ErrNoSuchParam           = sentinel(7)
+	ErrNoSuchProperty        = sentinel(8)
+	ErrAccessNotAllowed      = sentinel(9)
+	ErrInvalidArg            = sentinel(0xc)
+	ErrOperationNotAllowed   = sentinel(0xd)
+	ErrTimedOut              = sentinel(0xf)
+)
+
+// codeForDBusError maps a D-Bus error from a property call to the lipcErr code liblipc would return,
+// so callers can handle e.g. a missing service the same way whether or not it went through liblipc.
+func codeForDBusError(err error) uint32 {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimedOut.Code
+	}
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) {
+		switch dbusErr.Name {
+		case "org.freedesktop.DBus.Error.ServiceUnknown", "org.freedesktop.DBus.Error.NameHasNoOwner":
+			return ErrNoSuchSource.Code
+		case "org.freedesktop.DBus.Error.UnknownMethod":
+			return ErrNoSuchProperty.Code
+		case "org.freedesktop.DBus.Error.NoReply", "org.freedesktop.DBus.Error.Timeout":
+			return ErrTimedOut.Code
+		case "org.freedesktop.DBus.Error.AccessDenied":
+			return ErrAccessNotAllowed.Code
+		case "org.freedesktop.DBus.Error.InvalidArgs":
+			return ErrInvalidArg.Code
+		}
+	}
+	return ErrUnknown.Code
+}
+
+// statusForError picks the status code a Server replies with when a property handler fails.
+// Handlers can return one of the sentinels (or an *Error) to choose the code.
+func statusForError(err error) uint32 {
+	var lipcErr *Error
+	if errors.As(err, &lipcErr) && lipcErr.Code != 0 {
+		return lipcErr.Code
+	}
+	return ErrInternal.Code
+}
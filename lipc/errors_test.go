@@ -0,0 +1,23 @@
+package lipc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorString(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		err  *Error
+		want string
+	}{
+		{newError(8, "com.lab126.powerd", "flIntensity", OpGet, IntProp, nil), "getflIntensityInt on com.lab126.powerd: lipcErrNoSuchProperty (8)"},
+		{newError(8, "com.lab126.wifid", "scanList", OpSet, HasProp, nil), "setscanListHas on com.lab126.wifid: lipcErrNoSuchProperty (8)"},
+		{newError(3, "com.lab126.powerd", "", OpIntrospect, "", errors.New("no owner")), "introspect on com.lab126.powerd: lipcErrNoSuchSource (3): no owner"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
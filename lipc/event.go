@@ -0,0 +1,129 @@
+package lipc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// AllEvents can be passed as the event name to Subscribe to receive every event from a service.
+const AllEvents = "*"
+
+// Event is an LIPC event, as sent by e.g. lipc-send-event or a service like com.lab126.powerd.
+// On the bus, these are D-Bus signals on /default whose interface is the sending service's name.
+type Event struct {
+	Service string
+	Name    string
+	// Params are the event parameters in order. LIPC only supports int32 and string parameters.
+	Params []any
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s.%s%v", e.Service, e.Name, e.Params)
+}
+
+// Int returns the i-th parameter, if it's an int parameter.
+func (e Event) Int(i int) (int32, error) {
+	return eventParam[int32](e, i)
+}
+
+// Str returns the i-th parameter, if it's a string parameter.
+func (e Event) Str(i int) (string, error) {
+	return eventParam[string](e, i)
+}
+
+func eventParam[T int32 | string](e Event, i int) (T, error) {
+	if i < 0 || i >= len(e.Params) {
+		return *new(T), fmt.Errorf("event %s.%s has %d params, no param %d", e.Service, e.Name, len(e.Params), i)
+	}
+	v, ok := e.Params[i].(T)
+	if !ok {
+		return *new(T), fmt.Errorf("event %s.%s param %d is %T, not %T", e.Service, e.Name, i, e.Params[i], *new(T))
+	}
+	return v, nil
+}
+
+// eventFromSignal converts a D-Bus signal to an Event, or returns false if it isn't an LIPC event.
+func eventFromSignal(sig *dbus.Signal) (Event, bool) {
+	if sig == nil || sig.Path != "/default" {
+		return Event{}, false
+	}
+	i := strings.LastIndex(sig.Name, ".")
+	if i == -1 {
+		return Event{}, false
+	}
+	return Event{
+		Service: sig.Name[:i],
+		Name:    sig.Name[i+1:],
+		Params:  sig.Body,
+	}, true
+}
+
+func eventMatchOptions(service, eventName string) []dbus.MatchOption {
+	opts := []dbus.MatchOption{
+		dbus.WithMatchObjectPath("/default"),
+		dbus.WithMatchInterface(service),
+	}
+	if eventName != AllEvents && eventName != "" {
+		opts = append(opts, dbus.WithMatchMember(eventName))
+	}
+	return opts
+}
+
+// Subscribe listens for the named event from service, like lipc-wait-event.
+// Passing AllEvents (or "") as the event name subscribes to every event the service sends.
+//
+// The returned channel receives events until ctx is cancelled or the connection is closed, after
+// which the subscription is removed and the channel is closed. Events are dropped (and logged) if
+// the receiver falls behind.
+func Subscribe(ctx context.Context, conn *dbus.Conn, service, eventName string) (<-chan Event, error) {
+	opts := eventMatchOptions(service, eventName)
+	err := conn.AddMatchSignalContext(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s.%s: %w", service, eventName, err)
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	events := make(chan Event, 16)
+
+	go func() {
+		defer close(events)
+		defer func() {
+			conn.RemoveSignal(signals)
+			// ctx is already done, so removing the match needs its own
+			err := conn.RemoveMatchSignal(opts...)
+			if err != nil {
+				slog.Debug("failed to remove event match", "service", service, "event", eventName, "error", err)
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				// the channel is closed when the connection is
+				if !ok {
+					return
+				}
+				// every channel registered with conn.Signal gets every signal, so filter them here
+				ev, ok := eventFromSignal(sig)
+				if !ok || ev.Service != service {
+					continue
+				}
+				if eventName != AllEvents && eventName != "" && ev.Name != eventName {
+					continue
+				}
+				select {
+				case events <- ev:
+				default:
+					slog.Warn("dropping LIPC event, subscriber is not keeping up", "event", ev)
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,272 @@
+package lipc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// HasProp is the property type for hasharrays: "getwifiConfigsHas", "setcmdHas".
+const HasProp PropType = "Has"
+
+// Hasharray is an LIPC hasharray: an array of hashes, each mapping keys to int32, string or []byte values.
+//
+// Hasharrays are too big to send in a D-Bus message body, so instead the sender serializes them
+// into a POSIX shared memory segment and sends the segment's key. See SharedMemory.
+type Hasharray = []map[string]any
+
+// Value types in a serialized hasharray, matching liblipc's LIPC_HASHARRAY_* enum.
+const (
+	hasharrayInt    uint32 = 0
+	hasharrayString uint32 = 1
+	hasharrayBlob   uint32 = 2
+)
+
+// TODO: this layout (little-endian, length-prefixed, no padding) has only been checked against
+// segments we wrote ourselves. Verify it against liblipc's LipcHasharrayKeep on a device.
+//
+//	u32 hash count
+//	for each hash:
+//	  u32 key count
+//	  for each key:
+//	    u32 key length, key bytes
+//	    u32 value type
+//	    int:         i32 value
+//	    string/blob: u32 length, value bytes
+var hasharrayByteOrder = binary.LittleEndian
+
+func encodeHasharray(ha Hasharray) ([]byte, error) {
+	var buf bytes.Buffer
+	writeU32 := func(v uint32) {
+		_ = binary.Write(&buf, hasharrayByteOrder, v)
+	}
+	writeBytes := func(b []byte) {
+		writeU32(uint32(len(b))) //#nosec G115 -- bounded by the segment size
+		buf.Write(b)
+	}
+
+	writeU32(uint32(len(ha))) //#nosec G115
+	for i, hash := range ha {
+		writeU32(uint32(len(hash))) //#nosec G115
+		// sort the keys so encoding is deterministic
+		keys := make([]string, 0, len(hash))
+		for k := range hash {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			writeBytes([]byte(k))
+			switch v := hash[k].(type) {
+			case int32:
+				writeU32(hasharrayInt)
+				_ = binary.Write(&buf, hasharrayByteOrder, v)
+			case string:
+				writeU32(hasharrayString)
+				writeBytes([]byte(v))
+			case []byte:
+				writeU32(hasharrayBlob)
+				writeBytes(v)
+			default:
+				return nil, fmt.Errorf("unsupported hasharray value type %T for key %q in hash %d", v, k, i)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+var errTruncatedHasharray = errors.New("hasharray segment is truncated")
+
+func decodeHasharray(data []byte) (Hasharray, error) {
+	r := bytes.NewReader(data)
+	readU32 := func() (uint32, error) {
+		var v uint32
+		err := binary.Read(r, hasharrayByteOrder, &v)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, errTruncatedHasharray
+		}
+		return v, err
+	}
+	readBytes := func() ([]byte, error) {
+		n, err := readU32()
+		if err != nil {
+			return nil, err
+		}
+		if int64(n) > int64(r.Len()) {
+			return nil, errTruncatedHasharray
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return b, err
+	}
+
+	hashCount, err := readU32()
+	if err != nil {
+		return nil, err
+	}
+	// every hash takes at least 4 bytes, so this bounds the allocation on a corrupt count
+	if int64(hashCount)*4 > int64(r.Len()) {
+		return nil, errTruncatedHasharray
+	}
+	ha := make(Hasharray, 0, hashCount)
+	for range hashCount {
+		keyCount, err := readU32()
+		if err != nil {
+			return nil, err
+		}
+		hash := make(map[string]any)
+		for range keyCount {
+			key, err := readBytes()
+			if err != nil {
+				return nil, err
+			}
+			valueType, err := readU32()
+			if err != nil {
+				return nil, err
+			}
+			switch valueType {
+			case hasharrayInt:
+				var v int32
+				err := binary.Read(r, hasharrayByteOrder, &v)
+				if err != nil {
+					return nil, errTruncatedHasharray
+				}
+				hash[string(key)] = v
+			case hasharrayString:
+				v, err := readBytes()
+				if err != nil {
+					return nil, err
+				}
+				hash[string(key)] = string(v)
+			case hasharrayBlob:
+				v, err := readBytes()
+				if err != nil {
+					return nil, err
+				}
+				hash[string(key)] = v
+			default:
+				return nil, fmt.Errorf("unknown hasharray value type %d for key %q", valueType, key)
+			}
+		}
+		ha = append(ha, hash)
+	}
+	return ha, nil
+}
+
+// writeHasharray serializes ha into a new segment in mem, and returns its key.
+func writeHasharray(mem SharedMemory, ha Hasharray) (string, error) {
+	data, err := encodeHasharray(ha)
+	if err != nil {
+		return "", err
+	}
+	key, err := mem.Write(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to write hasharray segment: %w", err)
+	}
+	return key, nil
+}
+
+// readHasharray reads the hasharray in mem's segment with the given key. The segment is handed
+// over to the reader, so it's removed afterwards, even if it can't be decoded.
+func readHasharray(mem SharedMemory, key string) (Hasharray, error) {
+	data, err := mem.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hasharray segment %q: %w", key, err)
+	}
+	defer func() {
+		err := mem.Remove(key)
+		if err != nil {
+			slog.Debug("failed to remove hasharray segment", "key", key, "error", err)
+		}
+	}()
+	return decodeHasharray(data)
+}
+
+// GetHasharrayProperty reads a hasharray property, like `lipc-hash-prop service property`.
+// The service hands it over in a segment of mem (DevShm if nil).
+func GetHasharrayProperty(ctx context.Context, conn *dbus.Conn, mem SharedMemory, service, property string) (Hasharray, error) {
+	msg, err := makePropertyMessage[string](GetProp, service, property, HasProp)
+	if err != nil {
+		return nil, err
+	}
+	call := <-conn.SendWithContext(ctx, msg, make(chan *dbus.Call, 1)).Done
+	if call.Err != nil {
+		return nil, newError(codeForDBusError(call.Err), service, property, OpGet, HasProp, call.Err)
+	}
+	var status uint32
+	var key string
+	err = call.Store(&status, &key)
+	if err != nil {
+		return nil, err
+	}
+	if status != 0 {
+		return nil, newError(status, service, property, OpGet, HasProp, nil)
+	}
+	return readHasharray(sharedMemoryOrDefault(mem), key)
+}
+
+// SetHasharrayProperty writes a hasharray property, handing it to the service in a segment of
+// mem (DevShm if nil). Values in each hash must be int32, string or []byte.
+func SetHasharrayProperty(ctx context.Context, conn *dbus.Conn, mem SharedMemory, service, property string, value Hasharray) error {
+	mem = sharedMemoryOrDefault(mem)
+	key, err := writeHasharray(mem, value)
+	if err != nil {
+		return err
+	}
+	// the service copies the segment before replying, so we can always remove it afterwards
+	defer func() {
+		err := mem.Remove(key)
+		if err != nil {
+			slog.Debug("failed to remove hasharray segment", "key", key, "error", err)
+		}
+	}()
+
+	msg, err := makePropertyMessage(SetProp, service, property, HasProp, key)
+	if err != nil {
+		return err
+	}
+	call := <-conn.SendWithContext(ctx, msg, make(chan *dbus.Call, 1)).Done
+	if call.Err != nil {
+		return newError(codeForDBusError(call.Err), service, property, OpSet, HasProp, call.Err)
+	}
+	var status uint32
+	err = call.Store(&status)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return newError(status, service, property, OpSet, HasProp, nil)
+	}
+	return nil
+}
+
+// FormatHasharray renders a hasharray like lipc-hash-prop does, for logging and CLI output.
+func FormatHasharray(ha Hasharray) string {
+	var sb strings.Builder
+	for i, hash := range ha {
+		keys := make([]string, 0, len(hash))
+		for k := range hash {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		sb.WriteString("{ ")
+		for j, k := range keys {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "%s = %v", k, hash[k])
+		}
+		sb.WriteString(" }")
+		if i < len(ha)-1 {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,128 @@
+package lipc_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/clintharrison/bueno/lipc/lipctest"
+	"github.com/clintharrison/bueno/lipc/services/wifid"
+	"github.com/godbus/dbus/v5"
+)
+
+// hasharrayService fakes a service with a hasharray property, passing segments through mem
+// the way liblipc does: it copies the segment it's given on a set, and hands over a new one
+// on a get.
+type hasharrayService struct {
+	mem *lipc.MemSharedMemory
+
+	mu   sync.Mutex
+	data []byte
+}
+
+func newHasharrayService(t *testing.T, bus *lipctest.Bus, service, property string, mem *lipc.MemSharedMemory) *hasharrayService {
+	t.Helper()
+	conn, err := bus.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	if _, err := conn.RequestName(service, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+	s := &hasharrayService{mem: mem}
+	err = conn.ExportMethodTable(map[string]any{
+		"get" + property + "Has": s.get,
+		"set" + property + "Has": s.set,
+	}, "/default", service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (s *hasharrayService) get() (uint32, string, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := s.mem.Write(s.data)
+	if err != nil {
+		return 0, "", dbus.MakeFailedError(err)
+	}
+	return 0, key, nil
+}
+
+func (s *hasharrayService) set(key string) (uint32, *dbus.Error) {
+	data, err := s.mem.Read(key)
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = append([]byte(nil), data...)
+	return 0, nil
+}
+
+func TestHasharrayProperty(t *testing.T) {
+	t.Parallel()
+	bus, err := lipctest.NewBus()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = bus.Close() })
+	var mem lipc.MemSharedMemory
+	newHasharrayService(t, bus, wifid.Service, wifid.PropScanList, &mem)
+	conn, err := bus.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx := context.Background()
+	want := lipc.Hasharray{
+		{"essid": "home", "signal": int32(4), "bssid": []byte{0xde, 0xad, 0xbe, 0xef}},
+		{"essid": "12", "signal": int32(-1)},
+	}
+	err = lipc.SetHasharrayProperty(ctx, conn, &mem, wifid.Service, wifid.PropScanList, want)
+	if err != nil {
+		t.Fatalf("SetHasharrayProperty() error = %v", err)
+	}
+	if mem.Len() != 0 {
+		t.Errorf("SetHasharrayProperty() left %d segments behind", mem.Len())
+	}
+	got, err := lipc.GetHasharrayProperty(ctx, conn, &mem, wifid.Service, wifid.PropScanList)
+	if err != nil {
+		t.Fatalf("GetHasharrayProperty() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetHasharrayProperty() = %v, want %v", got, want)
+	}
+	if mem.Len() != 0 {
+		t.Errorf("GetHasharrayProperty() left %d segments behind", mem.Len())
+	}
+
+	// and through a Client, as the generated service clients use it
+	client := lipc.NewClient(bus.Connect, lipc.ClientOptions{SharedMemory: &mem})
+	t.Cleanup(func() { _ = client.Close() })
+	got, err = wifid.NewWithCaller(client).ScanList(ctx)
+	if err != nil {
+		t.Fatalf("ScanList() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanList() = %v, want %v", got, want)
+	}
+
+	_, err = lipc.GetHasharrayProperty(ctx, conn, &mem, wifid.Service, "missing")
+	if err == nil {
+		t.Error("GetHasharrayProperty() of a missing property succeeded, want an error")
+	}
+}
+
+func TestFormatHasharray(t *testing.T) {
+	t.Parallel()
+	got := lipc.FormatHasharray(lipc.Hasharray{{"b": int32(2), "a": "x"}, {}})
+	if want := "{ a = x, b = 2 }\n{  }"; got != want {
+		t.Errorf("FormatHasharray() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package lipc
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestHasharrayRoundTrip(t *testing.T) {
+	t.Parallel()
+	tests := map[string]Hasharray{
+		"empty":      {},
+		"empty hash": {{}},
+		"values": {
+			{"essid": "home", "signal": int32(4), "bssid": []byte{0xde, 0xad, 0xbe, 0xef}},
+			{"essid": "", "signal": int32(-1)},
+		},
+	}
+	for name, ha := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var mem MemSharedMemory
+			key, err := writeHasharray(&mem, ha)
+			if err != nil {
+				t.Fatalf("writeHasharray() error = %v", err)
+			}
+			got, err := readHasharray(&mem, key)
+			if err != nil {
+				t.Fatalf("readHasharray() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, ha) {
+				t.Errorf("readHasharray() = %v, want %v", got, ha)
+			}
+			if mem.Len() != 0 {
+				t.Errorf("segment wasn't removed after reading, %d left", mem.Len())
+			}
+		})
+	}
+}
+
+func TestEncodeHasharrayIsDeterministic(t *testing.T) {
+	t.Parallel()
+	ha := Hasharray{{"b": int32(2), "a": "1", "c": []byte("3")}}
+	first, err := encodeHasharray(ha)
+	if err != nil {
+		t.Fatalf("encodeHasharray() error = %v", err)
+	}
+	for range 10 {
+		again, err := encodeHasharray(ha)
+		if err != nil {
+			t.Fatalf("encodeHasharray() error = %v", err)
+		}
+		if string(again) != string(first) {
+			t.Fatalf("encodeHasharray() = %x, want %x", again, first)
+		}
+	}
+}
+
+func TestEncodeHasharrayUnsupportedType(t *testing.T) {
+	t.Parallel()
+	var mem MemSharedMemory
+	_, err := writeHasharray(&mem, Hasharray{{"n": 1}})
+	if err == nil {
+		t.Fatal("writeHasharray() with an int value succeeded, want an error")
+	}
+	if mem.Len() != 0 {
+		t.Errorf("a segment was written for an unencodable hasharray")
+	}
+}
+
+// segment builds a serialized hasharray from u32s, i32s, strings (length-prefixed) and raw bytes.
+func segment(parts ...any) []byte {
+	var b []byte
+	for _, p := range parts {
+		switch v := p.(type) {
+		case uint32:
+			b = binary.LittleEndian.AppendUint32(b, v)
+		case int32:
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		case string:
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(v)))
+			b = append(b, v...)
+		case []byte:
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+func TestDecodeHasharrayMalformed(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		data      []byte
+		truncated bool
+	}{
+		"empty":                   {data: nil, truncated: true},
+		"short hash count":        {data: []byte{1, 0}, truncated: true},
+		"missing hash":            {data: segment(uint32(1)), truncated: true},
+		"huge hash count":         {data: segment(uint32(0xffffffff), uint32(0)), truncated: true},
+		"missing key":             {data: segment(uint32(1), uint32(1)), truncated: true},
+		"key longer than data":    {data: segment(uint32(1), uint32(1), uint32(100), []byte("ab")), truncated: true},
+		"missing value type":      {data: segment(uint32(1), uint32(1), "k"), truncated: true},
+		"missing int value":       {data: segment(uint32(1), uint32(1), "k", hasharrayInt, []byte{1}), truncated: true},
+		"string longer than data": {data: segment(uint32(1), uint32(1), "k", hasharrayString, uint32(5), []byte("ab")), truncated: true},
+		"missing blob":            {data: segment(uint32(1), uint32(1), "k", hasharrayBlob), truncated: true},
+		"unknown value type":      {data: segment(uint32(1), uint32(1), "k", uint32(7), int32(0))},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var mem MemSharedMemory
+			key, err := mem.Write(tt.data)
+			if err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			got, err := readHasharray(&mem, key)
+			if err == nil {
+				t.Fatalf("readHasharray() = %v, want an error", got)
+			}
+			if tt.truncated != errors.Is(err, errTruncatedHasharray) {
+				t.Errorf("readHasharray() error = %v, truncated = %v", err, tt.truncated)
+			}
+			if mem.Len() != 0 {
+				t.Errorf("malformed segment wasn't removed after reading")
+			}
+		})
+	}
+}
+
+func TestReadHasharrayMissingSegment(t *testing.T) {
+	t.Parallel()
+	var mem MemSharedMemory
+	_, err := readHasharray(&mem, "/lipc-go.missing")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("readHasharray() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestDevShm(t *testing.T) {
+	t.Parallel()
+	mem := DevShm{Dir: t.TempDir()}
+	ha := Hasharray{{"a": int32(1)}}
+	key, err := writeHasharray(mem, ha)
+	if err != nil {
+		t.Fatalf("writeHasharray() error = %v", err)
+	}
+	got, err := readHasharray(mem, key)
+	if err != nil {
+		t.Fatalf("readHasharray() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, ha) {
+		t.Errorf("readHasharray() = %v, want %v", got, ha)
+	}
+	if _, err := mem.Read(key); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("segment wasn't removed after reading, Read() error = %v", err)
+	}
+
+	for _, key := range []string{"", "/", "/..", "/a/b", "../etc/passwd"} {
+		if _, err := mem.Read(key); !errors.Is(err, errInvalidSegmentKey) {
+			t.Errorf("Read(%q) error = %v, want %v", key, err, errInvalidSegmentKey)
+		}
+	}
+}
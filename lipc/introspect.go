@@ -0,0 +1,118 @@
+package lipc
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// Lab126Prefix is the prefix of the stock firmware's service names.
+const Lab126Prefix = "com.lab126."
+
+// PropertyInfo describes a property discovered with IntrospectService.
+type PropertyInfo struct {
+	Name     string   `json:"name"`
+	Type     PropType `json:"type"`
+	Readable bool     `json:"readable"`
+	Writable bool     `json:"writable"`
+}
+
+// Access returns "r", "w" or "rw", like lipc-probe shows.
+func (p PropertyInfo) Access() string {
+	var s string
+	if p.Readable {
+		s += "r"
+	}
+	if p.Writable {
+		s += "w"
+	}
+	return s
+}
+
+// ListServices returns the sorted names of all services on the bus starting with prefix,
+// e.g. Lab126Prefix. An empty prefix lists every service.
+func ListServices(ctx context.Context, conn *dbus.Conn, prefix string) ([]string, error) {
+	var names []string
+	err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.ListNames", 0).Store(&names)
+	if err != nil {
+		return nil, err
+	}
+	names = slices.DeleteFunc(names, func(n string) bool {
+		// unique connection names like ":1.42" aren't services
+		return strings.HasPrefix(n, ":") || n == "org.freedesktop.DBus" || !strings.HasPrefix(n, prefix)
+	})
+	slices.Sort(names)
+	return names, nil
+}
+
+// IntrospectService lists the properties of a service, by introspecting its /default object
+// and parsing the "get<Name><Type>"/"set<Name><Type>" method names.
+func IntrospectService(ctx context.Context, conn *dbus.Conn, service string) ([]PropertyInfo, error) {
+	var data string
+	err := conn.Object(service, "/default").CallWithContext(ctx, "org.freedesktop.DBus.Introspectable.Introspect", 0).Store(&data)
+	if err != nil {
+		return nil, newError(codeForDBusError(err), service, "", OpIntrospect, "", err)
+	}
+	return ParseIntrospection(data)
+}
+
+// ParseIntrospection extracts LIPC properties from D-Bus introspection XML.
+// Methods that don't follow the LIPC naming convention are ignored.
+func ParseIntrospection(data string) ([]PropertyInfo, error) {
+	var node introspect.Node
+	err := xml.Unmarshal([]byte(data), &node)
+	if err != nil {
+		return nil, fmt.Errorf("invalid introspection data: %w", err)
+	}
+	props := make(map[string]*PropertyInfo)
+	for _, iface := range node.Interfaces {
+		if strings.HasPrefix(iface.Name, "org.freedesktop.DBus.") {
+			continue
+		}
+		for _, m := range iface.Methods {
+			name, msgType, propType, ok := ParsePropertyMethod(m.Name)
+			if !ok {
+				continue
+			}
+			p, exists := props[name]
+			if !exists {
+				p = &PropertyInfo{Name: name, Type: propType}
+				props[name] = p
+			}
+			if msgType == GetProp {
+				p.Readable = true
+			} else {
+				p.Writable = true
+			}
+		}
+	}
+	result := make([]PropertyInfo, 0, len(props))
+	for _, name := range slices.Sorted(maps.Keys(props)) {
+		result = append(result, *props[name])
+	}
+	return result, nil
+}
+
+// ParsePropertyMethod splits an LIPC method name like "getflIntensityInt" into the property name,
+// message type and property type, or returns false if member isn't a property method.
+func ParsePropertyMethod(member string) (string, PropMessageType, PropType, bool) {
+	for _, msgType := range []PropMessageType{GetProp, SetProp} {
+		rest, ok := strings.CutPrefix(member, string(msgType))
+		if !ok {
+			continue
+		}
+		for _, propType := range []PropType{IntProp, StrProp, HasProp} {
+			name, ok := strings.CutSuffix(rest, string(propType))
+			if ok && name != "" {
+				return name, msgType, propType, true
+			}
+		}
+	}
+	return "", "", "", false
+}
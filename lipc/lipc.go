@@ -1,5 +1,5 @@
-// Package lipc provides a cgo-less interface for getting and setting LIPC properties over DBus.
-// Hasharrays are not supported, as they're a more complex interface involving shared memory.
+// Package lipc provides a cgo-less interface for getting and setting LIPC properties, and subscribing to LIPC events, over DBus.
+// Hasharray properties are passed through shared memory segments; see GetHasharrayProperty.
 package lipc
 
 import (
@@ -91,8 +91,8 @@ func doProperty[T any](ctx context.Context, conn *dbus.Conn, service, property s
 
 	call := <-conn.SendWithContext(ctx, message, make(chan *dbus.Call, 1)).Done
 	if call.Err != nil {
-		slog.Error("failed to get property", "error", call.Err)
-		return *new(T), call.Err
+		slog.Debug("property call failed", "error", call.Err)
+		return *new(T), newError(codeForDBusError(call.Err), service, property, Op(msgType), propType, call.Err)
 	}
 	slog.Debug("got property response", "body", call.Body)
 
@@ -112,7 +112,7 @@ func doProperty[T any](ctx context.Context, conn *dbus.Conn, service, property s
 		}
 	}
 	if status != 0 {
-		return *new(T), fmt.Errorf("non-zero status %d from %sStrProperty", status, msgType)
+		return *new(T), newError(status, service, property, Op(msgType), propType, nil)
 	}
 	return propValue, nil
 }
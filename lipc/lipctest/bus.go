@@ -0,0 +1,84 @@
+// Package lipctest provides fake LIPC services on a private bus, for testing LIPC clients
+// without a Kindle.
+//
+//	bus, err := lipctest.NewBus()
+//	if err != nil {
+//		t.Skip(err)
+//	}
+//	defer bus.Close()
+//	fake, err := lipctest.NewPowerd(bus.Connect)
+//	...
+//	conn, err := bus.Connect()
+//	client := powerd.New(conn)
+package lipctest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Bus is a private dbus-daemon, so tests neither need a session bus nor interfere with one.
+type Bus struct {
+	// Address is the bus address, as would be in $DBUS_SESSION_BUS_ADDRESS.
+	Address string
+	cmd     *exec.Cmd
+	dir     string
+}
+
+// NewBus starts a private dbus-daemon. It fails if dbus-daemon isn't installed, which tests
+// should treat as a reason to skip rather than fail.
+func NewBus() (*Bus, error) {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		return nil, fmt.Errorf("dbus-daemon is needed for a private bus: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "lipctest")
+	if err != nil {
+		return nil, err
+	}
+	// --session gives permissive policies, and --address keeps the socket out of the shared /tmp
+	cmd := exec.Command(path, "--session", "--nofork", "--nopidfile", "--print-address=1", "--address=unix:dir="+dir) //#nosec G204 -- fixed arguments
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to start dbus-daemon: %w", err)
+	}
+	b := &Bus{cmd: cmd, dir: dir}
+
+	// the daemon prints its address once it's listening
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		_ = b.Close()
+		return nil, fmt.Errorf("failed to read dbus-daemon address: %w", err)
+	}
+	b.Address = strings.TrimSpace(address)
+	return b, nil
+}
+
+// Connect opens a new connection to the bus. It's a lipc.Connector, so it can be passed to
+// lipc.NewServer, NewService and friends.
+func (b *Bus) Connect(opts ...dbus.ConnOption) (*dbus.Conn, error) {
+	return dbus.Connect(b.Address, opts...)
+}
+
+// Close stops the daemon, which disconnects everything still connected to it.
+func (b *Bus) Close() error {
+	err := b.cmd.Process.Kill()
+	// Wait always reports the kill, so only its other failures matter
+	var exitErr *exec.ExitError
+	if waitErr := b.cmd.Wait(); waitErr != nil && !errors.As(waitErr, &exitErr) {
+		err = errors.Join(err, waitErr)
+	}
+	return errors.Join(err, os.RemoveAll(b.dir))
+}
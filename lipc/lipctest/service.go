@@ -0,0 +1,170 @@
+package lipctest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/clintharrison/bueno/lipc/services/powerd"
+	"github.com/clintharrison/bueno/lipc/services/winmgr"
+)
+
+// Service is a fake LIPC service whose properties are plain values in memory.
+// Tests can read and change them directly, while clients go through the bus as usual.
+type Service struct {
+	server *lipc.Server
+
+	mu   sync.Mutex
+	ints map[string]int32
+	strs map[string]string
+	sets []Set
+}
+
+// Set is a property write a client made through the bus.
+type Set struct {
+	Property string
+	Value    any
+}
+
+// NewService registers a fake service on the bus that connect connects to.
+func NewService(service string, connect lipc.Connector) (*Service, error) {
+	server, err := lipc.NewServer(service, connect)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		server: server,
+		ints:   make(map[string]int32),
+		strs:   make(map[string]string),
+	}, nil
+}
+
+// Close unregisters the service.
+func (s *Service) Close() error {
+	return s.server.Close()
+}
+
+// AddInt publishes an int property with an initial value. Clients can only set it if writable.
+func (s *Service) AddInt(name string, value int32, writable bool) error {
+	s.mu.Lock()
+	s.ints[name] = value
+	s.mu.Unlock()
+	var set func(context.Context, int32) error
+	if writable {
+		set = func(_ context.Context, v int32) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.ints[name] = v
+			s.sets = append(s.sets, Set{Property: name, Value: v})
+			return nil
+		}
+	}
+	return lipc.RegisterProperty(s.server, name, func(context.Context) (int32, error) {
+		return s.Int(name), nil
+	}, set)
+}
+
+// AddStr publishes a string property with an initial value. Clients can only set it if writable.
+func (s *Service) AddStr(name string, value string, writable bool) error {
+	s.mu.Lock()
+	s.strs[name] = value
+	s.mu.Unlock()
+	var set func(context.Context, string) error
+	if writable {
+		set = func(_ context.Context, v string) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.strs[name] = v
+			s.sets = append(s.sets, Set{Property: name, Value: v})
+			return nil
+		}
+	}
+	return lipc.RegisterProperty(s.server, name, func(context.Context) (string, error) {
+		return s.Str(name), nil
+	}, set)
+}
+
+// Remove unpublishes a property, so clients get lipcErrNoSuchProperty for it.
+func (s *Service) Remove(name string) {
+	s.server.Unregister(name)
+}
+
+// Int returns the current value of an int property.
+func (s *Service) Int(name string) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ints[name]
+}
+
+// Str returns the current value of a string property.
+func (s *Service) Str(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.strs[name]
+}
+
+// SetInt changes an int property's value, as if the service had changed it itself.
+func (s *Service) SetInt(name string, value int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ints[name] = value
+}
+
+// SetStr changes a string property's value, as if the service had changed it itself.
+func (s *Service) SetStr(name string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strs[name] = value
+}
+
+// Sets returns the property writes clients have made so far, in order.
+func (s *Service) Sets() []Set {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Set(nil), s.sets...)
+}
+
+// SendEvent sends an event from the service, like the real one would.
+func (s *Service) SendEvent(name string, params ...any) error {
+	return s.server.SendEvent(name, params...)
+}
+
+// NewPowerd fakes com.lab126.powerd with a front light at intensity 10 of 24, and a warm light
+// at level 0.
+func NewPowerd(connect lipc.Connector) (*Service, error) {
+	s, err := NewService(powerd.Service, connect)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range []struct {
+		name     string
+		value    int32
+		writable bool
+	}{
+		{powerd.PropFlIntensity, 10, true},
+		{powerd.PropFlMaxIntensity, 24, false},
+		{powerd.PropCurrentAmberLevel, 0, true},
+	} {
+		err := s.AddInt(p.name, p.value, p.writable)
+		if err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("failed to add %s: %w", p.name, err)
+		}
+	}
+	return s, nil
+}
+
+// NewWinmgr fakes com.lab126.winmgr with the orientation locked to portrait.
+func NewWinmgr(connect lipc.Connector) (*Service, error) {
+	s, err := NewService(winmgr.Service, connect)
+	if err != nil {
+		return nil, err
+	}
+	err = s.AddStr(winmgr.PropOrientationLock, "U", true)
+	if err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("failed to add %s: %w", winmgr.PropOrientationLock, err)
+	}
+	return s, nil
+}
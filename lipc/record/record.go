@@ -0,0 +1,285 @@
+// Package record captures LIPC traffic into JSON-lines files and replays it as a fake bus.
+//
+// Recordings are meant for reverse-engineering how the stock firmware behaves, and then for
+// testing against that behavior without a Kindle:
+//
+//	lipc eavesdrop -o powerd.jsonl   # on the device
+//	lipc replay powerd.jsonl         # on a session bus, anywhere
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+// Message types, as used by dbus-monitor and match rules.
+const (
+	TypeMethodCall   = "method_call"
+	TypeMethodReturn = "method_return"
+	TypeError        = "error"
+	TypeSignal       = "signal"
+)
+
+// Record is a single recorded message. It's written as one line of JSON.
+type Record struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Serial uint32    `json:"serial"`
+	// Headers are keyed by lipc.NameForHeaderField, e.g. "Member" or "ReplySerial".
+	Headers map[string]string `json:"headers"`
+	// Body holds the arguments. Basic types are stored as JSON values, everything else as its
+	// GVariant text form (see dbus.Variant.String), and Headers["Signature"] says which is which.
+	Body []any `json:"body,omitempty"`
+
+	// The fields below are decoded from the message for readability, and aren't needed to replay it.
+	// Replies and errors get them from the call they answer.
+
+	Service  string               `json:"service,omitempty"`
+	Property string               `json:"property,omitempty"`
+	Op       lipc.PropMessageType `json:"op,omitempty"`
+	PropType lipc.PropType        `json:"propType,omitempty"`
+	// Status is the lipcErr name of a property reply's status, e.g. "lipcErrNoSuchProperty".
+	Status string `json:"status,omitempty"`
+	// Event is the event name of an LIPC event signal.
+	Event string `json:"event,omitempty"`
+}
+
+func typeName(t dbus.Type) string {
+	switch t {
+	case dbus.TypeMethodCall:
+		return TypeMethodCall
+	case dbus.TypeMethodReply:
+		return TypeMethodReturn
+	case dbus.TypeError:
+		return TypeError
+	case dbus.TypeSignal:
+		return TypeSignal
+	}
+	return "unknown"
+}
+
+// FromMessage converts a message to a Record, decoding the LIPC property or event it refers to.
+// Replies can't be decoded on their own; the Recorder fills those in from their calls.
+func FromMessage(msg *dbus.Message, t time.Time) Record {
+	r := Record{
+		Time:    t,
+		Type:    typeName(msg.Type),
+		Serial:  msg.Serial(),
+		Headers: make(map[string]string, len(msg.Headers)),
+		Body:    encodeBody(msg.Body),
+	}
+	for field, v := range msg.Headers {
+		switch v := v.Value().(type) {
+		case dbus.Signature:
+			r.Headers[lipc.NameForHeaderField(field)] = v.String()
+		default:
+			r.Headers[lipc.NameForHeaderField(field)] = fmt.Sprint(v)
+		}
+	}
+
+	member := r.Headers["Member"]
+	switch msg.Type {
+	case dbus.TypeMethodCall:
+		name, op, propType, ok := lipc.ParsePropertyMethod(member)
+		if ok && r.Headers["Path"] == "/default" {
+			r.Service = r.Headers["Destination"]
+			r.Property = name
+			r.Op = op
+			r.PropType = propType
+		}
+	case dbus.TypeSignal:
+		if r.Headers["Path"] == "/default" {
+			r.Service = r.Headers["Interface"]
+			r.Event = member
+		}
+	}
+	return r
+}
+
+// isBasic reports whether values of the signature are stored as plain JSON values.
+func isBasic(sig byte) bool {
+	switch sig {
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g':
+		return true
+	}
+	return false
+}
+
+func encodeBody(body []any) []any {
+	if len(body) == 0 {
+		return nil
+	}
+	out := make([]any, len(body))
+	for i, v := range body {
+		sig := dbus.SignatureOf(v).String()
+		switch {
+		case len(sig) == 1 && isBasic(sig[0]):
+			switch v := v.(type) {
+			case dbus.ObjectPath:
+				out[i] = string(v)
+			case dbus.Signature:
+				out[i] = v.String()
+			default:
+				out[i] = v
+			}
+		default:
+			out[i] = dbus.MakeVariant(v).String()
+		}
+	}
+	return out
+}
+
+// DecodeBody converts the recorded body back to D-Bus values, using the recorded signature.
+func (r Record) DecodeBody() ([]any, error) {
+	if len(r.Body) == 0 {
+		return nil, nil
+	}
+	sig, err := dbus.ParseSignature(r.Headers["Signature"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	types := splitSignature(sig.String())
+	if len(types) != len(r.Body) {
+		return nil, fmt.Errorf("signature %q doesn't match %d body values", sig, len(r.Body))
+	}
+	body := make([]any, len(r.Body))
+	for i, v := range r.Body {
+		body[i], err = decodeValue(types[i], v)
+		if err != nil {
+			return nil, fmt.Errorf("body value %d: %w", i, err)
+		}
+	}
+	return body, nil
+}
+
+// splitSignature splits a signature into its complete types, e.g. "ua{sv}s" into "u", "a{sv}", "s".
+func splitSignature(sig string) []string {
+	var types []string
+	for len(sig) > 0 {
+		n := completeTypeLen(sig)
+		types = append(types, sig[:n])
+		sig = sig[n:]
+	}
+	return types
+}
+
+func completeTypeLen(sig string) int {
+	switch sig[0] {
+	case 'a':
+		return 1 + completeTypeLen(sig[1:])
+	case '(', '{':
+		depth := 0
+		for i := 0; i < len(sig); i++ {
+			switch sig[i] {
+			case '(', '{':
+				depth++
+			case ')', '}':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return len(sig)
+	}
+	return 1
+}
+
+func decodeValue(sig string, v any) (any, error) {
+	if !isBasic(sig[0]) || len(sig) != 1 {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected text form of %s value, got %T", sig, v)
+		}
+		variant, err := dbus.ParseVariant(s, dbus.ParseSignatureMust(sig))
+		if err != nil {
+			return nil, err
+		}
+		return variant.Value(), nil
+	}
+
+	switch sig[0] {
+	case 's', 'o', 'g':
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string for %s value, got %T", sig, v)
+		}
+		switch sig[0] {
+		case 'o':
+			return dbus.ObjectPath(s), nil
+		case 'g':
+			return dbus.ParseSignature(s)
+		}
+		return s, nil
+	case 'b':
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool, got %T", v)
+		}
+		return b, nil
+	}
+
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("expected number for %s value, got %T", sig, v)
+	}
+	switch sig[0] {
+	case 'd':
+		return strconv.ParseFloat(n.String(), 64)
+	case 'y':
+		u, err := strconv.ParseUint(n.String(), 10, 8)
+		return byte(u), err
+	case 'q':
+		u, err := strconv.ParseUint(n.String(), 10, 16)
+		return uint16(u), err
+	case 'u':
+		u, err := strconv.ParseUint(n.String(), 10, 32)
+		return uint32(u), err
+	case 't':
+		return strconv.ParseUint(n.String(), 10, 64)
+	case 'n':
+		i, err := strconv.ParseInt(n.String(), 10, 16)
+		return int16(i), err
+	case 'i':
+		i, err := strconv.ParseInt(n.String(), 10, 32)
+		return int32(i), err
+	}
+	// 'x' is the only basic type left
+	return strconv.ParseInt(n.String(), 10, 64)
+}
+
+// Load reads a JSON-lines recording, as written by a Recorder.
+func Load(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	// hasharray keys and introspection data can make for long lines
+	scanner.Buffer(nil, 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		// keep numbers exact, so e.g. uint64 values survive the trip
+		dec.UseNumber()
+		err := dec.Decode(&rec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,164 @@
+package record
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+// maxPendingCalls bounds how many unanswered calls the recorder remembers for decoding replies,
+// since calls whose replies we never see would otherwise pile up forever.
+const maxPendingCalls = 1024
+
+// callKey identifies a method call on the bus: serials are only unique per sender.
+type callKey struct {
+	sender string
+	serial uint32
+}
+
+// Recorder writes every message on the bus to a JSON-lines stream.
+//
+// It uses its own connection, since monitoring the bus takes over the connection entirely:
+// replies to its own calls would be recorded instead of returned.
+type Recorder struct {
+	conn    *dbus.Conn
+	enc     *json.Encoder
+	pending map[callKey]Record
+	// Filter, if set, is called for every record, and only records it returns true for are written.
+	Filter func(Record) bool
+}
+
+// NewRecorder connects to the bus with connect (dbus.ConnectSystemBus if nil) for recording into w.
+func NewRecorder(connect lipc.Connector, w io.Writer) (*Recorder, error) {
+	if connect == nil {
+		connect = dbus.ConnectSystemBus
+	}
+	conn, err := connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bus: %w", err)
+	}
+	return &Recorder{
+		conn:    conn,
+		enc:     json.NewEncoder(w),
+		pending: make(map[callKey]Record),
+	}, nil
+}
+
+// Close closes the recorder's connection.
+func (r *Recorder) Close() error {
+	return r.conn.Close()
+}
+
+// Run records messages until ctx is cancelled or the connection is closed.
+//
+// It prefers org.freedesktop.DBus.Monitoring.BecomeMonitor, and falls back to eavesdropping
+// match rules on older buses (like the Kindle's) that don't support it.
+func (r *Recorder) Run(ctx context.Context) error {
+	messages := make(chan *dbus.Message, 256)
+	// this must happen before becoming a monitor, or the bus's reply would race with it
+	r.conn.Eavesdrop(messages)
+
+	monitorMsg := &dbus.Message{
+		Type: dbus.TypeMethodCall,
+		Headers: map[dbus.HeaderField]dbus.Variant{
+			dbus.FieldDestination: dbus.MakeVariant("org.freedesktop.DBus"),
+			dbus.FieldPath:        dbus.MakeVariant(dbus.ObjectPath("/org/freedesktop/DBus")),
+			dbus.FieldInterface:   dbus.MakeVariant("org.freedesktop.DBus.Monitoring"),
+			dbus.FieldMember:      dbus.MakeVariant("BecomeMonitor"),
+			dbus.FieldSignature:   dbus.MakeVariant(dbus.SignatureOf([]string{}, uint32(0))),
+		},
+		Body: []any{[]string{}, uint32(0)},
+	}
+	// the reply goes to the eavesdropping channel rather than the call, so don't wait for it
+	call := r.conn.SendWithContext(ctx, monitorMsg, nil)
+	if call.Err != nil {
+		return fmt.Errorf("failed to start monitoring: %w", call.Err)
+	}
+	monitorSerial := monitorMsg.Serial()
+	monitoring := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return errors.New("bus connection closed")
+			}
+			if replySerial, ok := msg.Headers[dbus.FieldReplySerial].Value().(uint32); ok && replySerial == monitorSerial && !monitoring {
+				monitoring = true
+				if msg.Type == dbus.TypeError {
+					slog.Debug("bus doesn't support BecomeMonitor, falling back to eavesdropping", "error", msg.Body)
+					r.eavesdrop()
+				} else {
+					slog.Debug("monitoring bus")
+				}
+				continue
+			}
+			err := r.record(msg, time.Now())
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// eavesdrop adds match rules for all messages. Both eavesdropping and plain rules are added,
+// since very old buses reject the eavesdrop key and newer ones need it to see other peers' calls.
+// The bus delivers each message once however many rules match.
+func (r *Recorder) eavesdrop() {
+	for _, t := range []string{TypeSignal, TypeMethodCall, TypeMethodReturn, TypeError} {
+		for _, rule := range []string{"type='" + t + "',eavesdrop='true'", "type='" + t + "'"} {
+			// replies would only show up in the eavesdropping channel, so don't ask for them
+			call := r.conn.BusObject().Go("org.freedesktop.DBus.AddMatch", dbus.FlagNoReplyExpected, nil, rule)
+			if call.Err != nil {
+				slog.Warn("failed to add match rule", "rule", rule, "error", call.Err)
+			}
+		}
+	}
+}
+
+func (r *Recorder) record(msg *dbus.Message, t time.Time) error {
+	rec := FromMessage(msg, t)
+	switch msg.Type {
+	case dbus.TypeMethodCall:
+		if rec.Property != "" && msg.Flags&dbus.FlagNoReplyExpected == 0 {
+			if len(r.pending) >= maxPendingCalls {
+				clear(r.pending)
+			}
+			r.pending[callKey{rec.Headers["Sender"], rec.Serial}] = rec
+		}
+	case dbus.TypeMethodReply, dbus.TypeError:
+		replySerial, _ := msg.Headers[dbus.FieldReplySerial].Value().(uint32)
+		key := callKey{rec.Headers["Destination"], replySerial}
+		if call, ok := r.pending[key]; ok {
+			delete(r.pending, key)
+			rec.Service = call.Service
+			rec.Property = call.Property
+			rec.Op = call.Op
+			rec.PropType = call.PropType
+			if status, ok := firstUint32(msg.Body); ok && msg.Type == dbus.TypeMethodReply {
+				rec.Status = lipc.NameForLipcError(status)
+			}
+		}
+	}
+	if r.Filter != nil && !r.Filter(rec) {
+		return nil
+	}
+	return r.enc.Encode(rec)
+}
+
+func firstUint32(body []any) (uint32, bool) {
+	if len(body) == 0 {
+		return 0, false
+	}
+	v, ok := body[0].(uint32)
+	return v, ok
+}
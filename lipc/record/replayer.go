@@ -0,0 +1,247 @@
+package record
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+// exchange is a recorded call and the reply or error it got.
+type exchange struct {
+	call  Record
+	reply Record
+}
+
+// Replayer serves a recording as a fake bus: it claims the services that were called in the
+// recording and answers calls to them with the recorded replies, so clients can be tested against
+// the firmware's real behavior without a Kindle.
+//
+// A call is matched by its destination, path, member and arguments. Repeated calls get the recorded
+// replies in order, and the last one again once they run out. Gets match regardless of arguments,
+// since LIPC clients may or may not send their own service name with them.
+type Replayer struct {
+	mu        sync.Mutex
+	conn      *dbus.Conn
+	exchanges map[string][]exchange
+	signals   []Record
+}
+
+// NewReplayer pairs up the calls and replies in records for replaying.
+// Calls without a recorded reply are left out.
+func NewReplayer(records []Record) (*Replayer, error) {
+	p := &Replayer{exchanges: make(map[string][]exchange)}
+	calls := make(map[callKey]Record)
+	for _, rec := range records {
+		switch rec.Type {
+		case TypeMethodCall:
+			calls[callKey{rec.Headers["Sender"], rec.Serial}] = rec
+		case TypeMethodReturn, TypeError:
+			replySerial, err := strconv.ParseUint(rec.Headers["ReplySerial"], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("reply %d has invalid ReplySerial: %w", rec.Serial, err)
+			}
+			key := callKey{rec.Headers["Destination"], uint32(replySerial)}
+			call, ok := calls[key]
+			if !ok {
+				continue
+			}
+			delete(calls, key)
+			k, err := exchangeKey(call.Headers["Destination"], call.Headers["Path"], call.Headers["Member"], call.Body)
+			if err != nil {
+				return nil, err
+			}
+			p.exchanges[k] = append(p.exchanges[k], exchange{call: call, reply: rec})
+		case TypeSignal:
+			// the bus's own signals (like NameOwnerChanged) come from the real bus during replay
+			if rec.Headers["Sender"] != "org.freedesktop.DBus" {
+				p.signals = append(p.signals, rec)
+			}
+		}
+	}
+	return p, nil
+}
+
+// exchangeKey identifies calls that should get the same replies.
+func exchangeKey(destination, path, member string, body []any) (string, error) {
+	if _, op, _, ok := lipc.ParsePropertyMethod(member); ok && op == lipc.GetProp {
+		body = nil
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{destination, path, member, string(b)}, "\x00"), nil
+}
+
+// Services returns the well-known names called in the recording, which Start claims.
+func (p *Replayer) Services() []string {
+	var services []string
+	for _, exchanges := range p.exchanges {
+		dest := exchanges[0].call.Headers["Destination"]
+		if dest == "" || strings.HasPrefix(dest, ":") || dest == "org.freedesktop.DBus" {
+			continue
+		}
+		if !slices.Contains(services, dest) {
+			services = append(services, dest)
+		}
+	}
+	slices.Sort(services)
+	return services
+}
+
+// Start connects to the bus with connect (dbus.ConnectSessionBus if nil, since replaying on the
+// system bus would shadow real services) and claims the recorded services. Calls are answered
+// from then on, until Close.
+func (p *Replayer) Start(connect lipc.Connector) error {
+	if connect == nil {
+		connect = dbus.ConnectSessionBus
+	}
+	conn, err := connect(dbus.WithHandler(p))
+	if err != nil {
+		return fmt.Errorf("failed to connect to bus: %w", err)
+	}
+	for _, service := range p.Services() {
+		reply, err := conn.RequestName(service, dbus.NameFlagDoNotQueue)
+		if err == nil && reply != dbus.RequestNameReplyPrimaryOwner && reply != dbus.RequestNameReplyAlreadyOwner {
+			err = fmt.Errorf("service name %s is already taken", service)
+		}
+		if err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("failed to request name %s: %w", service, err)
+		}
+		slog.Debug("replaying service", "service", service)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conn = conn
+	return nil
+}
+
+// Close releases the services and closes the connection.
+func (p *Replayer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// ReplaySignals emits the recorded signals in order, e.g. to send the LIPC events a client
+// is waiting for. With realtime set, it waits between signals as long as the recording did.
+// It must be called between Start and Close.
+func (p *Replayer) ReplaySignals(ctx context.Context, realtime bool) error {
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+	if conn == nil {
+		return errors.New("replayer is not started")
+	}
+
+	for i, sig := range p.signals {
+		if realtime && i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(sig.Time.Sub(p.signals[i-1].Time)):
+			}
+		}
+		body, err := sig.DecodeBody()
+		if err != nil {
+			return fmt.Errorf("signal %s.%s: %w", sig.Headers["Interface"], sig.Headers["Member"], err)
+		}
+		err = conn.Emit(dbus.ObjectPath(sig.Headers["Path"]), sig.Headers["Interface"]+"."+sig.Headers["Member"], body...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next returns the reply for a call, or false if the recording has no such call.
+func (p *Replayer) next(key string) (Record, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	exchanges := p.exchanges[key]
+	if len(exchanges) == 0 {
+		return Record{}, false
+	}
+	if len(exchanges) > 1 {
+		p.exchanges[key] = exchanges[1:]
+	}
+	return exchanges[0].reply, true
+}
+
+// LookupObject implements dbus.Handler. Any path may have been recorded, so every lookup succeeds
+// and unrecorded calls are rejected once their arguments are known.
+func (p *Replayer) LookupObject(path dbus.ObjectPath) (dbus.ServerObject, bool) {
+	return replayObject{p: p, path: path}, true
+}
+
+type replayObject struct {
+	p    *Replayer
+	path dbus.ObjectPath
+}
+
+func (o replayObject) LookupInterface(string) (dbus.Interface, bool) {
+	return o, true
+}
+
+func (o replayObject) LookupMethod(name string) (dbus.Method, bool) {
+	return &replayMethod{p: o.p, path: o.path, member: name}, true
+}
+
+// replayMethod answers a single call. It's created per call, so it can hold on to the call's
+// destination and arguments between decoding them and replying.
+type replayMethod struct {
+	p      *Replayer
+	path   dbus.ObjectPath
+	member string
+	key    string
+}
+
+var _ dbus.ArgumentDecoder = (*replayMethod)(nil)
+
+func (m *replayMethod) DecodeArguments(_ *dbus.Conn, _ string, msg *dbus.Message, args []any) ([]any, error) {
+	destination, _ := msg.Headers[dbus.FieldDestination].Value().(string)
+	key, err := exchangeKey(destination, string(m.path), m.member, encodeBody(args))
+	if err != nil {
+		return nil, err
+	}
+	m.key = key
+	return args, nil
+}
+
+func (m *replayMethod) Call(...any) ([]any, error) {
+	reply, ok := m.p.next(m.key)
+	if !ok {
+		slog.Debug("no recorded reply for call", "path", m.path, "member", m.member)
+		return nil, dbus.MakeUnknownMethodError(m.member)
+	}
+	body, err := reply.DecodeBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode recorded reply: %w", err)
+	}
+	if reply.Type == TypeError {
+		return nil, dbus.Error{Name: reply.Headers["ErrorName"], Body: body}
+	}
+	return body, nil
+}
+
+func (*replayMethod) NumArguments() int     { return 0 }
+func (*replayMethod) NumReturns() int       { return 0 }
+func (*replayMethod) ArgumentValue(int) any { return nil }
+func (*replayMethod) ReturnValue(int) any   { return nil }
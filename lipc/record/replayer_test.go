@@ -0,0 +1,88 @@
+package record_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/clintharrison/bueno/lipc/lipctest"
+	"github.com/clintharrison/bueno/lipc/record"
+	"github.com/clintharrison/bueno/lipc/services/powerd"
+)
+
+// testdata/powerd.jsonl was recorded from lipctest.NewPowerd, without currentAmberLevel: two gets
+// of flIntensity (10, then 12), a get of currentAmberLevel, and a flIntensityChanged event.
+func TestReplayer(t *testing.T) {
+	t.Parallel()
+	f, err := os.Open("testdata/powerd.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := record.Load(f)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	replayer, err := record.NewReplayer(records)
+	if err != nil {
+		t.Fatalf("NewReplayer() error = %v", err)
+	}
+	if got, want := replayer.Services(), []string{powerd.Service}; !slices.Equal(got, want) {
+		t.Errorf("Services() = %v, want %v", got, want)
+	}
+
+	bus, err := lipctest.NewBus()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = bus.Close() })
+	if err := replayer.Start(bus.Connect); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = replayer.Close() })
+	conn, err := bus.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := powerd.New(conn)
+	// the last reply repeats once the recorded ones run out
+	for _, want := range []int32{10, 12, 12} {
+		got, err := client.FlIntensity(ctx)
+		if err != nil {
+			t.Fatalf("FlIntensity() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("FlIntensity() = %d, want %d", got, want)
+		}
+	}
+	if _, err := client.CurrentAmberLevel(ctx); !errors.Is(err, lipc.ErrNoSuchProperty) {
+		t.Errorf("CurrentAmberLevel() error = %v, want %v", err, lipc.ErrNoSuchProperty)
+	}
+	if _, err := client.FlMaxIntensity(ctx); err == nil {
+		t.Error("FlMaxIntensity() succeeded, but it wasn't recorded")
+	}
+
+	events, err := lipc.Subscribe(ctx, conn, powerd.Service, "flIntensityChanged")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := replayer.ReplaySignals(ctx, false); err != nil {
+		t.Fatalf("ReplaySignals() error = %v", err)
+	}
+	select {
+	case ev := <-events:
+		if v, err := ev.Int(0); err != nil || v != 12 {
+			t.Errorf("event %v param 0 = %d, %v, want 12", ev, v, err)
+		}
+	case <-ctx.Done():
+		t.Fatal("the recorded event never arrived")
+	}
+}
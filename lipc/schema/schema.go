@@ -0,0 +1,168 @@
+// Package schema describes LIPC services, their properties and events, in YAML.
+// The schemas are used to generate typed clients (see tools/lipcgen).
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/clintharrison/bueno/quietly"
+	"gopkg.in/yaml.v3"
+)
+
+// Type is the type of a property or event parameter.
+type Type string
+
+const (
+	Int       Type = "int"
+	Str       Type = "str"
+	Hasharray Type = "hasharray"
+)
+
+// Access says whether a property can be read, written or both.
+type Access string
+
+const (
+	ReadOnly  Access = "r"
+	WriteOnly Access = "w"
+	ReadWrite Access = "rw"
+)
+
+func (a Access) Readable() bool {
+	return a == ReadOnly || a == ReadWrite
+}
+
+func (a Access) Writable() bool {
+	return a == WriteOnly || a == ReadWrite
+}
+
+// Range bounds the values an int property accepts.
+type Range struct {
+	Min int32 `yaml:"min"`
+	Max int32 `yaml:"max"`
+}
+
+type Property struct {
+	Name   string `yaml:"name"`
+	Type   Type   `yaml:"type"`
+	Access Access `yaml:"access"`
+	Range  *Range `yaml:"range,omitempty"`
+	Doc    string `yaml:"doc,omitempty"`
+}
+
+type Param struct {
+	Name string `yaml:"name"`
+	Type Type   `yaml:"type"`
+}
+
+type Event struct {
+	Name   string  `yaml:"name"`
+	Params []Param `yaml:"params,omitempty"`
+	Doc    string  `yaml:"doc,omitempty"`
+}
+
+// Service is the schema for a single LIPC service, e.g. com.lab126.powerd.
+type Service struct {
+	// Name is the LIPC service (and D-Bus bus) name.
+	Name string `yaml:"service"`
+	// Package is the Go package name for the generated client.
+	Package    string     `yaml:"package"`
+	Doc        string     `yaml:"doc,omitempty"`
+	Properties []Property `yaml:"properties"`
+	Events     []Event    `yaml:"events,omitempty"`
+}
+
+var (
+	serviceNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_-]*)+$`)
+	identifierRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Validate checks the schema for missing or invalid fields and duplicate names.
+func (s *Service) Validate() error {
+	var errs []error
+	if !serviceNameRe.MatchString(s.Name) {
+		errs = append(errs, fmt.Errorf("invalid service name %q", s.Name))
+	}
+	if !identifierRe.MatchString(s.Package) {
+		errs = append(errs, fmt.Errorf("invalid package name %q", s.Package))
+	}
+	seen := make(map[string]bool)
+	for _, p := range s.Properties {
+		if !identifierRe.MatchString(p.Name) {
+			errs = append(errs, fmt.Errorf("invalid property name %q", p.Name))
+		}
+		if seen[p.Name] {
+			errs = append(errs, fmt.Errorf("duplicate property %q", p.Name))
+		}
+		seen[p.Name] = true
+		switch p.Type {
+		case Int, Str, Hasharray:
+		default:
+			errs = append(errs, fmt.Errorf("property %q has invalid type %q", p.Name, p.Type))
+		}
+		switch p.Access {
+		case ReadOnly, WriteOnly, ReadWrite:
+		default:
+			errs = append(errs, fmt.Errorf("property %q has invalid access %q", p.Name, p.Access))
+		}
+		if p.Range != nil {
+			if p.Type != Int {
+				errs = append(errs, fmt.Errorf("property %q has a range but isn't an int", p.Name))
+			} else if p.Range.Min > p.Range.Max {
+				errs = append(errs, fmt.Errorf("property %q has min %d > max %d", p.Name, p.Range.Min, p.Range.Max))
+			}
+		}
+	}
+	seen = make(map[string]bool)
+	for _, e := range s.Events {
+		if !identifierRe.MatchString(e.Name) {
+			errs = append(errs, fmt.Errorf("invalid event name %q", e.Name))
+		}
+		if seen[e.Name] {
+			errs = append(errs, fmt.Errorf("duplicate event %q", e.Name))
+		}
+		seen[e.Name] = true
+		for _, p := range e.Params {
+			if !identifierRe.MatchString(p.Name) {
+				errs = append(errs, fmt.Errorf("event %q has invalid param name %q", e.Name, p.Name))
+			}
+			if p.Type != Int && p.Type != Str {
+				errs = append(errs, fmt.Errorf("event %q param %q has invalid type %q", e.Name, p.Name, p.Type))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Parse reads and validates a service schema.
+func Parse(r io.Reader) (*Service, error) {
+	var s Service
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	err := dec.Decode(&s)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Load reads and validates the service schema at path.
+func Load(path string) (*Service, error) {
+	f, err := os.Open(path) //#nosec G304
+	if err != nil {
+		return nil, err
+	}
+	defer quietly.Close(f)
+	s, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
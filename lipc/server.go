@@ -0,0 +1,347 @@
+package lipc
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// Connector opens a new bus connection, e.g. dbus.ConnectSystemBus or dbus.ConnectSessionBus.
+type Connector func(opts ...dbus.ConnOption) (*dbus.Conn, error)
+
+// Server publishes LIPC properties and events under a service name, like liblipc's LipcOpen and
+// LipcRegister*Property. Other processes can then use lipc-get-prop, lipc-set-prop and
+// lipc-wait-event with it like any other service.
+//
+// Properties are served on /default as "get<Name>Int"/"set<Name>Int" (and Str) methods, replying
+// with the same (status, value) convention that GetProperty and SetProperty expect.
+type Server struct {
+	conn    *dbus.Conn
+	service string
+
+	mu    sync.RWMutex
+	props map[string]*serverProperty
+}
+
+type serverProperty struct {
+	propType PropType
+	get      func(ctx context.Context) (any, error)
+	set      func(ctx context.Context, v any) error
+}
+
+// NewServer connects to the bus with connect (dbus.ConnectSystemBus if nil) and claims the
+// service name. It fails if the name is already owned by another process.
+func NewServer(service string, connect Connector) (*Server, error) {
+	if connect == nil {
+		connect = dbus.ConnectSystemBus
+	}
+	s := &Server{
+		service: service,
+		props:   make(map[string]*serverProperty),
+	}
+	conn, err := connect(dbus.WithHandler(s))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bus: %w", err)
+	}
+	s.conn = conn
+
+	reply, err := conn.RequestName(service, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to request name %s: %w", service, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner && reply != dbus.RequestNameReplyAlreadyOwner {
+		_ = conn.Close()
+		return nil, fmt.Errorf("service name %s is already taken", service)
+	}
+	slog.Debug("registered LIPC service", "service", service)
+	return s, nil
+}
+
+// Service returns the service name the server was registered with.
+func (s *Server) Service() string {
+	return s.service
+}
+
+// Conn returns the server's bus connection, which can also be used for client calls.
+func (s *Server) Conn() *dbus.Conn {
+	return s.conn
+}
+
+// Close releases the service name and closes the connection.
+func (s *Server) Close() error {
+	_, err := s.conn.ReleaseName(s.service)
+	if err != nil {
+		slog.Debug("failed to release service name", "service", s.service, "error", err)
+	}
+	return s.conn.Close()
+}
+
+// RegisterProperty publishes a property on the server. Either get or set may be nil to make
+// the property write-only or read-only. Registering a name again replaces the old property.
+//
+// If get or set fail, the caller gets lipcErrInternal, unless the handler returns an *Error
+// (such as ErrInvalidArg) to pick the status code.
+func RegisterProperty[T int32 | string](s *Server, name string, get func(ctx context.Context) (T, error), set func(ctx context.Context, v T) error) error {
+	if name == "" {
+		return errors.New("property name must not be empty")
+	}
+	if get == nil && set == nil {
+		return fmt.Errorf("property %s needs a getter or a setter", name)
+	}
+	propType := StrProp
+	if _, ok := any(*new(T)).(int32); ok {
+		propType = IntProp
+	}
+	prop := &serverProperty{propType: propType}
+	if get != nil {
+		prop.get = func(ctx context.Context) (any, error) {
+			return get(ctx)
+		}
+	}
+	if set != nil {
+		prop.set = func(ctx context.Context, v any) error {
+			tv, ok := v.(T)
+			if !ok {
+				return fmt.Errorf("expected %T value for %s, got %T", *new(T), name, v)
+			}
+			return set(ctx, tv)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.props[name] = prop
+	return nil
+}
+
+// Unregister removes a property from the server.
+func (s *Server) Unregister(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.props, name)
+}
+
+// SendEvent emits an LIPC event from the service, like lipc-send-event.
+// Params must be int32 or string values.
+func (s *Server) SendEvent(name string, params ...any) error {
+	for i, p := range params {
+		switch p.(type) {
+		case int32, string:
+		default:
+			return fmt.Errorf("event %s param %d has unsupported type %T", name, i, p)
+		}
+	}
+	return s.conn.Emit("/default", s.service+"."+name, params...)
+}
+
+// lookup finds the property and message type for an LIPC method name like "getflIntensityInt".
+func (s *Server) lookup(member string) (*serverProperty, PropMessageType, bool) {
+	name, msgType, propType, ok := ParsePropertyMethod(member)
+	if !ok {
+		return nil, "", false
+	}
+	s.mu.RLock()
+	prop, ok := s.props[name]
+	s.mu.RUnlock()
+	if !ok || prop.propType != propType {
+		return nil, "", false
+	}
+	return prop, msgType, true
+}
+
+// LookupObject implements dbus.Handler. LIPC services only have the one object.
+func (s *Server) LookupObject(path dbus.ObjectPath) (dbus.ServerObject, bool) {
+	if path != "/default" {
+		return nil, false
+	}
+	return serverObject{s}, true
+}
+
+type serverObject struct {
+	s *Server
+}
+
+func (o serverObject) LookupInterface(name string) (dbus.Interface, bool) {
+	switch name {
+	// some clients leave out the interface, which the D-Bus spec allows
+	case o.s.service, "":
+		return serverInterface(o), true
+	case "org.freedesktop.DBus.Introspectable":
+		return introspectInterface(o), true
+	}
+	return nil, false
+}
+
+type serverInterface struct {
+	s *Server
+}
+
+func (i serverInterface) LookupMethod(name string) (dbus.Method, bool) {
+	prop, msgType, ok := i.s.lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return &propertyMethod{prop: prop, msgType: msgType}, true
+}
+
+// propertyMethod handles a get or set call for a single property.
+// It decodes its own arguments since LIPC clients may or may not include their service name in gets.
+type propertyMethod struct {
+	prop    *serverProperty
+	msgType PropMessageType
+}
+
+var _ dbus.ArgumentDecoder = (*propertyMethod)(nil)
+
+func (m *propertyMethod) DecodeArguments(_ *dbus.Conn, _ string, _ *dbus.Message, args []any) ([]any, error) {
+	if m.msgType == SetProp {
+		if len(args) != 1 {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		switch args[0].(type) {
+		case int32:
+			if m.prop.propType != IntProp {
+				return nil, dbus.ErrMsgInvalidArg
+			}
+		case string:
+			if m.prop.propType != StrProp {
+				return nil, dbus.ErrMsgInvalidArg
+			}
+		default:
+			return nil, dbus.ErrMsgInvalidArg
+		}
+	}
+	return args, nil
+}
+
+func (m *propertyMethod) Call(args ...any) ([]any, error) {
+	// method calls have no context of their own, so handlers get a background one
+	ctx := context.Background()
+	if m.msgType == GetProp {
+		zero := m.zero()
+		if m.prop.get == nil {
+			return []any{ErrAccessNotAllowed.Code, zero}, nil
+		}
+		v, err := m.prop.get(ctx)
+		if err != nil {
+			slog.Warn("LIPC property getter failed", "error", err)
+			return []any{statusForError(err), zero}, nil
+		}
+		return []any{uint32(0), v}, nil
+	}
+
+	if m.prop.set == nil {
+		return []any{ErrAccessNotAllowed.Code}, nil
+	}
+	err := m.prop.set(ctx, args[0])
+	if err != nil {
+		slog.Warn("LIPC property setter failed", "error", err)
+		return []any{statusForError(err)}, nil
+	}
+	return []any{uint32(0)}, nil
+}
+
+func (m *propertyMethod) zero() any {
+	if m.prop.propType == IntProp {
+		return int32(0)
+	}
+	return ""
+}
+
+func (m *propertyMethod) NumArguments() int {
+	if m.msgType == SetProp {
+		return 1
+	}
+	return 0
+}
+
+func (m *propertyMethod) NumReturns() int {
+	if m.msgType == SetProp {
+		return 1
+	}
+	return 2
+}
+
+func (m *propertyMethod) ArgumentValue(int) any {
+	return m.zero()
+}
+
+func (m *propertyMethod) ReturnValue(position int) any {
+	if position == 0 {
+		return uint32(0)
+	}
+	return m.zero()
+}
+
+type introspectInterface struct {
+	s *Server
+}
+
+func (i introspectInterface) LookupMethod(name string) (dbus.Method, bool) {
+	if name != "Introspect" {
+		return nil, false
+	}
+	return introspectMethod(i), true
+}
+
+type introspectMethod struct {
+	s *Server
+}
+
+// introspectionNode describes the server's properties as get/set methods, the same way
+// liblipc-based services do.
+func (s *Server) introspectionNode() *introspect.Node {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	iface := introspect.Interface{Name: s.service}
+	for _, name := range slices.Sorted(maps.Keys(s.props)) {
+		prop := s.props[name]
+		sig := "i"
+		if prop.propType == StrProp {
+			sig = "s"
+		}
+		if prop.get != nil {
+			iface.Methods = append(iface.Methods, introspect.Method{
+				Name: fmt.Sprintf("%s%s%s", GetProp, name, prop.propType),
+				Args: []introspect.Arg{
+					{Name: "status", Type: "u", Direction: "out"},
+					{Name: "value", Type: sig, Direction: "out"},
+				},
+			})
+		}
+		if prop.set != nil {
+			iface.Methods = append(iface.Methods, introspect.Method{
+				Name: fmt.Sprintf("%s%s%s", SetProp, name, prop.propType),
+				Args: []introspect.Arg{
+					{Name: "value", Type: sig, Direction: "in"},
+					{Name: "status", Type: "u", Direction: "out"},
+				},
+			})
+		}
+	}
+	return &introspect.Node{
+		Name:       "/default",
+		Interfaces: []introspect.Interface{introspect.IntrospectData, iface},
+	}
+}
+
+func (m introspectMethod) Call(...any) ([]any, error) {
+	b, err := xml.Marshal(m.s.introspectionNode())
+	if err != nil {
+		return nil, err
+	}
+	return []any{introspect.IntrospectDeclarationString + string(b)}, nil
+}
+
+func (introspectMethod) NumArguments() int     { return 0 }
+func (introspectMethod) NumReturns() int       { return 1 }
+func (introspectMethod) ArgumentValue(int) any { return nil }
+func (introspectMethod) ReturnValue(int) any   { return "" }
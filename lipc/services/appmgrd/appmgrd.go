@@ -0,0 +1,99 @@
+// Code generated by lipcgen from appmgrd.yaml. DO NOT EDIT.
+
+// Package appmgrd is a typed client for com.lab126.appmgrd, which starts and switches between apps.
+package appmgrd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+// Service is the LIPC service name.
+const Service = "com.lab126.appmgrd"
+
+// Property names.
+const (
+	PropStart     = "start"
+	PropActiveApp = "activeApp"
+)
+
+// Event names.
+const (
+	EventAppActivating = "appActivating"
+)
+
+// Client calls com.lab126.appmgrd.
+type Client struct {
+	caller lipc.Caller
+}
+
+// New makes a client that calls straight through an existing bus connection.
+func New(conn *dbus.Conn) *Client {
+	return NewWithCaller(lipc.Direct(conn))
+}
+
+// NewWithCaller makes a client that calls through caller, e.g. a *lipc.Client.
+func NewWithCaller(caller lipc.Caller) *Client {
+	return &Client{caller: caller}
+}
+
+// SetStart sets the start property.
+// Set to an app URI to start it, e.g. "app://com.lab126.booklet.home".
+func (c *Client) SetStart(ctx context.Context, v string) error {
+	return c.caller.SetStr(ctx, Service, PropStart, v)
+}
+
+// ActiveApp gets the activeApp property.
+// The URI of the app in the foreground.
+func (c *Client) ActiveApp(ctx context.Context) (string, error) {
+	return c.caller.GetStr(ctx, Service, PropActiveApp)
+}
+
+// AppActivatingEvent is the appActivating event.
+type AppActivatingEvent struct {
+	State int32
+	App   string
+}
+
+func parseAppActivatingEvent(ev lipc.Event) (AppActivatingEvent, error) {
+	var typed AppActivatingEvent
+	var err error
+	typed.State, err = ev.Int(0)
+	if err != nil {
+		return typed, err
+	}
+	typed.App, err = ev.Str(1)
+	if err != nil {
+		return typed, err
+	}
+	return typed, nil
+}
+
+// SubscribeAppActivating listens for appActivating events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeAppActivating(ctx context.Context) (<-chan AppActivatingEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventAppActivating)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan AppActivatingEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseAppActivatingEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
@@ -0,0 +1,3 @@
+package appmgrd
+
+//go:generate go run ../../../tools/lipcgen -schema appmgrd.yaml -out appmgrd.go
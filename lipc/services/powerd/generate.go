@@ -0,0 +1,3 @@
+package powerd
+
+//go:generate go run ../../../tools/lipcgen -schema powerd.yaml -out powerd.go
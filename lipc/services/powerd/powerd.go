@@ -0,0 +1,435 @@
+// Code generated by lipcgen from powerd.yaml. DO NOT EDIT.
+
+// Package powerd is a typed client for com.lab126.powerd, which manages power states,
+// the screensaver, the battery and the frontlight.
+package powerd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+// Service is the LIPC service name.
+const Service = "com.lab126.powerd"
+
+// Property names.
+const (
+	PropFlIntensity        = "flIntensity"
+	PropFlMaxIntensity     = "flMaxIntensity"
+	PropCurrentAmberLevel  = "currentAmberLevel"
+	PropStatus             = "status"
+	PropState              = "state"
+	PropBattLevel          = "battLevel"
+	PropIsCharging         = "isCharging"
+	PropPreventScreenSaver = "preventScreenSaver"
+)
+
+// Event names.
+const (
+	EventGoingToScreenSaver = "goingToScreenSaver"
+	EventOutOfScreenSaver   = "outOfScreenSaver"
+	EventExitingScreenSaver = "exitingScreenSaver"
+	EventCharging           = "charging"
+	EventNotCharging        = "notCharging"
+	EventBattLevelChanged   = "battLevelChanged"
+	EventReadyToSuspend     = "readyToSuspend"
+	EventWakeupFromSuspend  = "wakeupFromSuspend"
+)
+
+// Client calls com.lab126.powerd.
+type Client struct {
+	caller lipc.Caller
+}
+
+// New makes a client that calls straight through an existing bus connection.
+func New(conn *dbus.Conn) *Client {
+	return NewWithCaller(lipc.Direct(conn))
+}
+
+// NewWithCaller makes a client that calls through caller, e.g. a *lipc.Client.
+func NewWithCaller(caller lipc.Caller) *Client {
+	return &Client{caller: caller}
+}
+
+// FlIntensity gets the flIntensity property.
+// The frontlight brightness, from 0 to flMaxIntensity.
+func (c *Client) FlIntensity(ctx context.Context) (int32, error) {
+	return c.caller.GetInt(ctx, Service, PropFlIntensity)
+}
+
+// SetFlIntensity sets the flIntensity property.
+// The frontlight brightness, from 0 to flMaxIntensity.
+func (c *Client) SetFlIntensity(ctx context.Context, v int32) error {
+	return c.caller.SetInt(ctx, Service, PropFlIntensity, v)
+}
+
+// FlMaxIntensity gets the flMaxIntensity property.
+// The maximum frontlight brightness, which varies between models.
+func (c *Client) FlMaxIntensity(ctx context.Context) (int32, error) {
+	return c.caller.GetInt(ctx, Service, PropFlMaxIntensity)
+}
+
+// CurrentAmberLevel gets the currentAmberLevel property.
+// The warm light level, on models that have one.
+func (c *Client) CurrentAmberLevel(ctx context.Context) (int32, error) {
+	return c.caller.GetInt(ctx, Service, PropCurrentAmberLevel)
+}
+
+// SetCurrentAmberLevel sets the currentAmberLevel property.
+// The warm light level, on models that have one.
+func (c *Client) SetCurrentAmberLevel(ctx context.Context, v int32) error {
+	return c.caller.SetInt(ctx, Service, PropCurrentAmberLevel, v)
+}
+
+// Status gets the status property.
+// A multi-line summary of the power state, as shown by `lipc-get-prop com.lab126.powerd status`.
+func (c *Client) Status(ctx context.Context) (string, error) {
+	return c.caller.GetStr(ctx, Service, PropStatus)
+}
+
+// State gets the state property.
+// The current power state, e.g. "active" or "screenSaver".
+func (c *Client) State(ctx context.Context) (string, error) {
+	return c.caller.GetStr(ctx, Service, PropState)
+}
+
+// BattLevel gets the battLevel property.
+// The battery charge, as a percentage.
+func (c *Client) BattLevel(ctx context.Context) (int32, error) {
+	return c.caller.GetInt(ctx, Service, PropBattLevel)
+}
+
+// IsCharging gets the isCharging property.
+// 1 while charging, 0 otherwise.
+func (c *Client) IsCharging(ctx context.Context) (int32, error) {
+	return c.caller.GetInt(ctx, Service, PropIsCharging)
+}
+
+// Valid range for preventScreenSaver.
+const (
+	MinPreventScreenSaver int32 = 0
+	MaxPreventScreenSaver int32 = 1
+)
+
+// PreventScreenSaver gets the preventScreenSaver property.
+// Set to 1 to keep the device from going to the screensaver.
+func (c *Client) PreventScreenSaver(ctx context.Context) (int32, error) {
+	return c.caller.GetInt(ctx, Service, PropPreventScreenSaver)
+}
+
+// SetPreventScreenSaver sets the preventScreenSaver property.
+// Set to 1 to keep the device from going to the screensaver.
+func (c *Client) SetPreventScreenSaver(ctx context.Context, v int32) error {
+	if v < MinPreventScreenSaver || v > MaxPreventScreenSaver {
+		return fmt.Errorf("%w: preventScreenSaver must be in [%d, %d], got %d", lipc.ErrInvalidArg, MinPreventScreenSaver, MaxPreventScreenSaver, v)
+	}
+	return c.caller.SetInt(ctx, Service, PropPreventScreenSaver, v)
+}
+
+// GoingToScreenSaverEvent is the goingToScreenSaver event.
+type GoingToScreenSaverEvent struct {
+	Source int32
+}
+
+func parseGoingToScreenSaverEvent(ev lipc.Event) (GoingToScreenSaverEvent, error) {
+	var typed GoingToScreenSaverEvent
+	var err error
+	typed.Source, err = ev.Int(0)
+	if err != nil {
+		return typed, err
+	}
+	return typed, nil
+}
+
+// SubscribeGoingToScreenSaver listens for goingToScreenSaver events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeGoingToScreenSaver(ctx context.Context) (<-chan GoingToScreenSaverEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventGoingToScreenSaver)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan GoingToScreenSaverEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseGoingToScreenSaverEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
+
+// OutOfScreenSaverEvent is the outOfScreenSaver event.
+type OutOfScreenSaverEvent struct {
+	Source int32
+}
+
+func parseOutOfScreenSaverEvent(ev lipc.Event) (OutOfScreenSaverEvent, error) {
+	var typed OutOfScreenSaverEvent
+	var err error
+	typed.Source, err = ev.Int(0)
+	if err != nil {
+		return typed, err
+	}
+	return typed, nil
+}
+
+// SubscribeOutOfScreenSaver listens for outOfScreenSaver events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeOutOfScreenSaver(ctx context.Context) (<-chan OutOfScreenSaverEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventOutOfScreenSaver)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan OutOfScreenSaverEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseOutOfScreenSaverEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
+
+// ExitingScreenSaverEvent is the exitingScreenSaver event.
+type ExitingScreenSaverEvent struct{}
+
+func parseExitingScreenSaverEvent(lipc.Event) (ExitingScreenSaverEvent, error) {
+	return ExitingScreenSaverEvent{}, nil
+}
+
+// SubscribeExitingScreenSaver listens for exitingScreenSaver events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeExitingScreenSaver(ctx context.Context) (<-chan ExitingScreenSaverEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventExitingScreenSaver)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan ExitingScreenSaverEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseExitingScreenSaverEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
+
+// ChargingEvent is the charging event.
+type ChargingEvent struct{}
+
+func parseChargingEvent(lipc.Event) (ChargingEvent, error) {
+	return ChargingEvent{}, nil
+}
+
+// SubscribeCharging listens for charging events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeCharging(ctx context.Context) (<-chan ChargingEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventCharging)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan ChargingEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseChargingEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
+
+// NotChargingEvent is the notCharging event.
+type NotChargingEvent struct{}
+
+func parseNotChargingEvent(lipc.Event) (NotChargingEvent, error) {
+	return NotChargingEvent{}, nil
+}
+
+// SubscribeNotCharging listens for notCharging events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeNotCharging(ctx context.Context) (<-chan NotChargingEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventNotCharging)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan NotChargingEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseNotChargingEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
+
+// BattLevelChangedEvent is the battLevelChanged event.
+type BattLevelChangedEvent struct {
+	Level int32
+}
+
+func parseBattLevelChangedEvent(ev lipc.Event) (BattLevelChangedEvent, error) {
+	var typed BattLevelChangedEvent
+	var err error
+	typed.Level, err = ev.Int(0)
+	if err != nil {
+		return typed, err
+	}
+	return typed, nil
+}
+
+// SubscribeBattLevelChanged listens for battLevelChanged events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeBattLevelChanged(ctx context.Context) (<-chan BattLevelChangedEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventBattLevelChanged)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan BattLevelChangedEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseBattLevelChangedEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
+
+// ReadyToSuspendEvent is the readyToSuspend event.
+type ReadyToSuspendEvent struct {
+	Seconds int32
+}
+
+func parseReadyToSuspendEvent(ev lipc.Event) (ReadyToSuspendEvent, error) {
+	var typed ReadyToSuspendEvent
+	var err error
+	typed.Seconds, err = ev.Int(0)
+	if err != nil {
+		return typed, err
+	}
+	return typed, nil
+}
+
+// SubscribeReadyToSuspend listens for readyToSuspend events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeReadyToSuspend(ctx context.Context) (<-chan ReadyToSuspendEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventReadyToSuspend)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan ReadyToSuspendEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseReadyToSuspendEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
+
+// WakeupFromSuspendEvent is the wakeupFromSuspend event.
+type WakeupFromSuspendEvent struct {
+	Seconds int32
+}
+
+func parseWakeupFromSuspendEvent(ev lipc.Event) (WakeupFromSuspendEvent, error) {
+	var typed WakeupFromSuspendEvent
+	var err error
+	typed.Seconds, err = ev.Int(0)
+	if err != nil {
+		return typed, err
+	}
+	return typed, nil
+}
+
+// SubscribeWakeupFromSuspend listens for wakeupFromSuspend events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeWakeupFromSuspend(ctx context.Context) (<-chan WakeupFromSuspendEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventWakeupFromSuspend)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan WakeupFromSuspendEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseWakeupFromSuspendEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
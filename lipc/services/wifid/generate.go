@@ -0,0 +1,3 @@
+package wifid
+
+//go:generate go run ../../../tools/lipcgen -schema wifid.yaml -out wifid.go
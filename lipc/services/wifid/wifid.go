@@ -0,0 +1,172 @@
+// Code generated by lipcgen from wifid.yaml. DO NOT EDIT.
+
+// Package wifid is a typed client for com.lab126.wifid, which manages the WiFi connection.
+package wifid
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+// Service is the LIPC service name.
+const Service = "com.lab126.wifid"
+
+// Property names.
+const (
+	PropEnable         = "enable"
+	PropCmState        = "cmState"
+	PropSignalStrength = "signalStrength"
+	PropCurrentEssid   = "currentEssid"
+	PropScanList       = "scanList"
+	PropScan           = "scan"
+)
+
+// Event names.
+const (
+	EventCmStateChange = "cmStateChange"
+	EventScanComplete  = "scanComplete"
+)
+
+// Client calls com.lab126.wifid.
+type Client struct {
+	caller lipc.Caller
+}
+
+// New makes a client that calls straight through an existing bus connection.
+func New(conn *dbus.Conn) *Client {
+	return NewWithCaller(lipc.Direct(conn))
+}
+
+// NewWithCaller makes a client that calls through caller, e.g. a *lipc.Client.
+func NewWithCaller(caller lipc.Caller) *Client {
+	return &Client{caller: caller}
+}
+
+// Valid range for enable.
+const (
+	MinEnable int32 = 0
+	MaxEnable int32 = 1
+)
+
+// Enable gets the enable property.
+// 1 if WiFi is enabled.
+func (c *Client) Enable(ctx context.Context) (int32, error) {
+	return c.caller.GetInt(ctx, Service, PropEnable)
+}
+
+// SetEnable sets the enable property.
+// 1 if WiFi is enabled.
+func (c *Client) SetEnable(ctx context.Context, v int32) error {
+	if v < MinEnable || v > MaxEnable {
+		return fmt.Errorf("%w: enable must be in [%d, %d], got %d", lipc.ErrInvalidArg, MinEnable, MaxEnable, v)
+	}
+	return c.caller.SetInt(ctx, Service, PropEnable, v)
+}
+
+// CmState gets the cmState property.
+// The connection manager state, e.g. "CONNECTED", "CONNECTING" or "NA".
+func (c *Client) CmState(ctx context.Context) (string, error) {
+	return c.caller.GetStr(ctx, Service, PropCmState)
+}
+
+// SignalStrength gets the signalStrength property.
+// The signal strength of the current network, e.g. "4/5".
+func (c *Client) SignalStrength(ctx context.Context) (string, error) {
+	return c.caller.GetStr(ctx, Service, PropSignalStrength)
+}
+
+// CurrentEssid gets the currentEssid property.
+// The ESSID of the current network.
+func (c *Client) CurrentEssid(ctx context.Context) (string, error) {
+	return c.caller.GetStr(ctx, Service, PropCurrentEssid)
+}
+
+// ScanList gets the scanList property.
+// The networks found by the last scan, one hash per network.
+func (c *Client) ScanList(ctx context.Context) (lipc.Hasharray, error) {
+	return c.caller.GetHasharray(ctx, Service, PropScanList)
+}
+
+// SetScan sets the scan property.
+// Set to start a scan. A scanComplete event is sent when it finishes.
+func (c *Client) SetScan(ctx context.Context, v int32) error {
+	return c.caller.SetInt(ctx, Service, PropScan, v)
+}
+
+// CmStateChangeEvent is the cmStateChange event.
+type CmStateChangeEvent struct {
+	State string
+}
+
+func parseCmStateChangeEvent(ev lipc.Event) (CmStateChangeEvent, error) {
+	var typed CmStateChangeEvent
+	var err error
+	typed.State, err = ev.Str(0)
+	if err != nil {
+		return typed, err
+	}
+	return typed, nil
+}
+
+// SubscribeCmStateChange listens for cmStateChange events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeCmStateChange(ctx context.Context) (<-chan CmStateChangeEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventCmStateChange)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan CmStateChangeEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseCmStateChangeEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
+
+// ScanCompleteEvent is the scanComplete event.
+type ScanCompleteEvent struct{}
+
+func parseScanCompleteEvent(lipc.Event) (ScanCompleteEvent, error) {
+	return ScanCompleteEvent{}, nil
+}
+
+// SubscribeScanComplete listens for scanComplete events until ctx is cancelled; see lipc.Subscribe.
+// Events with unexpected parameters are logged and dropped.
+func (c *Client) SubscribeScanComplete(ctx context.Context) (<-chan ScanCompleteEvent, error) {
+	events, err := c.caller.Subscribe(ctx, Service, EventScanComplete)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(chan ScanCompleteEvent, cap(events))
+	go func() {
+		defer close(typed)
+		for ev := range events {
+			typedEv, err := parseScanCompleteEvent(ev)
+			if err != nil {
+				slog.Warn("dropping malformed LIPC event", "event", ev, "error", err)
+				continue
+			}
+			select {
+			case typed <- typedEv:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return typed, nil
+}
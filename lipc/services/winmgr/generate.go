@@ -0,0 +1,3 @@
+package winmgr
+
+//go:generate go run ../../../tools/lipcgen -schema winmgr.yaml -out winmgr.go
@@ -0,0 +1,48 @@
+// Code generated by lipcgen from winmgr.yaml. DO NOT EDIT.
+
+// Package winmgr is a typed client for com.lab126.winmgr, the window manager.
+package winmgr
+
+import (
+	"context"
+
+	"github.com/clintharrison/bueno/lipc"
+	"github.com/godbus/dbus/v5"
+)
+
+// Service is the LIPC service name.
+const Service = "com.lab126.winmgr"
+
+// Property names.
+const (
+	PropOrientationLock = "orientationLock"
+)
+
+// Client calls com.lab126.winmgr.
+type Client struct {
+	caller lipc.Caller
+}
+
+// New makes a client that calls straight through an existing bus connection.
+func New(conn *dbus.Conn) *Client {
+	return NewWithCaller(lipc.Direct(conn))
+}
+
+// NewWithCaller makes a client that calls through caller, e.g. a *lipc.Client.
+func NewWithCaller(caller lipc.Caller) *Client {
+	return &Client{caller: caller}
+}
+
+// OrientationLock gets the orientationLock property.
+// The locked screen orientation: "U" (portrait), "D" (inverted portrait), "L" or "R" (landscape),
+// or "" when unlocked.
+func (c *Client) OrientationLock(ctx context.Context) (string, error) {
+	return c.caller.GetStr(ctx, Service, PropOrientationLock)
+}
+
+// SetOrientationLock sets the orientationLock property.
+// The locked screen orientation: "U" (portrait), "D" (inverted portrait), "L" or "R" (landscape),
+// or "" when unlocked.
+func (c *Client) SetOrientationLock(ctx context.Context, v string) error {
+	return c.caller.SetStr(ctx, Service, PropOrientationLock, v)
+}
@@ -0,0 +1,131 @@
+package lipc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SharedMemory stores serialized hasharrays so they can be handed between processes by key.
+type SharedMemory interface {
+	// Read returns the contents of the segment with the given key.
+	Read(key string) ([]byte, error)
+	// Write stores data in a new segment and returns its key.
+	Write(data []byte) (string, error)
+	// Remove deletes the segment with the given key.
+	Remove(key string) error
+}
+
+// DevShmDir is where Linux mounts POSIX shared memory, for DevShm.
+const DevShmDir = "/dev/shm"
+
+// sharedMemoryOrDefault returns mem, or the system's shared memory if it's nil.
+func sharedMemoryOrDefault(mem SharedMemory) SharedMemory {
+	if mem == nil {
+		return DevShm{Dir: DevShmDir}
+	}
+	return mem
+}
+
+var segmentCounter atomic.Uint64
+
+// newSegmentKey returns a process-unique segment key, in the "/name" form used by shm_open(3).
+func newSegmentKey() string {
+	return fmt.Sprintf("/lipc-go.%d.%d", os.Getpid(), segmentCounter.Add(1))
+}
+
+var errInvalidSegmentKey = errors.New("invalid shared memory segment key")
+
+// DevShm is a SharedMemory backed by POSIX shared memory, which on Linux is a tmpfs mounted at
+// DevShmDir.
+type DevShm struct {
+	Dir string
+}
+
+func (d DevShm) path(key string) (string, error) {
+	name := strings.TrimPrefix(key, "/")
+	// shm_open names can't contain slashes, and we don't want to be tricked into reading other files
+	if name == "" || strings.Contains(name, "/") || name == "." || name == ".." {
+		return "", fmt.Errorf("%w: %q", errInvalidSegmentKey, key)
+	}
+	return filepath.Join(d.Dir, name), nil
+}
+
+func (d DevShm) Read(key string) ([]byte, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p) //#nosec G304 -- path is validated above
+}
+
+func (d DevShm) Write(data []byte) (string, error) {
+	key := newSegmentKey()
+	p, err := d.path(key)
+	if err != nil {
+		return "", err
+	}
+	// world-readable, since the service on the other end usually runs as a different user
+	err = os.WriteFile(p, data, 0o644) //#nosec G306
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (d DevShm) Remove(key string) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// MemSharedMemory is an in-process SharedMemory, standing in for /dev/shm in tests and fakes.
+// The zero value is ready to use.
+type MemSharedMemory struct {
+	mu       sync.Mutex
+	segments map[string][]byte
+}
+
+func (m *MemSharedMemory) Read(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.segments[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", os.ErrNotExist, key)
+	}
+	return data, nil
+}
+
+func (m *MemSharedMemory) Write(data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.segments == nil {
+		m.segments = make(map[string][]byte)
+	}
+	key := newSegmentKey()
+	m.segments[key] = append([]byte(nil), data...)
+	return key, nil
+}
+
+func (m *MemSharedMemory) Remove(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.segments[key]; !ok {
+		return fmt.Errorf("%w: %q", os.ErrNotExist, key)
+	}
+	delete(m.segments, key)
+	return nil
+}
+
+// Len returns the number of segments currently stored, to check for leaks.
+func (m *MemSharedMemory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.segments)
+}
@@ -0,0 +1,140 @@
+// lipcgen generates a typed Go client for an LIPC service from its schema (see lipc/schema).
+//
+// Usage:
+//
+//	//go:generate go run github.com/clintharrison/bueno/tools/lipcgen -schema powerd.yaml -out powerd.go
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"flag"
+	"fmt"
+	"go/format"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/clintharrison/bueno/core/logutil"
+	"github.com/clintharrison/bueno/lipc/schema"
+)
+
+//go:embed client.go.tmpl
+var clientTemplate string
+
+// exported turns an LIPC name like "flIntensity" into an exported Go identifier like "FlIntensity".
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// goType is the Go type generated for a schema type.
+func goType(t schema.Type) string {
+	switch t {
+	case schema.Int:
+		return "int32"
+	case schema.Str:
+		return "string"
+	case schema.Hasharray:
+		return "lipc.Hasharray"
+	}
+	return "any"
+}
+
+// callSuffix is the suffix of the lipc.Caller methods for a property type, e.g. GetInt.
+func callSuffix(t schema.Type) string {
+	switch t {
+	case schema.Int:
+		return "Int"
+	case schema.Str:
+		return "Str"
+	}
+	return "Hasharray"
+}
+
+// docLines turns a (possibly multi-line) doc string into comment lines.
+func docLines(doc string) []string {
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return nil
+	}
+	return strings.Split(doc, "\n")
+}
+
+func generate(svc *schema.Service, schemaPath string) ([]byte, error) {
+	tmpl, err := template.New("client").Funcs(template.FuncMap{
+		"exported":   exported,
+		"goType":     goType,
+		"callSuffix": callSuffix,
+		"docLines":   docLines,
+	}).Parse(clientTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"Schema":   svc,
+		"Source":   filepath.Base(schemaPath),
+		"NeedsFmt": needsFmt(svc),
+	})
+	if err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code doesn't compile: %w\n%s", err, buf.String())
+	}
+	return src, nil
+}
+
+// needsFmt reports whether any setter does a range check, which needs fmt.Errorf.
+func needsFmt(svc *schema.Service) bool {
+	for _, p := range svc.Properties {
+		if p.Range != nil && p.Access.Writable() {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	err := doMain()
+	if err != nil {
+		slog.Error("Application error", "error", err)
+		os.Exit(1)
+	}
+}
+
+func doMain() error {
+	logutil.ConfigureInteractiveLogger()
+
+	schemaPath := flag.String("schema", "", "path to the service schema YAML")
+	outPath := flag.String("out", "", "path to write the generated client to")
+	flag.Parse()
+	if *schemaPath == "" || *outPath == "" {
+		flag.Usage()
+		return fmt.Errorf("-schema and -out are required")
+	}
+
+	svc, err := schema.Load(*schemaPath)
+	if err != nil {
+		return err
+	}
+	src, err := generate(svc, *schemaPath)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(*outPath, src, 0o644) //#nosec G306
+	if err != nil {
+		return err
+	}
+	slog.Info("generated client", "service", svc.Name, "out", *outPath)
+	return nil
+}
@@ -17,7 +17,10 @@ import (
 )
 
 type InputDeviceWatcher struct {
-	Devices    []address.Address
+	Devices []address.Address
+	// Resolver, if set, is used to match devices using resolvable private addresses
+	// against the identity addresses in Devices.
+	Resolver   *address.Resolver
 	AddFunc    func(dev *evdev.InputDevice)
 	RemoveFunc func(uevent netlink.UEvent)
 }
@@ -123,10 +126,11 @@ func (w *InputDeviceWatcher) handleEvent(uevent netlink.UEvent) {
 			}
 
 			matched := slices.ContainsFunc(w.Devices, func(device address.Address) bool {
-				return device == addr
+				// the unique ID doesn't say whether the address is public or random, so it may be an RPA
+				return w.Resolver.Matches(addr, true, device)
 			})
 			if !matched {
-				slog.Debug("ignoring device not matching patterns", "addr", addr.ToString(), "path", dev.Path())
+				slog.Debug("ignoring device not matching patterns", "addr", addr, "path", dev.Path())
 				quietly.Close(dev)
 				return
 			}
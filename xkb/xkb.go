@@ -10,6 +10,7 @@ import "C"
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"runtime"
 	"unsafe"
@@ -62,6 +63,17 @@ const (
 	XKPageDown XKeysym = 0xFF56
 )
 
+// KeysymFromName returns the keysym with the given name, as used by xdotool, e.g. "Page_Down" or "Return".
+func KeysymFromName(name string) (XKeysym, error) {
+	nameCStr := C.CString(name)
+	defer C.free(unsafe.Pointer(nameCStr))
+	keysym := C.XStringToKeysym(nameCStr)
+	if keysym == C.NoSymbol {
+		return 0, fmt.Errorf("unknown keysym %q", name)
+	}
+	return XKeysym(keysym), nil
+}
+
 func (x *X11) KeyPress(keysym XKeysym) error {
 	wnd := x.getActiveWindow()
 	if wnd == 0 {